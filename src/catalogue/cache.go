@@ -0,0 +1,364 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// cache.go contains a read-through cache for the read operations of the
+// service, as catalogue data changes rarely but is expensive to query.
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Cache is a bounded store of service results, each kept for a limited time.
+// Concurrent misses on the same key share a single call to the service.
+type Cache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mtx        sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List // of *cacheEntry, most recently used first
+	inflight   map[string]*cacheCall
+	generation uint64 // incremented by Invalidate
+
+	requests      *prometheus.CounterVec
+	invalidations prometheus.Counter
+}
+
+type cacheEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+type cacheCall struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// NewCache returns a cache holding up to size results for ttl each. Register
+// it with Prometheus to expose its hit and miss counts.
+func NewCache(size int, ttl time.Duration) *Cache {
+	return &Cache{
+		size:     size,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		inflight: make(map[string]*cacheCall),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "catalogue_cache_requests_total",
+			Help: "Catalogue cache lookups, by method and result (hit or miss).",
+		}, []string{"method", "result"}),
+		invalidations: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "catalogue_cache_invalidations_total",
+			Help: "Times the catalogue cache was emptied because of a write.",
+		}),
+	}
+}
+
+// Invalidate drops all cached results, including those of calls still in
+// flight. Writes to the catalogue must call it.
+func (c *Cache) Invalidate() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	c.generation++
+	c.invalidations.Inc()
+}
+
+// InvalidateProduct drops the cached results holding a product, as after a
+// change to its stock, and the results of calls still in flight. Results
+// not holding it, such as counts, are kept.
+func (c *Cache) InvalidateProduct(id string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for e := c.lru.Front(); e != nil; {
+		next := e.Next()
+		if holdsProduct(e.Value.(*cacheEntry).value, id) {
+			c.remove(e)
+		}
+		e = next
+	}
+	c.generation++
+}
+
+// holdsProduct tells whether a cached result holds a product.
+func holdsProduct(value interface{}, id string) bool {
+	var products []Product
+	switch v := value.(type) {
+	case Product:
+		return v.ID == id
+	case []Product:
+		products = v
+	case listResult:
+		products = v.products
+	}
+	for _, p := range products {
+		if p.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of cached results, expired or not.
+func (c *Cache) Len() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.lru.Len()
+}
+
+// Describe implements prometheus.Collector.
+func (c *Cache) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.invalidations.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Cache) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.invalidations.Collect(ch)
+}
+
+// get returns the cached result for key, or loads, caches and returns it.
+// Errors are not cached.
+func (c *Cache) get(ctx context.Context, method, key string, load func() (interface{}, error)) (interface{}, error) {
+	c.mtx.Lock()
+	if e, ok := c.entries[key]; ok {
+		entry := e.Value.(*cacheEntry)
+		if c.now().Before(entry.expires) {
+			c.lru.MoveToFront(e)
+			c.mtx.Unlock()
+			c.requests.WithLabelValues(method, "hit").Inc()
+			return entry.value, nil
+		}
+		c.remove(e)
+	}
+	c.requests.WithLabelValues(method, "miss").Inc()
+	if call, ok := c.inflight[key]; ok {
+		c.mtx.Unlock()
+		call.wg.Wait()
+		// The call shared may have failed for the deadline or cancellation
+		// of the caller making it, rather than of this one.
+		if isContextError(call.err) && ctx.Err() == nil {
+			return c.get(ctx, method, key, load)
+		}
+		return call.value, call.err
+	}
+	call := &cacheCall{}
+	call.wg.Add(1)
+	c.inflight[key] = call
+	generation := c.generation
+	c.mtx.Unlock()
+
+	call.value, call.err = load()
+
+	c.mtx.Lock()
+	delete(c.inflight, key)
+	// A result loaded across an invalidation may predate the write.
+	if call.err == nil && generation == c.generation {
+		c.add(key, call.value)
+	}
+	c.mtx.Unlock()
+	call.wg.Done()
+
+	return call.value, call.err
+}
+
+// add caches a value, evicting the least recently used ones beyond the size.
+// The caller holds the lock.
+func (c *Cache) add(key string, value interface{}) {
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:     key,
+		value:   value,
+		expires: c.now().Add(c.ttl),
+	})
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *Cache) remove(e *list.Element) {
+	c.lru.Remove(e)
+	delete(c.entries, e.Value.(*cacheEntry).key)
+}
+
+// cacheKey identifies a call by method and arguments.
+func cacheKey(method string, args ...interface{}) string {
+	b, _ := json.Marshal(args)
+	return method + string(b)
+}
+
+// CachingMiddleware serves List, Count, Get, Related, Categories and
+// CategoryTree from the cache, and invalidates it on writes. Other methods
+// pass through.
+func CachingMiddleware(cache *Cache) Middleware {
+	return func(next Service) Service {
+		return cachingMiddleware{
+			Service: next,
+			cache:   cache,
+		}
+	}
+}
+
+type cachingMiddleware struct {
+	Service
+	cache *Cache
+}
+
+type listResult struct {
+	products []Product
+	next     string
+}
+
+func (mw cachingMiddleware) List(ctx context.Context, filter Filter, order, cursor, currency string, languages []string, pageNum, pageSize int) ([]Product, string, error) {
+	v, err := mw.cache.get(ctx, "List", cacheKey("List", filter, order, cursor, currency, languages, pageNum, pageSize), func() (interface{}, error) {
+		products, next, err := mw.Service.List(ctx, filter, order, cursor, currency, languages, pageNum, pageSize)
+		return listResult{products, next}, err
+	})
+	result := v.(listResult)
+	return result.products, result.next, err
+}
+
+func (mw cachingMiddleware) Count(ctx context.Context, filter Filter) (int, error) {
+	v, err := mw.cache.get(ctx, "Count", cacheKey("Count", filter), func() (interface{}, error) {
+		return mw.Service.Count(ctx, filter)
+	})
+	return v.(int), err
+}
+
+func (mw cachingMiddleware) Get(ctx context.Context, id, currency string, languages []string) (Product, error) {
+	v, err := mw.cache.get(ctx, "Get", cacheKey("Get", id, currency, languages), func() (interface{}, error) {
+		return mw.Service.Get(ctx, id, currency, languages)
+	})
+	return v.(Product), err
+}
+
+func (mw cachingMiddleware) Related(ctx context.Context, id, currency string, languages []string, size int) ([]Product, error) {
+	v, err := mw.cache.get(ctx, "Related", cacheKey("Related", id, currency, languages, size), func() (interface{}, error) {
+		return mw.Service.Related(ctx, id, currency, languages, size)
+	})
+	return v.([]Product), err
+}
+
+func (mw cachingMiddleware) Categories(ctx context.Context) ([]string, error) {
+	v, err := mw.cache.get(ctx, "Categories", cacheKey("Categories"), func() (interface{}, error) {
+		return mw.Service.Categories(ctx)
+	})
+	return v.([]string), err
+}
+
+func (mw cachingMiddleware) CategoryTree(ctx context.Context) ([]Category, error) {
+	v, err := mw.cache.get(ctx, "CategoryTree", cacheKey("CategoryTree"), func() (interface{}, error) {
+		return mw.Service.CategoryTree(ctx)
+	})
+	return v.([]Category), err
+}
+
+func (mw cachingMiddleware) Create(ctx context.Context, product Product) (Product, error) {
+	defer mw.cache.Invalidate()
+	return mw.Service.Create(ctx, product)
+}
+
+func (mw cachingMiddleware) Update(ctx context.Context, product Product) (Product, error) {
+	defer mw.cache.Invalidate()
+	return mw.Service.Update(ctx, product)
+}
+
+func (mw cachingMiddleware) Delete(ctx context.Context, id string, version int) error {
+	defer mw.cache.Invalidate()
+	return mw.Service.Delete(ctx, id, version)
+}
+
+func (mw cachingMiddleware) Import(ctx context.Context, products []Product, dryRun bool) (ImportReport, error) {
+	if !dryRun {
+		defer mw.cache.Invalidate()
+	}
+	return mw.Service.Import(ctx, products, dryRun)
+}
+
+// SetRates changes the prices of cached products.
+func (mw cachingMiddleware) SetRates(ctx context.Context, rates Rates) (Rates, error) {
+	defer mw.cache.Invalidate()
+	return mw.Service.SetRates(ctx, rates)
+}
+
+// SetCoViews changes the ranking of cached related products.
+func (mw cachingMiddleware) SetCoViews(ctx context.Context, coViews CoViews) error {
+	defer mw.cache.Invalidate()
+	return mw.Service.SetCoViews(ctx, coViews)
+}
+
+// CreatePromotion changes the sale prices of cached products.
+func (mw cachingMiddleware) CreatePromotion(ctx context.Context, promotion Promotion) (Promotion, error) {
+	defer mw.cache.Invalidate()
+	return mw.Service.CreatePromotion(ctx, promotion)
+}
+
+func (mw cachingMiddleware) ExpirePromotion(ctx context.Context, id string) (Promotion, error) {
+	defer mw.cache.Invalidate()
+	return mw.Service.ExpirePromotion(ctx, id)
+}
+
+// ModerateReview changes the rating of the product of the review, when it
+// approves it or rejects it once approved.
+func (mw cachingMiddleware) ModerateReview(ctx context.Context, id, status string) (Review, error) {
+	defer mw.cache.Invalidate()
+	return mw.Service.ModerateReview(ctx, id, status)
+}
+
+// Reserve changes the stock available of the cached product reserved, and
+// only of it, as no filter or sort goes by it. Reservations expiring change
+// it too, which cached products show once they expire.
+func (mw cachingMiddleware) Reserve(ctx context.Context, reservation Reservation) (Reservation, error) {
+	reservation, err := mw.Service.Reserve(ctx, reservation)
+	if err == nil {
+		mw.cache.InvalidateProduct(reservation.SKU)
+	}
+	return reservation, err
+}
+
+// CommitReservation changes the qty and version of the product, which lists
+// and counts may be filtered or sorted by.
+func (mw cachingMiddleware) CommitReservation(ctx context.Context, id string) (Reservation, error) {
+	reservation, err := mw.Service.CommitReservation(ctx, id)
+	if err == nil {
+		mw.cache.Invalidate()
+	}
+	return reservation, err
+}
+
+func (mw cachingMiddleware) ReleaseReservation(ctx context.Context, id string) (Reservation, error) {
+	reservation, err := mw.Service.ReleaseReservation(ctx, id)
+	if err == nil {
+		mw.cache.InvalidateProduct(reservation.SKU)
+	}
+	return reservation, err
+}
+
+// SetTranslations changes the titles and descriptions of cached products.
+func (mw cachingMiddleware) SetTranslations(ctx context.Context, translations []Translation) ([]Translation, error) {
+	defer mw.cache.Invalidate()
+	return mw.Service.SetTranslations(ctx, translations)
+}
+
+func (mw cachingMiddleware) DeleteTranslation(ctx context.Context, id, locale string) error {
+	defer mw.cache.Invalidate()
+	return mw.Service.DeleteTranslation(ctx, id, locale)
+}
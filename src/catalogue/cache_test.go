@@ -0,0 +1,247 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+package catalogue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingService counts the calls reaching it. Methods it does not define
+// panic through the nil embedded Service.
+type countingService struct {
+	Service
+	calls   int64
+	release chan struct{} // when set, Get blocks until it is closed
+}
+
+func (s *countingService) Get(ctx context.Context, id, currency string, languages []string) (Product, error) {
+	atomic.AddInt64(&s.calls, 1)
+	if s.release != nil {
+		<-s.release
+	}
+	if err := ctx.Err(); err != nil {
+		return Product{}, err
+	}
+	if id == "0" {
+		return Product{}, ErrNotFound
+	}
+	return Product{ID: id}, nil
+}
+
+func (s *countingService) Count(ctx context.Context, filter Filter) (int, error) {
+	atomic.AddInt64(&s.calls, 1)
+	return len(filter.Categories), nil
+}
+
+func (s *countingService) Delete(ctx context.Context, id string, version int) error {
+	return nil
+}
+
+func (s *countingService) Reserve(ctx context.Context, reservation Reservation) (Reservation, error) {
+	if reservation.Qty > 1 {
+		return Reservation{}, ErrInsufficientStock
+	}
+	return reservation, nil
+}
+
+func (s *countingService) CommitReservation(ctx context.Context, id string) (Reservation, error) {
+	return Reservation{ID: id, SKU: "1", Qty: 1}, nil
+}
+
+func TestCachingMiddleware(t *testing.T) {
+	ctx := context.Background()
+	next := &countingService{}
+	cache := NewCache(2, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	s := CachingMiddleware(cache)(next)
+
+	for i := 0; i < 3; i++ {
+		if p, err := s.Get(ctx, "1", "", nil); err != nil || p.ID != "1" {
+			t.Fatalf("Get(1): have %v, %v", p, err)
+		}
+	}
+	if next.calls != 1 {
+		t.Errorf("Get(1) three times: want 1 call, have %d", next.calls)
+	}
+
+	// Errors are not cached.
+	for i := 0; i < 2; i++ {
+		if _, err := s.Get(ctx, "0", "", nil); err != ErrNotFound {
+			t.Errorf("Get(0): want %v, have %v", ErrNotFound, err)
+		}
+	}
+	if next.calls != 3 {
+		t.Errorf("Get(0) twice: want 3 calls, have %d", next.calls)
+	}
+
+	// Arguments are part of the key.
+	s.Count(ctx, Filter{Categories: []string{"odd"}})
+	s.Count(ctx, Filter{Categories: []string{"odd"}, MatchAll: true})
+	if next.calls != 5 {
+		t.Errorf("Count of two filters: want 5 calls, have %d", next.calls)
+	}
+
+	// Bounded: Get(1) was least recently used and is evicted.
+	if cache.Len() != 2 {
+		t.Errorf("Len(): want 2, have %d", cache.Len())
+	}
+	s.Get(ctx, "1", "", nil)
+	if next.calls != 6 {
+		t.Errorf("Get(1) after eviction: want 6 calls, have %d", next.calls)
+	}
+
+	// Expiry.
+	now = now.Add(time.Minute)
+	s.Get(ctx, "1", "", nil)
+	if next.calls != 7 {
+		t.Errorf("Get(1) after expiry: want 7 calls, have %d", next.calls)
+	}
+
+	// Writes invalidate.
+	s.Delete(ctx, "1", 1)
+	if cache.Len() != 0 {
+		t.Errorf("Len() after Delete: want 0, have %d", cache.Len())
+	}
+	s.Get(ctx, "1", "", nil)
+	if next.calls != 8 {
+		t.Errorf("Get(1) after Delete: want 8 calls, have %d", next.calls)
+	}
+}
+
+func TestCachingMiddlewareReserve(t *testing.T) {
+	ctx := context.Background()
+	next := &countingService{}
+	cache := NewCache(10, time.Minute)
+	s := CachingMiddleware(cache)(next)
+
+	s.Get(ctx, "1", "", nil)
+	s.Get(ctx, "2", "", nil)
+	s.Count(ctx, Filter{})
+
+	// Failed reservations change nothing.
+	if _, err := s.Reserve(ctx, Reservation{SKU: "1", Qty: 2}); err != ErrInsufficientStock {
+		t.Errorf("Reserve: want %v, have %v", ErrInsufficientStock, err)
+	}
+	if cache.Len() != 3 {
+		t.Errorf("Len() after failed Reserve: want 3, have %d", cache.Len())
+	}
+
+	// Reservations change the product reserved, and only it.
+	if _, err := s.Reserve(ctx, Reservation{SKU: "1", Qty: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Len() after Reserve: want 2, have %d", cache.Len())
+	}
+	s.Get(ctx, "2", "", nil)
+	s.Count(ctx, Filter{})
+	s.Get(ctx, "1", "", nil)
+	if next.calls != 4 {
+		t.Errorf("reads after Reserve(1): want 4 calls, have %d", next.calls)
+	}
+
+	// Commits change the qty, which counts may go by.
+	if _, err := s.CommitReservation(ctx, "r1"); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Len() after CommitReservation: want 0, have %d", cache.Len())
+	}
+}
+
+func TestCachingMiddlewareSingleflight(t *testing.T) {
+	ctx := context.Background()
+	next := &countingService{release: make(chan struct{})}
+	cache := NewCache(10, time.Minute)
+	s := CachingMiddleware(cache)(next)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if p, err := s.Get(ctx, "1", "", nil); err != nil || p.ID != "1" {
+				errs <- errors.New("unexpected result")
+			}
+		}()
+	}
+	// Let the callers pile up on the first miss before it returns.
+	for atomic.LoadInt64(&next.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(next.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if next.calls != 1 {
+		t.Errorf("10 concurrent Get(1): want 1 call, have %d", next.calls)
+	}
+}
+
+func TestCacheSharedCallCancelled(t *testing.T) {
+	next := &countingService{release: make(chan struct{})}
+	s := CachingMiddleware(NewCache(10, time.Minute))(next)
+
+	// The first caller gives up while the second waits on its call.
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := s.Get(ctx, "1", "", nil)
+		first <- err
+	}()
+	for atomic.LoadInt64(&next.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	second := make(chan error)
+	go func() {
+		_, err := s.Get(context.Background(), "1", "", nil)
+		second <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	close(next.release)
+
+	if err := <-first; err != context.Canceled {
+		t.Errorf("Get cancelled: want %v, have %v", context.Canceled, err)
+	}
+	if err := <-second; err != nil || next.calls != 2 {
+		t.Errorf("Get sharing the cancelled call: want it made again, have %d calls, %v", next.calls, err)
+	}
+}
+
+func TestCacheInvalidateInFlight(t *testing.T) {
+	ctx := context.Background()
+	next := &countingService{release: make(chan struct{})}
+	cache := NewCache(10, time.Minute)
+	s := CachingMiddleware(cache)(next)
+
+	done := make(chan struct{})
+	go func() {
+		s.Get(ctx, "1", "", nil)
+		close(done)
+	}()
+	for atomic.LoadInt64(&next.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// The write lands while the read is in flight: its result may be stale.
+	cache.Invalidate()
+	close(next.release)
+	<-done
+
+	if cache.Len() != 0 {
+		t.Errorf("Len(): want 0, have %d", cache.Len())
+	}
+}
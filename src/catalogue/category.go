@@ -0,0 +1,156 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// category.go contains the tree of the categories of products, which
+// filters by a category descend into.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidCategory is returned when a category has an invalid slug, the
+// slug of another category, or a parent that is not a category or would make
+// it its own ancestor.
+var ErrInvalidCategory = errors.New("invalid category")
+
+// Category is a category of products. Categories form a tree, in which a
+// category is under its Parent, named, among its siblings by Position and
+// then by name. Products are in the categories they list, and filters by a
+// category match those in its descendants too.
+type Category struct {
+	Name        string     `json:"name" db:"name"`
+	Slug        string     `json:"slug" db:"slug"` // naming it in URLs, derived from its name by default
+	Parent      string     `json:"parent,omitempty" db:"parent"`
+	Position    int        `json:"position" db:"position"`
+	Description string     `json:"description,omitempty" db:"description"`
+	Children    []Category `json:"children,omitempty" db:"-"` // in the tree
+}
+
+// UnmarshalJSON reads a category from an object, or from its name alone as
+// fixtures listed categories before they formed a tree.
+func (c *Category) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		*c = Category{Name: name}
+		return nil
+	}
+	type category Category
+	return json.Unmarshal(b, (*category)(c))
+}
+
+// slugify derives a slug from a name: its letters and digits, in lower case,
+// with dashes between words.
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// normalizeCategories validates categories, leaving out blank and repeated
+// names, and returns them with each after its parent.
+func normalizeCategories(categories []Category) ([]Category, error) {
+	byName := make(map[string]Category, len(categories))
+	slugs := make(map[string]bool, len(categories))
+	var names []string
+	for _, c := range categories {
+		c.Name = strings.TrimSpace(c.Name)
+		c.Slug = strings.TrimSpace(c.Slug)
+		c.Parent = strings.TrimSpace(c.Parent)
+		c.Description = strings.TrimSpace(c.Description)
+		c.Children = nil
+		if _, ok := byName[c.Name]; ok || c.Name == "" {
+			continue
+		}
+		if c.Slug == "" {
+			c.Slug = slugify(c.Name)
+		}
+		if c.Slug == "" || c.Slug != slugify(c.Slug) || len(c.Slug) > 40 || slugs[c.Slug] || len(c.Description) > 500 {
+			return nil, fmt.Errorf("category %q: %w", c.Name, ErrInvalidCategory)
+		}
+		byName[c.Name], slugs[c.Slug] = c, true
+		names = append(names, c.Name)
+	}
+
+	// Place each category once its parent is, until none can be: those left
+	// have no parent, or are their own ancestors.
+	placed := make(map[string]bool, len(names))
+	result := make([]Category, 0, len(names))
+	for len(result) < len(names) {
+		n := len(result)
+		for _, name := range names {
+			c := byName[name]
+			if !placed[name] && (c.Parent == "" || placed[c.Parent]) {
+				placed[name] = true
+				result = append(result, c)
+			}
+		}
+		if len(result) == n {
+			for _, name := range names {
+				if !placed[name] {
+					return nil, fmt.Errorf("category %q: %w", name, ErrInvalidCategory)
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// categoryTree arranges categories into their tree, and returns its roots.
+// Categories whose parent is not among them are roots.
+func categoryTree(categories []Category) []Category {
+	known := make(map[string]bool, len(categories))
+	for _, c := range categories {
+		known[c.Name] = true
+	}
+	children := make(map[string][]Category)
+	for _, c := range categories {
+		parent := c.Parent
+		if !known[parent] {
+			parent = ""
+		}
+		children[parent] = append(children[parent], c)
+	}
+
+	var grow func(parent string, seen map[string]bool) []Category
+	grow = func(parent string, seen map[string]bool) []Category {
+		branch := []Category{}
+		for _, c := range children[parent] {
+			if seen[c.Name] {
+				continue
+			}
+			seen[c.Name] = true
+			c.Children = grow(c.Name, seen)
+			if len(c.Children) == 0 {
+				c.Children = nil
+			}
+			branch = append(branch, c)
+		}
+		sort.SliceStable(branch, func(i, j int) bool {
+			if branch[i].Position != branch[j].Position {
+				return branch[i].Position < branch[j].Position
+			}
+			return branch[i].Name < branch[j].Name
+		})
+		return branch
+	}
+	return grow("", make(map[string]bool, len(categories)))
+}
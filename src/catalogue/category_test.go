@@ -0,0 +1,150 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+package catalogue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-kit/kit/log"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestNormalizeCategories(t *testing.T) {
+	var categories []Category
+	if err := json.Unmarshal([]byte(`["Wet Food", {"name": " Food ", "position": 1}, {"name": "Dry Food", "slug": "kibble", "parent": "Food"}, "", "Food"]`), &categories); err != nil {
+		t.Fatal(err)
+	}
+	categories[0].Parent = "Food"
+	have, err := normalizeCategories(categories)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Category{
+		{Name: "Food", Slug: "food", Position: 1},
+		{Name: "Dry Food", Slug: "kibble", Parent: "Food"},
+		{Name: "Wet Food", Slug: "wet-food", Parent: "Food"},
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("normalizeCategories: want %+v, have %+v", want, have)
+	}
+
+	for name, categories := range map[string][]Category{
+		"invalid slug":   {{Name: "Food", Slug: "Dry Food"}},
+		"duplicate slug": {{Name: "Dry Food"}, {Name: "Dry-Food"}},
+		"unknown parent": {{Name: "Dry Food", Parent: "Food"}},
+		"cycle":          {{Name: "Food", Parent: "Dry Food"}, {Name: "Dry Food", Parent: "Food"}},
+	} {
+		if _, err := normalizeCategories(categories); !errors.Is(err, ErrInvalidCategory) {
+			t.Errorf("%s: want %v, have %v", name, ErrInvalidCategory, err)
+		}
+	}
+}
+
+func TestCategoryTree(t *testing.T) {
+	ctx := context.Background()
+	fixture := testFixture
+	fixture.Categories = []Category{
+		{Name: "Toys", Position: 2},
+		{Name: "Food", Position: 1},
+		{Name: "Bowls", Parent: "Food", Position: 2},
+		{Name: "Treats", Parent: "Food", Position: 1},
+	}
+	store, err := NewMemoryStore(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewCatalogueService(store)
+
+	tree, err := s.CategoryTree(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Category{
+		{Name: "Food", Slug: "food", Position: 1, Children: []Category{
+			{Name: "Treats", Slug: "treats", Parent: "Food", Position: 1},
+			{Name: "Bowls", Slug: "bowls", Parent: "Food", Position: 2},
+		}},
+		{Name: "Toys", Slug: "toys", Position: 2},
+	}
+	if !reflect.DeepEqual(tree, want) {
+		t.Errorf("CategoryTree: want %+v, have %+v", want, tree)
+	}
+
+	// Filtering by Food matches A, in Bowls, along with C, in Food itself.
+	for _, tc := range []struct {
+		filter Filter
+		want   int
+	}{
+		{Filter{Categories: []string{"Food"}}, 2},
+		{Filter{Categories: []string{"Bowls"}}, 2},
+		{Filter{Categories: []string{"Food", "Toys"}, MatchAll: true}, 0},
+		{Filter{Categories: []string{"Food", "Bowls"}, MatchAll: true}, 2},
+	} {
+		if n, err := s.Count(ctx, tc.filter); err != nil || n != tc.want {
+			t.Errorf("Count %+v: want %d, have %d, %v", tc.filter, tc.want, n, err)
+		}
+	}
+}
+
+func TestCategoryTreeHTTP(t *testing.T) {
+	h := newTestHandler(newTestMemoryService(t))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/categories/tree", nil))
+	var tree struct {
+		Categories []Category `json:"categories"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&tree); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || len(tree.Categories) != 3 || tree.Categories[0].Name != "Bowls" || tree.Categories[0].Slug != "bowls" {
+		t.Errorf("GET /categories/tree: have %d %+v", rec.Code, tree.Categories)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/categories", nil))
+	var flat struct {
+		Categories []string `json:"categories"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&flat); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || len(flat.Categories) != 3 {
+		t.Errorf("GET /categories: have %d %+v", rec.Code, flat.Categories)
+	}
+}
+
+func TestPostgresStoreCategoryTree(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	store := NewPostgresStore(sqlx.NewDb(db, "sqlmock"), log.NewNopLogger())
+
+	rows := sqlmock.NewRows([]string{"name", "slug", "parent", "position", "description"}).
+		AddRow("Wet Food", "wet-food", "Food", 1, "").
+		AddRow("Food", "food", "", 1, "Food for cats.")
+	mock.ExpectQuery("SELECT categories.name, categories.slug, COALESCE\\(parent.name, ''\\) AS parent, .* FROM categories LEFT JOIN categories parent ON parent.category_id = categories.parent_id").
+		WillReturnRows(rows)
+
+	tree, err := store.CategoryTree(context.Background())
+	want := []Category{{Name: "Food", Slug: "food", Position: 1, Description: "Food for cats.", Children: []Category{
+		{Name: "Wet Food", Slug: "wet-food", Parent: "Food", Position: 1},
+	}}}
+	if err != nil || !reflect.DeepEqual(tree, want) {
+		t.Errorf("CategoryTree: want %+v, have %+v, %v", want, tree, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,213 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"mushop/catalogue"
+
+	"github.com/go-kit/kit/log"
+	"github.com/jmoiron/sqlx"
+)
+
+const commandUsage = `usage: cataloguesvc [flags] command
+commands:
+  migrate up|down|status  apply, revert or list schema migrations
+  seed                    add the products of the fixture
+  import FILE             import products from FILE, - for stdin
+  export [FILE]           export all products to FILE, stdout by default`
+
+// commandOptions are the flags the commands use.
+type commandOptions struct {
+	connectString *string
+	fixture       *string
+	format        *string
+	dryRun        *bool
+}
+
+// runCommand runs a maintenance command on the database instead of the
+// service. Flags may follow the command too.
+func runCommand(command string, args []string, opts commandOptions) error {
+	var action string
+	if command == "migrate" {
+		if len(args) == 0 {
+			return errors.New(commandUsage)
+		}
+		action, args = args[0], args[1:]
+	}
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return err
+	}
+	files := flag.Args()
+	switch {
+	case command == "import" && len(files) != 1,
+		command == "export" && len(files) > 1,
+		command != "import" && command != "export" && len(files) > 0:
+		return errors.New(commandUsage)
+	}
+
+	db, err := sqlx.Open("postgres", *opts.connectString)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	migrator := catalogue.NewMigrator(db)
+	logger := log.NewLogfmtLogger(os.Stderr)
+	ctx := context.Background()
+
+	switch command {
+	case "migrate":
+		return migrate(ctx, migrator, action)
+	case "seed":
+		if err := migrator.Check(ctx); err != nil {
+			return err
+		}
+		seed, err := catalogue.ReadFixture(*opts.fixture)
+		if err != nil {
+			return err
+		}
+		created, err := catalogue.SeedPostgres(ctx, db, seed, logger)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("seeded %d of %d products from %s\n", created, len(seed.Products), *opts.fixture)
+		return nil
+	case "import", "export":
+		if err := migrator.Check(ctx); err != nil {
+			return err
+		}
+		service := catalogue.NewCatalogueService(catalogue.NewPostgresStore(db, logger))
+		file := "-"
+		if len(files) > 0 {
+			file = files[0]
+		}
+		format := *opts.format
+		if format == "" {
+			format = catalogue.FormatOf(file)
+		}
+		if format == "" {
+			format = catalogue.FormatJSON
+		}
+		if command == "import" {
+			return importProducts(ctx, service, file, format, *opts.dryRun)
+		}
+		return exportProducts(ctx, service, file, format)
+	}
+	return errors.New(commandUsage)
+}
+
+func importProducts(ctx context.Context, service catalogue.Service, file, format string, dryRun bool) error {
+	in := os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	products, err := catalogue.ReadProducts(in, format)
+	var report catalogue.ImportReport
+	if err == nil {
+		report, err = service.Import(ctx, products, dryRun)
+	}
+	var rows catalogue.ImportErrors
+	if errors.As(err, &rows) {
+		for _, row := range rows {
+			switch {
+			case row.Row == 0:
+				fmt.Fprintf(os.Stderr, "%s: %s\n", file, row.Error)
+			case row.ID != "":
+				fmt.Fprintf(os.Stderr, "%s: row %d (%s): %s\n", file, row.Row, row.ID, row.Error)
+			default:
+				fmt.Fprintf(os.Stderr, "%s: row %d: %s\n", file, row.Row, row.Error)
+			}
+		}
+		return fmt.Errorf("%s: nothing imported, %d rows in error", file, len(rows))
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, id := range report.Created {
+		fmt.Printf("create %s\n", id)
+	}
+	for _, id := range report.Updated {
+		fmt.Printf("update %s\n", id)
+	}
+	summary := "imported"
+	if dryRun {
+		summary = "dry run, nothing imported"
+	}
+	fmt.Printf("%s: %d created, %d updated, %d unchanged (%s)\n", file, len(report.Created), len(report.Updated), len(report.Unchanged), summary)
+	return nil
+}
+
+func exportProducts(ctx context.Context, service catalogue.Service, file, format string) error {
+	products, err := service.Export(ctx)
+	if err != nil {
+		return err
+	}
+	if file == "-" {
+		return catalogue.WriteProducts(os.Stdout, format, products)
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err = catalogue.WriteProducts(f, format, products); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d products to %s\n", len(products), file)
+	return nil
+}
+
+func migrate(ctx context.Context, migrator *catalogue.Migrator, action string) error {
+	switch action {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Printf("schema is up to date at version %d\n", catalogue.SchemaVersion())
+		}
+		return err
+	case "down":
+		reverted, err := migrator.Down(ctx)
+		if reverted != nil {
+			fmt.Printf("reverted %04d_%s\n", reverted.Version, reverted.Name)
+		} else if err == nil {
+			fmt.Println("no migration to revert")
+		}
+		return err
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	}
+	return errors.New(commandUsage)
+}
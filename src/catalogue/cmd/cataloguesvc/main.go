@@ -15,7 +15,9 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/Shopify/sarama"
 	"github.com/go-kit/kit/log"
+	kitgrpc "github.com/go-kit/kit/transport/grpc"
 	stdopentracing "github.com/opentracing/opentracing-go"
 	zipkin "github.com/openzipkin-contrib/zipkin-go-opentracing"
 
@@ -25,11 +27,16 @@ import (
 	"path/filepath"
 
 	"mushop/catalogue"
+	"mushop/catalogue/pb"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/weaveworks/common/middleware"
 	_ "github.com/lib/pq"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 
 	// OpenTelemetry imports
 	"go.opentelemetry.io/otel"
@@ -98,12 +105,39 @@ func initOpenTelemetry(ctx context.Context, serviceName string) (*sdktrace.Trace
 func main() {
 	var (
 		port          = flag.String("port", getEnv("CATALOGUE_PORT", "80"), "Port to bind HTTP listener")
+		grpcPort      = flag.String("grpc-port", getEnv("CATALOGUE_GRPC_PORT", "50051"), "Port to bind gRPC listener, none to serve HTTP only")
 		images        = flag.String("images", "./images/", "Image path")
+		imageCache    = flag.String("image-cache", filepath.Join(os.TempDir(), "catalogue-images"), "Directory of resized images")
+		imageCacheMax = flag.Int64("image-cache-size", 256<<20, "Bytes of resized images to keep, 0 disables the image cache")
 		connectString = flag.String("CONNECTSTRING", getEnv("DATABASE_URL", fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", getEnv("POSTGRES_HOST", "localhost"), getEnv("POSTGRES_PORT", "5432"), getEnv("POSTGRES_USER", "mushop"), getEnv("POSTGRES_PASSWORD", "mushop"), getEnv("POSTGRES_DB", "mushop_catalogue"))), "PostgreSQL connection string")
 		zip           = flag.String("zipkin", os.Getenv("ZIPKIN"), "Zipkin address")
+		store         = flag.String("store", getEnv("CATALOGUE_STORE", "postgres"), "Catalogue store: postgres, or memory seeded from the fixture")
+		fixture       = flag.String("fixture", "./dbdata/catalogue.json", "JSON fixture seeding the memory store")
+		rates         = flag.String("rates", getEnv("CATALOGUE_RATES", ""), "JSON file of the exchange rates prices are converted by")
+		coViews       = flag.String("coviews", getEnv("CATALOGUE_COVIEWS", ""), "JSON file of the co-view signals related products are blended with")
+		cacheSize     = flag.Int("cache-size", 1000, "Number of catalogue reads to cache, 0 disables the cache")
+		cacheTTL      = flag.Duration("cache-ttl", time.Minute, "Time catalogue reads are cached for")
+		deadlines     = flag.String("deadlines", getEnv("CATALOGUE_DEADLINES", "10s,Import=2m,Export=2m"), "Time each method is given before its queries are cancelled: a default, then Method=duration, 0 for none")
+		format        = flag.String("format", "", "Format of import and export files: csv or json, by default that of the file name")
+		dryRun        = flag.Bool("dry-run", false, "Report what import would change without changing it")
+		kafkaBrokers  = flag.String("kafka-brokers", getEnv("KAFKA_BROKERS", ""), "Comma separated Kafka brokers to publish product changes to, none to keep them in the outbox")
+		kafkaTopic    = flag.String("kafka-topic", getEnv("KAFKA_TOPIC", "mushop-catalogue"), "Kafka topic of product changes")
 	)
 	flag.Parse()
 
+	if flag.NArg() > 0 {
+		if err := runCommand(flag.Arg(0), flag.Args()[1:], commandOptions{
+			connectString: connectString,
+			fixture:       fixture,
+			format:        format,
+			dryRun:        dryRun,
+		}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	fmt.Fprintf(os.Stderr, "images: %q\n", *images)
 	abs, err := filepath.Abs(*images)
 	fmt.Fprintf(os.Stderr, "Abs(images): %q (%v)\n", abs, err)
@@ -175,31 +209,115 @@ func main() {
 	}
 
 	// Data domain.
-	db, err := sqlx.Open("postgres", *connectString)
-	if err != nil {
-		logger.Log("err", err)
+	var catalogueStore catalogue.Store
+	switch *store {
+	case "postgres":
+		db, err := sqlx.Open("postgres", *connectString)
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+
+		// Check if DB connection can be made, only for logging purposes, should not fail/exit
+		checkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err = db.PingContext(checkCtx)
+		if err != nil {
+			logger.Log("Error", "Unable to connect to Database", "CONNECTSTRING", connectString)
+		} else if err = catalogue.NewMigrator(db).Check(checkCtx); err != nil {
+			// The queries would fail on an older schema.
+			logger.Log("err", err, "hint", "run cataloguesvc migrate up")
+			os.Exit(1)
+		}
+		cancel()
+		catalogueStore = catalogue.NewPostgresStore(db, logger)
+	case "memory":
+		seed, err := catalogue.ReadFixture(*fixture)
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		catalogueStore, err = catalogue.NewMemoryStore(seed)
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		logger.Log("store", "memory", "fixture", *fixture, "products", len(seed.Products))
+	default:
+		logger.Log("err", "unknown store", "store", *store)
 		os.Exit(1)
 	}
-	defer db.Close()
 
-	// Check if DB connection can be made, only for logging purposes, should not fail/exit
-	err = db.Ping()
-	if err != nil {
-		logger.Log("Error", "Unable to connect to Database", "CONNECTSTRING", connectString)
+	// Change feed.
+	if *kafkaBrokers != "" {
+		config := sarama.NewConfig()
+		config.Producer.Return.Successes = true
+		config.Producer.RequiredAcks = sarama.WaitForAll
+		config.Producer.Retry.Max = 3
+		producer, err := sarama.NewSyncProducer(strings.Split(*kafkaBrokers, ","), config)
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		defer producer.Close()
+		publisher := catalogue.NewChangePublisher(catalogueStore, producer, *kafkaTopic, log.With(logger, "publisher", "kafka"))
+		go publisher.Run(ctx)
+		logger.Log("changes", "kafka", "brokers", *kafkaBrokers, "topic", *kafkaTopic)
 	}
 
+	// Expired reservations.
+	go catalogue.NewReservationReaper(catalogueStore, log.With(logger, "reaper", "reservations")).Run(ctx)
+
 	// Service domain.
 	var service catalogue.Service
 	{
-		service = catalogue.NewCatalogueService(db, logger)
+		service = catalogue.NewCatalogueService(catalogueStore)
+		if *rates != "" {
+			r, err := catalogue.ReadRates(*rates)
+			if err == nil {
+				_, err = service.SetRates(ctx, r)
+			}
+			if err != nil {
+				logger.Log("err", err)
+				os.Exit(1)
+			}
+		}
+		if *coViews != "" {
+			c, err := catalogue.ReadCoViews(*coViews)
+			if err == nil {
+				err = service.SetCoViews(ctx, c)
+			}
+			if err != nil {
+				logger.Log("err", err)
+				os.Exit(1)
+			}
+		}
+		if *cacheSize > 0 {
+			cache := catalogue.NewCache(*cacheSize, *cacheTTL)
+			prometheus.MustRegister(cache)
+			service = catalogue.CachingMiddleware(cache)(service)
+		}
+		d, err := catalogue.ParseDeadlines(*deadlines)
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		service = catalogue.DeadlineMiddleware(d)(service)
 		service = catalogue.LoggingMiddleware(logger)(service)
 	}
 
 	// Endpoint domain.
 	endpoints := catalogue.MakeEndpoints(service, tracer)
 
+	// Images.
+	imageServer, err := catalogue.NewImageServer(*images, *imageCache, *imageCacheMax, log.With(logger, "handler", "images"))
+	if err != nil {
+		logger.Log("err", err)
+		os.Exit(1)
+	}
+
 	// HTTP router
-	router := catalogue.MakeHTTPHandler(endpoints, *images, logger, tracer)
+	router := catalogue.MakeHTTPHandler(endpoints, imageServer, logger, tracer)
 
 	httpMiddleware := []middleware.Interface{
 		middleware.Instrument{
@@ -224,9 +342,29 @@ func main() {
 		errc <- http.ListenAndServe(":"+*port, otelHandler)
 	}()
 
+	// Create and launch the gRPC server, with health checks and reflection
+	// for tools such as grpcurl.
+	if *grpcPort != "" {
+		ln, err := net.Listen("tcp", ":"+*grpcPort)
+		if err != nil {
+			logger.Log("err", err)
+			os.Exit(1)
+		}
+		grpcServer := grpc.NewServer(grpc.UnaryInterceptor(kitgrpc.Interceptor))
+		pb.RegisterCatalogueServer(grpcServer, catalogue.MakeGRPCServer(endpoints, logger, tracer))
+		healthServer := health.NewServer()
+		go catalogue.NewHealthChecker(service, healthServer, log.With(logger, "transport", "gRPC")).Run(ctx)
+		healthpb.RegisterHealthServer(grpcServer, healthServer)
+		reflection.Register(grpcServer)
+		go func() {
+			logger.Log("transport", "gRPC", "port", *grpcPort)
+			errc <- grpcServer.Serve(ln)
+		}()
+	}
+
 	// Capture interrupts.
 	go func() {
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 		errc <- fmt.Errorf("%s", <-c)
 	}()
@@ -0,0 +1,85 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// conditional.go contains the HTTP caching support of the transport: strong
+// ETags on read responses, and 304 Not Modified for If-None-Match requests.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// readCacheControl is the Cache-Control of cacheable read responses. Clients
+// and proxies may reuse them for a minute, and revalidate by ETag thereafter.
+const readCacheControl = "public, max-age=60"
+
+type contextKey int
+
+const contextKeyIfNoneMatch contextKey = iota
+
+// ifNoneMatchToContext moves the If-None-Match header of a request into the
+// context, for the response encoder to see.
+func ifNoneMatchToContext(ctx context.Context, r *http.Request) context.Context {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		return context.WithValue(ctx, contextKeyIfNoneMatch, match)
+	}
+	return ctx
+}
+
+// marshalTagged encodes a response the way encodeResponse does, and returns
+// it along with its strong ETag. The prefix goes in front of the body hash.
+func marshalTagged(prefix string, response interface{}) ([]byte, string, error) {
+	body, err := json.Marshal(response)
+	if err != nil {
+		return nil, "", err
+	}
+	body = append(body, '\n')
+	sum := sha256.Sum256(body)
+	return body, `"` + prefix + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// productETagPrefix starts the ETag of a product with its version, so that
+// the If-Match header of a write says which version it was based on.
+func productETagPrefix(p Product) string {
+	return strconv.Itoa(p.Version) + "-"
+}
+
+// encodeCacheableResponse encodes a read response with ETag and Cache-Control
+// headers, or answers 304 Not Modified if the client already has it.
+func encodeCacheableResponse(ctx context.Context, w http.ResponseWriter, prefix string, response interface{}) error {
+	body, etag, err := marshalTagged(prefix, response)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", readCacheControl)
+	if match, ok := ctx.Value(contextKeyIfNoneMatch).(string); ok && etagMatches(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, err = w.Write(body)
+	return err
+}
+
+// etagMatches tells whether an If-None-Match header lists the ETag, using the
+// weak comparison RFC 7232 prescribes for it.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
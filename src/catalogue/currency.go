@@ -0,0 +1,278 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// currency.go contains the presentation of prices in other currencies than
+// the one products are priced in, by a table of exchange rates.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultCurrency is the currency of products created without one.
+const DefaultCurrency = "USD"
+
+// ErrUnknownCurrency is returned when prices are to be converted to or from a
+// currency the exchange rates do not cover.
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// ErrInvalidRates is returned when exchange rates are not positive decimals
+// keyed by ISO 4217 currency codes.
+var ErrInvalidRates = errors.New("invalid exchange rates")
+
+// Rates are exchange rates: how many units of each currency one unit of the
+// base currency buys. Rates are decimals, kept as JSON numbers so that they
+// are read without the rounding of floats.
+type Rates struct {
+	Base  string                 `json:"base"`
+	Rates map[string]json.Number `json:"rates"`
+}
+
+// ReadRates reads exchange rates from a JSON file.
+func ReadRates(path string) (Rates, error) {
+	var rates Rates
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Rates{}, err
+	}
+	if err = json.Unmarshal(b, &rates); err != nil {
+		return Rates{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return rates, nil
+}
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// currencyFormat is how amounts of a currency are written: after a symbol,
+// with a number of decimals, its minor units.
+type currencyFormat struct {
+	symbol   string
+	decimals int
+}
+
+// currencyFormats are the formats of the currencies the shop sells in.
+// Others are written after their code, with 2 decimals.
+var currencyFormats = map[string]currencyFormat{
+	"AUD": {"A$", 2},
+	"BRL": {"R$", 2},
+	"CAD": {"CA$", 2},
+	"CHF": {"CHF ", 2},
+	"CNY": {"CN¥", 2},
+	"EUR": {"€", 2},
+	"GBP": {"£", 2},
+	"INR": {"₹", 2},
+	"JPY": {"¥", 0},
+	"KRW": {"₩", 0},
+	"KWD": {"KWD ", 3},
+	"MXN": {"MX$", 2},
+	"USD": {"$", 2},
+}
+
+func formatOf(currency string) currencyFormat {
+	if f, ok := currencyFormats[currency]; ok {
+		return f
+	}
+	return currencyFormat{currency + " ", 2}
+}
+
+// exchange converts amounts between the currencies of a table of rates.
+type exchange struct {
+	rates  Rates
+	parsed map[string]*big.Rat // units per unit of the base currency
+}
+
+// newExchange validates exchange rates. The base currency has a rate of 1,
+// whether listed or not.
+func newExchange(rates Rates) (*exchange, error) {
+	rates.Base = strings.ToUpper(strings.TrimSpace(rates.Base))
+	if !currencyCode.MatchString(rates.Base) {
+		return nil, fmt.Errorf("%w: base %q is not a currency code", ErrInvalidRates, rates.Base)
+	}
+	x := &exchange{
+		rates:  Rates{Base: rates.Base, Rates: map[string]json.Number{rates.Base: "1"}},
+		parsed: map[string]*big.Rat{rates.Base: big.NewRat(1, 1)},
+	}
+	for code, rate := range rates.Rates {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if !currencyCode.MatchString(code) {
+			return nil, fmt.Errorf("%w: %q is not a currency code", ErrInvalidRates, code)
+		}
+		r, ok := new(big.Rat).SetString(string(rate))
+		if !ok || r.Sign() <= 0 {
+			return nil, fmt.Errorf("%w: rate %q of %s is not a positive decimal", ErrInvalidRates, rate, code)
+		}
+		if code == rates.Base && r.Cmp(big.NewRat(1, 1)) != 0 {
+			return nil, fmt.Errorf("%w: rate of base %s must be 1", ErrInvalidRates, code)
+		}
+		x.rates.Rates[code] = rate
+		x.parsed[code] = r
+	}
+	return x, nil
+}
+
+// defaultExchange knows the default currency alone.
+var defaultExchange, _ = newExchange(Rates{Base: DefaultCurrency})
+
+// convert converts an amount from a currency to another, rounded to the minor
+// units of the latter.
+func (x *exchange) convert(amount *big.Rat, from, to string) (*big.Rat, error) {
+	if from == to {
+		return roundDecimal(amount, formatOf(to).decimals), nil
+	}
+	fromRate, ok := x.parsed[from]
+	if !ok {
+		return nil, ErrUnknownCurrency
+	}
+	toRate, ok := x.parsed[to]
+	if !ok {
+		return nil, ErrUnknownCurrency
+	}
+	result := new(big.Rat).Mul(amount, toRate)
+	result.Quo(result, fromRate)
+	return roundDecimal(result, formatOf(to).decimals), nil
+}
+
+// factors returns the factors converting prices to a currency, by the
+// currency they are in, unrounded.
+func (x *exchange) factors(to string) (map[string]float64, error) {
+	toRate, ok := x.parsed[to]
+	if !ok {
+		return nil, ErrUnknownCurrency
+	}
+	factors := make(map[string]float64, len(x.parsed))
+	for code, rate := range x.parsed {
+		factors[code], _ = new(big.Rat).Quo(toRate, rate).Float64()
+	}
+	return factors, nil
+}
+
+// present returns a product priced in the given currency, by default its
+// own, with the price formatted, and so are its sale price and its variants.
+// Products without a currency are left alone. Prices are converted exactly,
+// but kept as float32, which holds whole minor units only up to 2^24 (about
+// 167,772.16 in a currency of cents); formatted prices are exact whatever
+// the amount.
+func (x *exchange) present(p Product, currency string) (Product, error) {
+	if p.Currency == "" {
+		return p, nil
+	}
+	if currency == "" {
+		currency = p.Currency
+	}
+	// A float32 price is the decimal it was written as, the shortest that
+	// reads back as the same float32.
+	amount, _ := new(big.Rat).SetString(strconv.FormatFloat(float64(p.Price), 'f', -1, 32))
+	converted, err := x.convert(amount, p.Currency, currency)
+	if err != nil {
+		return Product{}, err
+	}
+	if p.Variants != nil {
+		variants := make([]Variant, len(p.Variants))
+		for i, v := range p.Variants {
+			delta, _ := new(big.Rat).SetString(strconv.FormatFloat(float64(v.PriceDelta), 'f', -1, 32))
+			price, err := x.convert(delta.Add(delta, amount), p.Currency, currency)
+			if err != nil {
+				return Product{}, err
+			}
+			v.Price, _ = price.Float32()
+			v.FormattedPrice = formatPrice(price, currency)
+			variants[i] = v
+		}
+		p.Variants = variants
+	}
+	if p.PromotionID != "" {
+		sale, _ := new(big.Rat).SetString(strconv.FormatFloat(float64(p.SalePrice), 'f', -1, 32))
+		if sale, err = x.convert(sale, p.Currency, currency); err != nil {
+			return Product{}, err
+		}
+		p.SalePrice, _ = sale.Float32()
+		p.FormattedSalePrice = formatPrice(sale, currency)
+	}
+	p.Price, _ = converted.Float32()
+	p.Currency = currency
+	p.FormattedPrice = formatPrice(converted, currency)
+	return p, nil
+}
+
+// roundDecimal rounds to a number of decimals, halves away from zero.
+func roundDecimal(r *big.Rat, decimals int) *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(scale))
+	num, den := scaled.Num(), scaled.Denom()
+	q, m := new(big.Int).QuoRem(num, den, new(big.Int))
+	// Round away from zero when the remainder is at least half the
+	// denominator.
+	if new(big.Int).Lsh(m.Abs(m), 1).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return new(big.Rat).SetFrac(q, scale)
+}
+
+// formatPrice writes an amount after the symbol of its currency, with
+// thousands separated by commas, e.g. "$1,299.00".
+func formatPrice(amount *big.Rat, currency string) string {
+	f := formatOf(currency)
+	s := amount.FloatString(f.decimals)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, fraction = s[:i], s[i:]
+	}
+	var b strings.Builder
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	return sign + f.symbol + b.String() + fraction
+}
+
+// normalizeCurrency returns a currency code in upper case, the default one
+// when empty, or false if it is not a code.
+func normalizeCurrency(currency string) (string, bool) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return DefaultCurrency, true
+	}
+	return currency, currencyCode.MatchString(currency)
+}
+
+// exchangeRates holds the current exchange rates of a service.
+type exchangeRates struct {
+	mtx sync.RWMutex
+	x   *exchange
+}
+
+func (r *exchangeRates) get() *exchange {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	if r.x == nil {
+		return defaultExchange
+	}
+	return r.x
+}
+
+func (r *exchangeRates) set(x *exchange) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.x = x
+}
@@ -0,0 +1,200 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+package catalogue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testRates = Rates{Base: "USD", Rates: map[string]json.Number{"EUR": "0.9217", "JPY": "149.82", "KWD": "0.3075"}}
+
+func TestRoundDecimal(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		decimals int
+		want     string
+	}{
+		{"1.005", 2, "1.01"},
+		{"1.0049", 2, "1.00"},
+		{"-1.005", 2, "-1.01"},
+		{"2.5", 0, "3"},
+		{"1234.5678", 3, "1234.568"},
+	} {
+		r, _ := new(big.Rat).SetString(tc.value)
+		if have := roundDecimal(r, tc.decimals).FloatString(tc.decimals); have != tc.want {
+			t.Errorf("roundDecimal(%s, %d): want %s, have %s", tc.value, tc.decimals, tc.want, have)
+		}
+	}
+}
+
+func TestPresentPrice(t *testing.T) {
+	x, err := newExchange(testRates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := Product{ID: "A", Price: 18.5, Currency: "USD"}
+
+	for _, tc := range []struct {
+		currency, formatted string
+		price               float32
+	}{
+		{"", "$18.50", 18.5},
+		{"EUR", "€17.05", 17.05}, // 17.05145
+		{"JPY", "¥2,772", 2772},  // 2771.67
+		{"KWD", "KWD 5.689", 5.689},
+	} {
+		have, err := x.present(p, tc.currency)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have.FormattedPrice != tc.formatted || have.Price != tc.price {
+			t.Errorf("%q: want %s (%v), have %s (%v)", tc.currency, tc.formatted, tc.price, have.FormattedPrice, have.Price)
+		}
+	}
+
+	// Between two currencies other than the base.
+	eur := Product{ID: "B", Price: 100, Currency: "EUR"}
+	if have, _ := x.present(eur, "JPY"); have.FormattedPrice != "¥16,255" || have.Currency != "JPY" {
+		t.Errorf("EUR to JPY: have %s %s", have.FormattedPrice, have.Currency)
+	}
+	// Beyond 2^24 cents the float32 price loses them; the formatted one
+	// does not.
+	large := Product{ID: "C", Price: 12345678, Currency: "USD"}
+	if have, _ := x.present(large, "EUR"); have.FormattedPrice != "€11,379,011.41" || have.Price != 11379011 {
+		t.Errorf("large USD to EUR: have %s (%v)", have.FormattedPrice, have.Price)
+	}
+	if _, err := x.present(p, "GBP"); err != ErrUnknownCurrency {
+		t.Errorf("GBP: want %v, have %v", ErrUnknownCurrency, err)
+	}
+}
+
+func TestSetRates(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryService(t)
+
+	if _, err := s.Get(ctx, "A", "EUR", nil); err != ErrUnknownCurrency {
+		t.Errorf("Get in EUR without rates: want %v, have %v", ErrUnknownCurrency, err)
+	}
+	for _, bad := range []Rates{
+		{Base: "dollar"},
+		{Base: "USD", Rates: map[string]json.Number{"EUR": "-1"}},
+		{Base: "USD", Rates: map[string]json.Number{"EURO": "1"}},
+		{Base: "USD", Rates: map[string]json.Number{"USD": "2"}},
+	} {
+		if _, err := s.SetRates(ctx, bad); !errors.Is(err, ErrInvalidRates) {
+			t.Errorf("SetRates(%v): want %v, have %v", bad, ErrInvalidRates, err)
+		}
+	}
+
+	if _, err := s.SetRates(ctx, testRates); err != nil {
+		t.Fatal(err)
+	}
+	p, err := s.Get(ctx, "A", "EUR", nil)
+	if err != nil || p.Currency != "EUR" || p.FormattedPrice != "€9.21" {
+		t.Errorf("Get in EUR: have %v, %v", p, err)
+	}
+	products, _, err := s.List(ctx, Filter{}, "", "", "JPY", nil, 1, 10)
+	if err != nil || len(products) != 4 || products[0].FormattedPrice != "¥1,497" {
+		t.Errorf("List in JPY: have %v, %v", products, err)
+	}
+	// Stored prices are left alone.
+	if p, _ := s.Get(ctx, "A", "", nil); p.Price != 9.99 || p.Currency != "USD" {
+		t.Errorf("Get after conversions: have %v", p)
+	}
+}
+
+func TestPriceFilterCurrencies(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewMemoryStore(Fixture{Products: []Product{
+		{ID: "U", Title: "Dollars", Price: 40, Currency: "USD"}, // €36.87
+		{ID: "E", Title: "Euros", Price: 30, Currency: "EUR"},   // $32.55
+		{ID: "J", Title: "Yen", Price: 5000, Currency: "JPY"},   // $33.37, €30.76
+		{ID: "G", Title: "Pounds", Price: 10, Currency: "GBP"},  // no rate
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewCatalogueService(store)
+	if _, err := s.SetRates(ctx, testRates); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bounds are in the currency asked for, not in those of the products.
+	max := 31.0
+	products, _, err := s.List(ctx, Filter{MaxPrice: &max}, "price", "", "EUR", nil, 1, 10)
+	if want := "[E, J]"; err != nil || printIDs(products).String() != want {
+		t.Errorf("List(maxPrice=31 EUR): want %s, have %s, %v", want, printIDs(products), err)
+	}
+	min := 31.0
+	if n, err := s.Count(ctx, Filter{MinPrice: &min, Currency: "EUR"}); n != 1 || err != nil {
+		t.Errorf("Count(minPrice=31 EUR): want 1, have %d, %v", n, err)
+	}
+	if _, err := s.Count(ctx, Filter{MinPrice: &min, Currency: "CHF"}); err != ErrUnknownCurrency {
+		t.Errorf("Count(minPrice=31 CHF): want %v, have %v", ErrUnknownCurrency, err)
+	}
+
+	// Products sort by their price in one currency, page after page.
+	var ids []string
+	for cursor := ""; ; {
+		page, next, err := s.List(ctx, Filter{}, "price", cursor, "", nil, 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range page {
+			ids = append(ids, p.ID)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if want := "G E J U"; strings.Join(ids, " ") != want {
+		t.Errorf("List(price): want %s, have %s", want, strings.Join(ids, " "))
+	}
+
+	facets, err := s.Facets(ctx, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts := []int{facets.Prices[0].Count, facets.Prices[1].Count, facets.Prices[2].Count, facets.Prices[3].Count}; !reflect.DeepEqual(counts, []int{0, 0, 3, 0}) {
+		t.Errorf("Facets().Prices in USD: want 0 0 3 0, have %v", counts)
+	}
+}
+
+func TestRatesHTTP(t *testing.T) {
+	h := newTestHandler(newTestMemoryService(t))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("PUT", "/rates", strings.NewReader(`{"base": "usd", "rates": {"eur": 0.9217}}`)))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"EUR":0.9217`) {
+		t.Fatalf("PUT /rates: have %d %s", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("PUT", "/rates", strings.NewReader(`{"base": "USD", "rates": {"EUR": 0}}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("PUT /rates with zero rate: want %d, have %d", http.StatusBadRequest, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/catalogue/A?currency=eur", nil))
+	var p Product
+	json.NewDecoder(rec.Body).Decode(&p)
+	if rec.Code != http.StatusOK || p.Currency != "EUR" || p.FormattedPrice != "€9.21" {
+		t.Errorf("GET in EUR: have %d %v", rec.Code, p)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/catalogue?currency=GBP", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GET in GBP: want %d, have %d", http.StatusBadRequest, rec.Code)
+	}
+}
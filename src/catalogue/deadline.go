@@ -0,0 +1,286 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// deadline.go contains the deadlines of the methods of the service, past
+// which their queries are cancelled rather than left running for a client
+// that gave up on them.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Deadlines are the time each method of the service is given to complete, by
+// method name, and the Default time of the others. Zero means no deadline.
+type Deadlines struct {
+	Default time.Duration
+	Methods map[string]time.Duration
+}
+
+// ParseDeadlines parses deadlines such as "10s,Import=2m,Export=1m": the
+// default, followed by those of methods of the Service by name.
+func ParseDeadlines(s string) (Deadlines, error) {
+	d := Deadlines{Methods: make(map[string]time.Duration)}
+	service := reflect.TypeOf((*Service)(nil)).Elem()
+	for i, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		method, value, named := strings.Cut(part, "=")
+		if !named {
+			if i > 0 {
+				return Deadlines{}, fmt.Errorf("deadline %q: want Method=duration", part)
+			}
+			value = part
+		} else if _, ok := service.MethodByName(method); !ok {
+			return Deadlines{}, fmt.Errorf("deadline %q: no method %s", part, method)
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			return Deadlines{}, fmt.Errorf("deadline %q: invalid duration", part)
+		}
+		if named {
+			d.Methods[method] = timeout
+		} else {
+			d.Default = timeout
+		}
+	}
+	return d, nil
+}
+
+// of returns the deadline of a method.
+func (d Deadlines) of(method string) time.Duration {
+	if timeout, ok := d.Methods[method]; ok {
+		return timeout
+	}
+	return d.Default
+}
+
+// isContextError tells whether an error is that of a context past its
+// deadline or cancelled.
+func isContextError(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+}
+
+// DeadlineMiddleware gives each call of the methods reading or writing the
+// store the deadline of its method, past which its queries are cancelled and
+// it fails with context.DeadlineExceeded. Other methods pass through.
+func DeadlineMiddleware(deadlines Deadlines) Middleware {
+	return func(next Service) Service {
+		return deadlineMiddleware{
+			Service:   next,
+			deadlines: deadlines,
+		}
+	}
+}
+
+type deadlineMiddleware struct {
+	Service
+	deadlines Deadlines
+}
+
+// context returns the context of a call of a method, and the function
+// releasing it.
+func (mw deadlineMiddleware) context(ctx context.Context, method string) (context.Context, context.CancelFunc) {
+	if timeout := mw.deadlines.of(method); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+func (mw deadlineMiddleware) List(ctx context.Context, filter Filter, order, cursor, currency string, languages []string, pageNum, pageSize int) ([]Product, string, error) {
+	ctx, cancel := mw.context(ctx, "List")
+	defer cancel()
+	return mw.Service.List(ctx, filter, order, cursor, currency, languages, pageNum, pageSize)
+}
+
+func (mw deadlineMiddleware) Count(ctx context.Context, filter Filter) (int, error) {
+	ctx, cancel := mw.context(ctx, "Count")
+	defer cancel()
+	return mw.Service.Count(ctx, filter)
+}
+
+func (mw deadlineMiddleware) Facets(ctx context.Context, filter Filter) (Facets, error) {
+	ctx, cancel := mw.context(ctx, "Facets")
+	defer cancel()
+	return mw.Service.Facets(ctx, filter)
+}
+
+func (mw deadlineMiddleware) Search(ctx context.Context, query string, categories, languages []string, pageNum, pageSize int) ([]Product, error) {
+	ctx, cancel := mw.context(ctx, "Search")
+	defer cancel()
+	return mw.Service.Search(ctx, query, categories, languages, pageNum, pageSize)
+}
+
+// Suggest reads the store only to build its index, on first use.
+func (mw deadlineMiddleware) Suggest(ctx context.Context, query string, size int) ([]Suggestion, error) {
+	ctx, cancel := mw.context(ctx, "Suggest")
+	defer cancel()
+	return mw.Service.Suggest(ctx, query, size)
+}
+
+func (mw deadlineMiddleware) Get(ctx context.Context, id, currency string, languages []string) (Product, error) {
+	ctx, cancel := mw.context(ctx, "Get")
+	defer cancel()
+	return mw.Service.Get(ctx, id, currency, languages)
+}
+
+func (mw deadlineMiddleware) Batch(ctx context.Context, ids []string, currency string, languages []string) ([]Product, []string, error) {
+	ctx, cancel := mw.context(ctx, "Batch")
+	defer cancel()
+	return mw.Service.Batch(ctx, ids, currency, languages)
+}
+
+func (mw deadlineMiddleware) Related(ctx context.Context, id, currency string, languages []string, size int) ([]Product, error) {
+	ctx, cancel := mw.context(ctx, "Related")
+	defer cancel()
+	return mw.Service.Related(ctx, id, currency, languages, size)
+}
+
+func (mw deadlineMiddleware) Create(ctx context.Context, product Product) (Product, error) {
+	ctx, cancel := mw.context(ctx, "Create")
+	defer cancel()
+	return mw.Service.Create(ctx, product)
+}
+
+func (mw deadlineMiddleware) Update(ctx context.Context, product Product) (Product, error) {
+	ctx, cancel := mw.context(ctx, "Update")
+	defer cancel()
+	return mw.Service.Update(ctx, product)
+}
+
+func (mw deadlineMiddleware) Delete(ctx context.Context, id string, version int) error {
+	ctx, cancel := mw.context(ctx, "Delete")
+	defer cancel()
+	return mw.Service.Delete(ctx, id, version)
+}
+
+func (mw deadlineMiddleware) Import(ctx context.Context, products []Product, dryRun bool) (ImportReport, error) {
+	ctx, cancel := mw.context(ctx, "Import")
+	defer cancel()
+	return mw.Service.Import(ctx, products, dryRun)
+}
+
+func (mw deadlineMiddleware) Export(ctx context.Context) ([]Product, error) {
+	ctx, cancel := mw.context(ctx, "Export")
+	defer cancel()
+	return mw.Service.Export(ctx)
+}
+
+func (mw deadlineMiddleware) Translations(ctx context.Context, id string) ([]Translation, error) {
+	ctx, cancel := mw.context(ctx, "Translations")
+	defer cancel()
+	return mw.Service.Translations(ctx, id)
+}
+
+func (mw deadlineMiddleware) SetTranslations(ctx context.Context, translations []Translation) ([]Translation, error) {
+	ctx, cancel := mw.context(ctx, "SetTranslations")
+	defer cancel()
+	return mw.Service.SetTranslations(ctx, translations)
+}
+
+func (mw deadlineMiddleware) DeleteTranslation(ctx context.Context, id, locale string) error {
+	ctx, cancel := mw.context(ctx, "DeleteTranslation")
+	defer cancel()
+	return mw.Service.DeleteTranslation(ctx, id, locale)
+}
+
+func (mw deadlineMiddleware) Categories(ctx context.Context) ([]string, error) {
+	ctx, cancel := mw.context(ctx, "Categories")
+	defer cancel()
+	return mw.Service.Categories(ctx)
+}
+
+func (mw deadlineMiddleware) CategoryTree(ctx context.Context) ([]Category, error) {
+	ctx, cancel := mw.context(ctx, "CategoryTree")
+	defer cancel()
+	return mw.Service.CategoryTree(ctx)
+}
+
+func (mw deadlineMiddleware) Promotions(ctx context.Context) ([]Promotion, error) {
+	ctx, cancel := mw.context(ctx, "Promotions")
+	defer cancel()
+	return mw.Service.Promotions(ctx)
+}
+
+func (mw deadlineMiddleware) CreatePromotion(ctx context.Context, promotion Promotion) (Promotion, error) {
+	ctx, cancel := mw.context(ctx, "CreatePromotion")
+	defer cancel()
+	return mw.Service.CreatePromotion(ctx, promotion)
+}
+
+func (mw deadlineMiddleware) ExpirePromotion(ctx context.Context, id string) (Promotion, error) {
+	ctx, cancel := mw.context(ctx, "ExpirePromotion")
+	defer cancel()
+	return mw.Service.ExpirePromotion(ctx, id)
+}
+
+func (mw deadlineMiddleware) PriceHistory(ctx context.Context, id string) ([]PriceChange, error) {
+	ctx, cancel := mw.context(ctx, "PriceHistory")
+	defer cancel()
+	return mw.Service.PriceHistory(ctx, id)
+}
+
+func (mw deadlineMiddleware) Reviews(ctx context.Context, id string, pageNum, pageSize int) ([]Review, error) {
+	ctx, cancel := mw.context(ctx, "Reviews")
+	defer cancel()
+	return mw.Service.Reviews(ctx, id, pageNum, pageSize)
+}
+
+func (mw deadlineMiddleware) CreateReview(ctx context.Context, review Review) (Review, error) {
+	ctx, cancel := mw.context(ctx, "CreateReview")
+	defer cancel()
+	return mw.Service.CreateReview(ctx, review)
+}
+
+func (mw deadlineMiddleware) ListReviews(ctx context.Context, status string, pageNum, pageSize int) ([]Review, error) {
+	ctx, cancel := mw.context(ctx, "ListReviews")
+	defer cancel()
+	return mw.Service.ListReviews(ctx, status, pageNum, pageSize)
+}
+
+func (mw deadlineMiddleware) ModerateReview(ctx context.Context, id, status string) (Review, error) {
+	ctx, cancel := mw.context(ctx, "ModerateReview")
+	defer cancel()
+	return mw.Service.ModerateReview(ctx, id, status)
+}
+
+func (mw deadlineMiddleware) Reserve(ctx context.Context, reservation Reservation) (Reservation, error) {
+	ctx, cancel := mw.context(ctx, "Reserve")
+	defer cancel()
+	return mw.Service.Reserve(ctx, reservation)
+}
+
+func (mw deadlineMiddleware) CommitReservation(ctx context.Context, id string) (Reservation, error) {
+	ctx, cancel := mw.context(ctx, "CommitReservation")
+	defer cancel()
+	return mw.Service.CommitReservation(ctx, id)
+}
+
+func (mw deadlineMiddleware) ReleaseReservation(ctx context.Context, id string) (Reservation, error) {
+	ctx, cancel := mw.context(ctx, "ReleaseReservation")
+	defer cancel()
+	return mw.Service.ReleaseReservation(ctx, id)
+}
+
+func (mw deadlineMiddleware) ReplayChanges(ctx context.Context, from int64) (int, error) {
+	ctx, cancel := mw.context(ctx, "ReplayChanges")
+	defer cancel()
+	return mw.Service.ReplayChanges(ctx, from)
+}
+
+// Health gives up on a database that does not answer in time.
+func (mw deadlineMiddleware) Health(ctx context.Context) []Health {
+	ctx, cancel := mw.context(ctx, "Health")
+	defer cancel()
+	return mw.Service.Health(ctx)
+}
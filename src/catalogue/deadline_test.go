@@ -0,0 +1,91 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+package catalogue
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-kit/kit/log"
+	"github.com/jmoiron/sqlx"
+)
+
+// slowService answers Get once its context is done, or fails after a second
+// when there is no deadline.
+type slowService struct {
+	Service
+}
+
+func (slowService) Get(ctx context.Context, id, currency string, languages []string) (Product, error) {
+	select {
+	case <-ctx.Done():
+		return Product{}, ctx.Err()
+	case <-time.After(time.Second):
+		return Product{}, errors.New("no deadline")
+	}
+}
+
+func TestParseDeadlines(t *testing.T) {
+	d, err := ParseDeadlines("10s, Import=2m,Export=0")
+	want := Deadlines{Default: 10 * time.Second, Methods: map[string]time.Duration{"Import": 2 * time.Minute, "Export": 0}}
+	if err != nil || !reflect.DeepEqual(d, want) {
+		t.Errorf("ParseDeadlines: want %+v, have %+v, %v", want, d, err)
+	}
+	if d.of("List") != 10*time.Second || d.of("Import") != 2*time.Minute || d.of("Export") != 0 {
+		t.Errorf("deadlines of List, Import and Export: have %v, %v, %v", d.of("List"), d.of("Import"), d.of("Export"))
+	}
+	if d, err := ParseDeadlines("Get=1s"); err != nil || d.Default != 0 || d.of("Get") != time.Second {
+		t.Errorf("ParseDeadlines without default: have %+v, %v", d, err)
+	}
+
+	for _, s := range []string{"soon", "-1s", "1s,2s", "Nope=1s", "Get=", "Get=soon"} {
+		if _, err := ParseDeadlines(s); err == nil {
+			t.Errorf("ParseDeadlines(%q): want error", s)
+		}
+	}
+}
+
+func TestDeadlineMiddlewareHTTP(t *testing.T) {
+	s := DeadlineMiddleware(Deadlines{Default: 10 * time.Millisecond})(slowService{})
+	h := newTestHandler(s)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/catalogue/A", nil))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("GET past its deadline: want %d, have %d %s", http.StatusGatewayTimeout, rec.Code, rec.Body)
+	}
+}
+
+func TestPostgresStoreDeadline(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	store := NewPostgresStore(sqlx.NewDb(db, "sqlmock"), log.NewNopLogger())
+
+	mock.ExpectQuery("SELECT .* FROM products").WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"ID"}).AddRow("1"))
+	mock.ExpectQuery("SELECT name FROM categories").WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Bowls"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := store.Get(ctx, "1", nil); err != context.DeadlineExceeded {
+		t.Errorf("Get past its deadline: want %v, have %v", context.DeadlineExceeded, err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.Categories(ctx); err != context.Canceled {
+		t.Errorf("Categories cancelled: want %v, have %v", context.Canceled, err)
+	}
+}
@@ -18,22 +18,80 @@ import (
 
 // Endpoints collects the endpoints that comprise the Service.
 type Endpoints struct {
-	ListEndpoint       endpoint.Endpoint
-	CountEndpoint      endpoint.Endpoint
-	GetEndpoint        endpoint.Endpoint
-	CategoriesEndpoint endpoint.Endpoint
-	HealthEndpoint     endpoint.Endpoint
+	ListEndpoint               endpoint.Endpoint
+	CountEndpoint              endpoint.Endpoint
+	FacetsEndpoint             endpoint.Endpoint
+	SearchEndpoint             endpoint.Endpoint
+	SuggestEndpoint            endpoint.Endpoint
+	GetEndpoint                endpoint.Endpoint
+	BatchEndpoint              endpoint.Endpoint
+	RelatedEndpoint            endpoint.Endpoint
+	CreateEndpoint             endpoint.Endpoint
+	UpdateEndpoint             endpoint.Endpoint
+	DeleteEndpoint             endpoint.Endpoint
+	ImportEndpoint             endpoint.Endpoint
+	ExportEndpoint             endpoint.Endpoint
+	TranslationsEndpoint       endpoint.Endpoint
+	SetTranslationsEndpoint    endpoint.Endpoint
+	DeleteTranslationEndpoint  endpoint.Endpoint
+	CategoriesEndpoint         endpoint.Endpoint
+	CategoryTreeEndpoint       endpoint.Endpoint
+	RatesEndpoint              endpoint.Endpoint
+	SetRatesEndpoint           endpoint.Endpoint
+	SetCoViewsEndpoint         endpoint.Endpoint
+	PromotionsEndpoint         endpoint.Endpoint
+	CreatePromotionEndpoint    endpoint.Endpoint
+	ExpirePromotionEndpoint    endpoint.Endpoint
+	PriceHistoryEndpoint       endpoint.Endpoint
+	ReviewsEndpoint            endpoint.Endpoint
+	CreateReviewEndpoint       endpoint.Endpoint
+	ListReviewsEndpoint        endpoint.Endpoint
+	ModerateReviewEndpoint     endpoint.Endpoint
+	ReserveEndpoint            endpoint.Endpoint
+	CommitReservationEndpoint  endpoint.Endpoint
+	ReleaseReservationEndpoint endpoint.Endpoint
+	ReplayChangesEndpoint      endpoint.Endpoint
+	HealthEndpoint             endpoint.Endpoint
 }
 
 // MakeEndpoints returns an Endpoints structure, where each endpoint is
 // backed by the given service.
 func MakeEndpoints(s Service, tracer stdopentracing.Tracer) Endpoints {
 	return Endpoints{
-		ListEndpoint:       opentracing.TraceServer(tracer, "GET /catalogue")(MakeListEndpoint(s)),
-		CountEndpoint:      opentracing.TraceServer(tracer, "GET /catalogue/size")(MakeCountEndpoint(s)),
-		GetEndpoint:        opentracing.TraceServer(tracer, "GET /catalogue/{id}")(MakeGetEndpoint(s)),
-		CategoriesEndpoint: opentracing.TraceServer(tracer, "GET /categories")(MakeCategoriesEndpoint(s)),
-		HealthEndpoint:     opentracing.TraceServer(tracer, "GET /health")(MakeHealthEndpoint(s)),
+		ListEndpoint:               opentracing.TraceServer(tracer, "GET /catalogue")(MakeListEndpoint(s)),
+		CountEndpoint:              opentracing.TraceServer(tracer, "GET /catalogue/size")(MakeCountEndpoint(s)),
+		FacetsEndpoint:             opentracing.TraceServer(tracer, "GET /catalogue/facets")(MakeFacetsEndpoint(s)),
+		SearchEndpoint:             opentracing.TraceServer(tracer, "GET /catalogue/search")(MakeSearchEndpoint(s)),
+		SuggestEndpoint:            opentracing.TraceServer(tracer, "GET /catalogue/suggest")(MakeSuggestEndpoint(s)),
+		GetEndpoint:                opentracing.TraceServer(tracer, "GET /catalogue/{id}")(MakeGetEndpoint(s)),
+		BatchEndpoint:              opentracing.TraceServer(tracer, "POST /catalogue/batch")(MakeBatchEndpoint(s)),
+		RelatedEndpoint:            opentracing.TraceServer(tracer, "GET /catalogue/{id}/related")(MakeRelatedEndpoint(s)),
+		CreateEndpoint:             opentracing.TraceServer(tracer, "POST /catalogue")(MakeCreateEndpoint(s)),
+		UpdateEndpoint:             opentracing.TraceServer(tracer, "PUT /catalogue/{id}")(MakeUpdateEndpoint(s)),
+		DeleteEndpoint:             opentracing.TraceServer(tracer, "DELETE /catalogue/{id}")(MakeDeleteEndpoint(s)),
+		ImportEndpoint:             opentracing.TraceServer(tracer, "POST /catalogue/import")(MakeImportEndpoint(s)),
+		ExportEndpoint:             opentracing.TraceServer(tracer, "GET /admin/catalogue/export")(MakeExportEndpoint(s)),
+		TranslationsEndpoint:       opentracing.TraceServer(tracer, "GET /catalogue/{id}/translations")(MakeTranslationsEndpoint(s)),
+		SetTranslationsEndpoint:    opentracing.TraceServer(tracer, "POST /translations")(MakeSetTranslationsEndpoint(s)),
+		DeleteTranslationEndpoint:  opentracing.TraceServer(tracer, "DELETE /catalogue/{id}/translations/{locale}")(MakeDeleteTranslationEndpoint(s)),
+		CategoriesEndpoint:         opentracing.TraceServer(tracer, "GET /categories")(MakeCategoriesEndpoint(s)),
+		CategoryTreeEndpoint:       opentracing.TraceServer(tracer, "GET /categories/tree")(MakeCategoryTreeEndpoint(s)),
+		RatesEndpoint:              opentracing.TraceServer(tracer, "GET /rates")(MakeRatesEndpoint(s)),
+		SetRatesEndpoint:           opentracing.TraceServer(tracer, "PUT /rates")(MakeSetRatesEndpoint(s)),
+		SetCoViewsEndpoint:         opentracing.TraceServer(tracer, "PUT /coviews")(MakeSetCoViewsEndpoint(s)),
+		PromotionsEndpoint:         opentracing.TraceServer(tracer, "GET /promotions")(MakePromotionsEndpoint(s)),
+		CreatePromotionEndpoint:    opentracing.TraceServer(tracer, "POST /promotions")(MakeCreatePromotionEndpoint(s)),
+		ExpirePromotionEndpoint:    opentracing.TraceServer(tracer, "POST /promotions/{id}/expire")(MakeExpirePromotionEndpoint(s)),
+		PriceHistoryEndpoint:       opentracing.TraceServer(tracer, "GET /admin/catalogue/{id}/prices")(MakePriceHistoryEndpoint(s)),
+		ReviewsEndpoint:            opentracing.TraceServer(tracer, "GET /catalogue/{id}/reviews")(MakeReviewsEndpoint(s)),
+		CreateReviewEndpoint:       opentracing.TraceServer(tracer, "POST /catalogue/{id}/reviews")(MakeCreateReviewEndpoint(s)),
+		ListReviewsEndpoint:        opentracing.TraceServer(tracer, "GET /admin/reviews")(MakeListReviewsEndpoint(s)),
+		ModerateReviewEndpoint:     opentracing.TraceServer(tracer, "POST /reviews/{id}/{action}")(MakeModerateReviewEndpoint(s)),
+		ReserveEndpoint:            opentracing.TraceServer(tracer, "POST /reservations")(MakeReserveEndpoint(s)),
+		CommitReservationEndpoint:  opentracing.TraceServer(tracer, "POST /reservations/{id}/commit")(MakeCommitReservationEndpoint(s)),
+		ReleaseReservationEndpoint: opentracing.TraceServer(tracer, "DELETE /reservations/{id}")(MakeReleaseReservationEndpoint(s)),
+		ReplayChangesEndpoint:      opentracing.TraceServer(tracer, "POST /changes/replay")(MakeReplayChangesEndpoint(s)),
+		HealthEndpoint:             opentracing.TraceServer(tracer, "GET /health")(MakeHealthEndpoint(s)),
 	}
 }
 
@@ -41,8 +99,8 @@ func MakeEndpoints(s Service, tracer stdopentracing.Tracer) Endpoints {
 func MakeListEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(listRequest)
-		products, err := s.List(req.Categories, req.Order, req.PageNum, req.PageSize)
-		return listResponse{Products: products, Err: err}, err
+		products, next, err := s.List(ctx, req.Filter, req.Order, req.Cursor, req.Currency, req.Languages, req.PageNum, req.PageSize)
+		return listResponse{Products: products, NextCursor: next, Envelope: req.Envelope, Err: err}, err
 	}
 }
 
@@ -50,50 +108,314 @@ func MakeListEndpoint(s Service) endpoint.Endpoint {
 func MakeCountEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(countRequest)
-		n, err := s.Count(req.Categories)
+		n, err := s.Count(ctx, req.Filter)
 		return countResponse{N: n, Err: err}, err
 	}
 }
 
+// MakeFacetsEndpoint returns an endpoint via the given service.
+func MakeFacetsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(facetsRequest)
+		facets, err := s.Facets(ctx, req.Filter)
+		return facetsResponse{Facets: facets, Err: err}, err
+	}
+}
+
+// MakeSearchEndpoint returns an endpoint via the given service.
+func MakeSearchEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(searchRequest)
+		products, err := s.Search(ctx, req.Query, req.Categories, req.Languages, req.PageNum, req.PageSize)
+		return searchResponse{Products: products, Err: err}, err
+	}
+}
+
+// MakeSuggestEndpoint returns an endpoint via the given service.
+func MakeSuggestEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(suggestRequest)
+		suggestions, err := s.Suggest(ctx, req.Query, req.Size)
+		return suggestResponse{Suggestions: suggestions, Err: err}, err
+	}
+}
+
 // MakeGetEndpoint returns an endpoint via the given service.
 func MakeGetEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(getRequest)
-		product, err := s.Get(req.ID)
+		product, err := s.Get(ctx, req.ID, req.Currency, req.Languages)
 		return getResponse{Product: product, Err: err}, err
 	}
 }
 
+// MakeBatchEndpoint returns an endpoint via the given service.
+func MakeBatchEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(batchRequest)
+		products, missing, err := s.Batch(ctx, req.IDs, req.Currency, req.Languages)
+		return batchResponse{Products: products, Missing: missing, Err: err}, err
+	}
+}
+
+// MakeRelatedEndpoint returns an endpoint via the given service.
+func MakeRelatedEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(relatedRequest)
+		products, err := s.Related(ctx, req.ID, req.Currency, req.Languages, req.Size)
+		return relatedResponse{Products: products, Err: err}, err
+	}
+}
+
+// MakeCreateEndpoint returns an endpoint via the given service.
+func MakeCreateEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(createRequest)
+		product, err := s.Create(ctx, req.Product)
+		return createResponse{Product: product, Err: err}, err
+	}
+}
+
+// MakeUpdateEndpoint returns an endpoint via the given service.
+func MakeUpdateEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(updateRequest)
+		product, err := s.Update(ctx, req.Product)
+		return updateResponse{Product: product, Err: err}, err
+	}
+}
+
+// MakeDeleteEndpoint returns an endpoint via the given service.
+func MakeDeleteEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(deleteRequest)
+		err = s.Delete(ctx, req.ID, req.Version)
+		return deleteResponse{Err: err}, err
+	}
+}
+
+// MakeImportEndpoint returns an endpoint via the given service.
+func MakeImportEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(importRequest)
+		report, err := s.Import(ctx, req.Products, req.DryRun)
+		return importResponse{Report: report, Err: err}, err
+	}
+}
+
+// MakeExportEndpoint returns an endpoint via the given service.
+func MakeExportEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(exportRequest)
+		products, err := s.Export(ctx)
+		return exportResponse{Products: products, Format: req.Format, Err: err}, err
+	}
+}
+
+// MakeTranslationsEndpoint returns an endpoint via the given service.
+func MakeTranslationsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(translationsRequest)
+		translations, err := s.Translations(ctx, req.ID)
+		return translationsResponse{Translations: translations, Err: err}, err
+	}
+}
+
+// MakeSetTranslationsEndpoint returns an endpoint via the given service.
+func MakeSetTranslationsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(setTranslationsRequest)
+		translations, err := s.SetTranslations(ctx, req.Translations)
+		return translationsResponse{Translations: translations, Err: err}, err
+	}
+}
+
+// MakeDeleteTranslationEndpoint returns an endpoint via the given service.
+func MakeDeleteTranslationEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(deleteTranslationRequest)
+		err = s.DeleteTranslation(ctx, req.ID, req.Locale)
+		return deleteResponse{Err: err}, err
+	}
+}
+
 // MakeCategoriesEndpoint returns an endpoint via the given service.
 func MakeCategoriesEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
-		categories, err := s.Categories()
+		categories, err := s.Categories(ctx)
 		return categoriesResponse{Categories: categories, Err: err}, err
 	}
 }
 
+// MakeCategoryTreeEndpoint returns an endpoint via the given service.
+func MakeCategoryTreeEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		categories, err := s.CategoryTree(ctx)
+		return categoryTreeResponse{Categories: categories, Err: err}, err
+	}
+}
+
+// MakeRatesEndpoint returns an endpoint via the given service.
+func MakeRatesEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		rates, err := s.Rates(ctx)
+		return ratesResponse{Rates: rates, Err: err}, err
+	}
+}
+
+// MakeSetRatesEndpoint returns an endpoint via the given service.
+func MakeSetRatesEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(setRatesRequest)
+		rates, err := s.SetRates(ctx, req.Rates)
+		return ratesResponse{Rates: rates, Err: err}, err
+	}
+}
+
+// MakeSetCoViewsEndpoint returns an endpoint via the given service.
+func MakeSetCoViewsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(setCoViewsRequest)
+		err = s.SetCoViews(ctx, req.CoViews)
+		return setCoViewsResponse{Err: err}, err
+	}
+}
+
+// MakePromotionsEndpoint returns an endpoint via the given service.
+func MakePromotionsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		promotions, err := s.Promotions(ctx)
+		return promotionsResponse{Promotions: promotions, Err: err}, err
+	}
+}
+
+// MakeCreatePromotionEndpoint returns an endpoint via the given service.
+func MakeCreatePromotionEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(createPromotionRequest)
+		promotion, err := s.CreatePromotion(ctx, req.Promotion)
+		return promotionResponse{Promotion: promotion, Err: err}, err
+	}
+}
+
+// MakeExpirePromotionEndpoint returns an endpoint via the given service.
+func MakeExpirePromotionEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(expirePromotionRequest)
+		promotion, err := s.ExpirePromotion(ctx, req.ID)
+		return promotionResponse{Promotion: promotion, Err: err}, err
+	}
+}
+
+// MakePriceHistoryEndpoint returns an endpoint via the given service.
+func MakePriceHistoryEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(priceHistoryRequest)
+		prices, err := s.PriceHistory(ctx, req.ID)
+		return priceHistoryResponse{Prices: prices, Err: err}, err
+	}
+}
+
+// MakeReviewsEndpoint returns an endpoint via the given service.
+func MakeReviewsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(reviewsRequest)
+		reviews, err := s.Reviews(ctx, req.ID, req.PageNum, req.PageSize)
+		return reviewsResponse{Reviews: reviews, Err: err}, err
+	}
+}
+
+// MakeCreateReviewEndpoint returns an endpoint via the given service.
+func MakeCreateReviewEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(createReviewRequest)
+		review, err := s.CreateReview(ctx, req.Review)
+		return reviewResponse{Review: review, Err: err}, err
+	}
+}
+
+// MakeListReviewsEndpoint returns an endpoint via the given service.
+func MakeListReviewsEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(listReviewsRequest)
+		reviews, err := s.ListReviews(ctx, req.Status, req.PageNum, req.PageSize)
+		return reviewsResponse{Reviews: reviews, Err: err}, err
+	}
+}
+
+// MakeModerateReviewEndpoint returns an endpoint via the given service.
+func MakeModerateReviewEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(moderateReviewRequest)
+		review, err := s.ModerateReview(ctx, req.ID, req.Status)
+		return reviewResponse{Review: review, Err: err}, err
+	}
+}
+
+// MakeReserveEndpoint returns an endpoint via the given service.
+func MakeReserveEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(reserveRequest)
+		reservation, err := s.Reserve(ctx, req.Reservation)
+		return reservationResponse{Reservation: reservation, Err: err}, err
+	}
+}
+
+// MakeCommitReservationEndpoint returns an endpoint via the given service.
+func MakeCommitReservationEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(reservationRequest)
+		reservation, err := s.CommitReservation(ctx, req.ID)
+		return reservationResponse{Reservation: reservation, Err: err}, err
+	}
+}
+
+// MakeReleaseReservationEndpoint returns an endpoint via the given service.
+func MakeReleaseReservationEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(reservationRequest)
+		_, err = s.ReleaseReservation(ctx, req.ID)
+		return deleteResponse{Err: err}, err
+	}
+}
+
+// MakeReplayChangesEndpoint returns an endpoint via the given service.
+func MakeReplayChangesEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(replayChangesRequest)
+		replayed, err := s.ReplayChanges(ctx, req.From)
+		return replayChangesResponse{Replayed: replayed, Err: err}, err
+	}
+}
+
 // MakeHealthEndpoint returns current health of the given service.
 func MakeHealthEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
-		health := s.Health()
+		health := s.Health(ctx)
 		return healthResponse{Health: health}, nil
 	}
 }
 
 type listRequest struct {
-	Categories []string `json:"categories"`
-	Order      string   `json:"order"`
-	PageNum    int      `json:"pageNum"`
-	PageSize   int      `json:"pageSize"`
+	Filter    Filter   `json:"filter"`
+	Order     string   `json:"order"`
+	Cursor    string   `json:"cursor"`
+	Currency  string   `json:"currency"`
+	Languages []string `json:"languages"`
+	PageNum   int      `json:"pageNum"`
+	PageSize  int      `json:"pageSize"`
+	Envelope  bool     `json:"envelope"`
 }
 
 type listResponse struct {
-	Products []Product `json:"product"`
-	Err      error     `json:"err"`
+	Products   []Product `json:"product"`
+	NextCursor string    `json:"next_cursor"`
+	Envelope   bool      `json:"-"`
+	Err        error     `json:"err"`
 }
 
 type countRequest struct {
-	Categories []string `json:"categories"`
+	Filter Filter `json:"filter"`
 }
 
 type countResponse struct {
@@ -101,8 +423,42 @@ type countResponse struct {
 	Err error `json:"err"`
 }
 
+type facetsRequest struct {
+	Filter Filter `json:"filter"`
+}
+
+type facetsResponse struct {
+	Facets Facets `json:"facets"`
+	Err    error  `json:"err"`
+}
+
+type searchRequest struct {
+	Query      string   `json:"q"`
+	Categories []string `json:"categories"`
+	Languages  []string `json:"languages"`
+	PageNum    int      `json:"pageNum"`
+	PageSize   int      `json:"pageSize"`
+}
+
+type searchResponse struct {
+	Products []Product `json:"product"`
+	Err      error     `json:"err"`
+}
+
+type suggestRequest struct {
+	Query string `json:"q"`
+	Size  int    `json:"size"`
+}
+
+type suggestResponse struct {
+	Suggestions []Suggestion `json:"suggestions"`
+	Err         error        `json:"err"`
+}
+
 type getRequest struct {
-	ID string `json:"id"`
+	ID        string   `json:"id"`
+	Currency  string   `json:"currency"`
+	Languages []string `json:"languages"`
 }
 
 type getResponse struct {
@@ -110,6 +466,95 @@ type getResponse struct {
 	Err     error   `json:"err"`
 }
 
+type batchRequest struct {
+	IDs       []string `json:"ids"`
+	Currency  string   `json:"currency"`
+	Languages []string `json:"languages"`
+}
+
+type batchResponse struct {
+	Products []Product `json:"products"`
+	Missing  []string  `json:"missing"`
+	Err      error     `json:"-"`
+}
+
+type relatedRequest struct {
+	ID        string   `json:"id"`
+	Currency  string   `json:"currency"`
+	Languages []string `json:"languages"`
+	Size      int      `json:"size"`
+}
+
+type relatedResponse struct {
+	Products []Product `json:"product"`
+	Err      error     `json:"err"`
+}
+
+type createRequest struct {
+	Product Product `json:"product"`
+}
+
+type createResponse struct {
+	Product Product `json:"product"`
+	Err     error   `json:"err"`
+}
+
+type updateRequest struct {
+	Product Product `json:"product"`
+}
+
+type updateResponse struct {
+	Product Product `json:"product"`
+	Err     error   `json:"err"`
+}
+
+type deleteRequest struct {
+	ID      string `json:"id"`
+	Version int    `json:"version"`
+}
+
+type deleteResponse struct {
+	Err error `json:"err"`
+}
+
+type importRequest struct {
+	Products []Product `json:"products"`
+	DryRun   bool      `json:"dryRun"`
+}
+
+type importResponse struct {
+	Report ImportReport `json:"report"`
+	Err    error        `json:"err"`
+}
+
+type exportRequest struct {
+	Format string `json:"format"`
+}
+
+type exportResponse struct {
+	Products []Product `json:"products"`
+	Format   string    `json:"-"`
+	Err      error     `json:"err"`
+}
+
+type translationsRequest struct {
+	ID string `json:"id"`
+}
+
+type setTranslationsRequest struct {
+	Translations []Translation `json:"translations"`
+}
+
+type translationsResponse struct {
+	Translations []Translation `json:"translations"`
+	Err          error         `json:"err"`
+}
+
+type deleteTranslationRequest struct {
+	ID     string `json:"id"`
+	Locale string `json:"locale"`
+}
+
 type categoriesRequest struct {
 	//
 }
@@ -119,6 +564,108 @@ type categoriesResponse struct {
 	Err        error    `json:"err"`
 }
 
+type categoryTreeResponse struct {
+	Categories []Category `json:"categories"`
+	Err        error      `json:"err"`
+}
+
+type setRatesRequest struct {
+	Rates Rates `json:"rates"`
+}
+
+type ratesResponse struct {
+	Rates Rates `json:"rates"`
+	Err   error `json:"err"`
+}
+
+type setCoViewsRequest struct {
+	CoViews CoViews `json:"coViews"`
+}
+
+type setCoViewsResponse struct {
+	Err error `json:"err"`
+}
+
+type promotionsResponse struct {
+	Promotions []Promotion `json:"promotions"`
+	Err        error       `json:"err"`
+}
+
+type createPromotionRequest struct {
+	Promotion Promotion `json:"promotion"`
+}
+
+type expirePromotionRequest struct {
+	ID string `json:"id"`
+}
+
+type promotionResponse struct {
+	Promotion Promotion `json:"promotion"`
+	Err       error     `json:"err"`
+}
+
+type priceHistoryRequest struct {
+	ID string `json:"id"`
+}
+
+type priceHistoryResponse struct {
+	Prices []PriceChange `json:"prices"`
+	Err    error         `json:"err"`
+}
+
+type reviewsRequest struct {
+	ID       string `json:"id"`
+	PageNum  int    `json:"pageNum"`
+	PageSize int    `json:"pageSize"`
+}
+
+type reviewsResponse struct {
+	Reviews []Review `json:"reviews"`
+	Err     error    `json:"err"`
+}
+
+type createReviewRequest struct {
+	Review Review `json:"review"`
+}
+
+type reviewResponse struct {
+	Review Review `json:"review"`
+	Err    error  `json:"err"`
+}
+
+type listReviewsRequest struct {
+	Status   string `json:"status"`
+	PageNum  int    `json:"pageNum"`
+	PageSize int    `json:"pageSize"`
+}
+
+type moderateReviewRequest struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+type reserveRequest struct {
+	Reservation Reservation `json:"reservation"`
+}
+
+type reservationRequest struct {
+	ID string `json:"id"`
+}
+
+type reservationResponse struct {
+	Reservation Reservation `json:"reservation"`
+	Err         error       `json:"err"`
+}
+
+type replayChangesRequest struct {
+	From int64 `json:"from"`
+}
+
+type replayChangesResponse struct {
+	Replayed int   `json:"replayed"`
+	Err      error `json:"-"`
+}
+
 type healthRequest struct {
 	//
 }
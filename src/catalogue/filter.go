@@ -0,0 +1,229 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// filter.go contains the product filters shared by List, Count and Facets, and
+// their translation into SQL conditions.
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Filter narrows down the products of the catalogue. Empty fields do not
+// filter; values within a field are alternatives, fields are combined. The
+// exception are categories: with MatchAll set, products must be in all of
+// them.
+//
+// MinPrice and MaxPrice are in Currency, as are the price buckets of Facets
+// and the prices products are sorted by: the base currency of the exchange
+// rates when empty.
+type Filter struct {
+	Categories []string `json:"categories"`
+	MatchAll   bool     `json:"matchAll,omitempty"`
+	MinPrice   *float64 `json:"minPrice,omitempty"`
+	MaxPrice   *float64 `json:"maxPrice,omitempty"`
+	Currency   string   `json:"currency,omitempty"`
+	Brands     []string `json:"brand,omitempty"`
+	Colors     []string `json:"color,omitempty"`
+	Sizes      []string `json:"product_size,omitempty"`
+
+	// factors convert prices to Currency, by the currency they are in, as
+	// the service sets them from its exchange rates. Products in other
+	// currencies have no price to filter and bucket by, and sort as priced
+	// 0. Prices are compared as they are without factors.
+	factors map[string]float64
+}
+
+// price returns the price of a product in the currency of the filter, or
+// false if there is no factor for its currency.
+func (f Filter) price(p Product) (float64, bool) {
+	// A float32 price is the decimal it was written as, the shortest that
+	// reads back as the same float32, which is what PostgreSQL converts
+	// the NUMERIC price to float8 as.
+	price, _ := strconv.ParseFloat(strconv.FormatFloat(float64(p.Price), 'f', -1, 32), 64)
+	if f.factors == nil {
+		return price, true
+	}
+	factor, ok := f.factors[p.Currency]
+	if !ok {
+		return 0, false
+	}
+	return price * factor, true
+}
+
+// Facets counts the products matching a filter per value of the attributes
+// they can be filtered by. Each attribute is counted as if it was not
+// filtered on itself, so that the alternatives to a selected value remain.
+type Facets struct {
+	Brands     []FacetCount  `json:"brands"`
+	Colors     []FacetCount  `json:"colors"`
+	Categories []FacetCount  `json:"categories"`
+	Prices     []PriceBucket `json:"prices"`
+}
+
+// FacetCount is the number of products having a value.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// PriceBucket is the number of products priced from Min up to, but not
+// including, Max. The last bucket has no Max.
+type PriceBucket struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max,omitempty"`
+	Count int      `json:"count"`
+}
+
+// priceBounds are the boundaries between the price buckets of Facets.
+var priceBounds = []float64{10, 25, 50, 100}
+
+// newPriceBuckets returns the empty price buckets of Facets.
+func newPriceBuckets() []PriceBucket {
+	buckets := make([]PriceBucket, len(priceBounds)+1)
+	for i := range buckets {
+		if i > 0 {
+			buckets[i].Min = priceBounds[i-1]
+		}
+		if i < len(priceBounds) {
+			buckets[i].Max = &priceBounds[i]
+		}
+	}
+	return buckets
+}
+
+// splitList is the SQL splitting the free-form, comma separated attribute
+// lists of products (colors, product_size) into rows.
+const splitList = `regexp_split_to_table(%s, '\s*,\s*')`
+
+// conditions collects the SQL conditions of a WHERE clause together with the
+// arguments of their placeholders.
+type conditions struct {
+	clauses []string
+	args    []interface{}
+}
+
+// add appends a condition, in which each "?" is a placeholder for the next
+// of the given arguments.
+func (c *conditions) add(clause string, args ...interface{}) {
+	for _, arg := range args {
+		c.args = append(c.args, arg)
+		clause = strings.Replace(clause, "?", fmt.Sprintf("$%d", len(c.args)), 1)
+	}
+	c.clauses = append(c.clauses, clause)
+}
+
+// arg appends an argument that is not part of a condition, e.g. for LIMIT,
+// and returns its placeholder.
+func (c *conditions) arg(arg interface{}) string {
+	c.args = append(c.args, arg)
+	return fmt.Sprintf("$%d", len(c.args))
+}
+
+// where returns the WHERE clause of the conditions, if there are any.
+func (c *conditions) where() string {
+	if len(c.clauses) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(c.clauses, " AND ")
+}
+
+// priceColumn returns the price of products in the currency of the filter,
+// as Filter.price computes it, which is NULL for those in currencies without
+// a factor.
+func (c *conditions) priceColumn(f Filter) string {
+	if f.factors == nil {
+		return "products.price"
+	}
+	currencies := make([]string, 0, len(f.factors))
+	for currency := range f.factors {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	factors := make([]float64, len(currencies))
+	for i, currency := range currencies {
+		factors[i] = f.factors[currency]
+	}
+	return fmt.Sprintf("(products.price::float8 * (SELECT rate.factor FROM unnest(%s::text[], %s::float8[]) rate(currency, factor) WHERE rate.currency = products.currency))", c.arg(pq.Array(currencies)), c.arg(pq.Array(factors)))
+}
+
+// addAttributes adds the conditions for the filters on product attributes,
+// that is everything but the categories.
+func (c *conditions) addAttributes(f Filter) {
+	if f.MinPrice != nil || f.MaxPrice != nil {
+		price := c.priceColumn(f)
+		if f.MinPrice != nil {
+			c.add(price+" >= ?", *f.MinPrice)
+		}
+		if f.MaxPrice != nil {
+			c.add(price+" <= ?", *f.MaxPrice)
+		}
+	}
+	if len(f.Brands) > 0 {
+		c.add("products.brand = ANY(?)", pq.Array(f.Brands))
+	}
+	var colors, sizes string
+	if len(f.Colors) > 0 {
+		colors = c.arg(pq.Array(lower(f.Colors)))
+		c.add("EXISTS (SELECT 1 FROM " + fmt.Sprintf(splitList, "products.colors") + " color WHERE lower(color) = ANY(" + colors + "))")
+	}
+	if len(f.Sizes) > 0 {
+		sizes = c.arg(pq.Array(lower(f.Sizes)))
+		c.add("EXISTS (SELECT 1 FROM " + fmt.Sprintf(splitList, "products.product_size") + " size WHERE lower(size) = ANY(" + sizes + "))")
+	}
+	// The colors and sizes of products with variants are those of their
+	// variants, of which one must have both.
+	if colors != "" && sizes != "" {
+		c.add("(NOT EXISTS (SELECT 1 FROM product_variant WHERE product_variant.product_sku = products.sku) OR EXISTS (SELECT 1 FROM product_variant WHERE product_variant.product_sku = products.sku AND lower(product_variant.color) = ANY(" + colors + ") AND lower(product_variant.size) = ANY(" + sizes + ")))")
+	}
+}
+
+// categoryProducts selects the SKUs of the products in any of the categories
+// given by a placeholder, or in their descendants: those of the subtree of a
+// category have it as their root.
+const categoryProducts = "SELECT product_category.sku FROM product_category JOIN (WITH RECURSIVE subtree (category_id, root) AS (SELECT category_id, name FROM categories WHERE name = ANY(?) UNION SELECT categories.category_id, subtree.root FROM categories JOIN subtree ON categories.parent_id = subtree.category_id) SELECT category_id, root FROM subtree) subtree ON product_category.category_id = subtree.category_id"
+
+// addCategories adds the condition for the category filter. Matching all
+// categories means a product is in as many distinct subtrees among those
+// wanted as there are wanted.
+func (c *conditions) addCategories(f Filter) {
+	categories := distinct(f.Categories)
+	switch {
+	case len(categories) == 0:
+	case f.MatchAll && len(categories) > 1:
+		c.add("products.sku IN ("+categoryProducts+" GROUP BY product_category.sku HAVING COUNT(DISTINCT subtree.root) = ?)", pq.Array(categories), len(categories))
+	default:
+		c.add("products.sku IN ("+categoryProducts+")", pq.Array(categories))
+	}
+}
+
+// distinct returns the non-blank values, trimmed and without duplicates.
+func distinct(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		result = append(result, v)
+	}
+	return result
+}
+
+func lower(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, v := range values {
+		lowered[i] = strings.ToLower(strings.TrimSpace(v))
+	}
+	return lowered
+}
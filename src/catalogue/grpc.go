@@ -0,0 +1,287 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// grpc.go contains the binding from the read endpoints to gRPC, for the
+// services that would rather not go through the REST-y HTTP transport.
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/tracing/opentracing"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	"github.com/sony/gobreaker"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"mushop/catalogue/pb"
+)
+
+// HealthChecker keeps the status of the Catalogue service on a gRPC health
+// server up to date with Service.Health, which the HTTP transport serves on
+// /health: it is not serving while any of the checks fails, such as that of
+// the database.
+type HealthChecker struct {
+	service Service
+	server  *health.Server
+	logger  log.Logger
+
+	// Interval is the time between checks.
+	Interval time.Duration
+}
+
+// NewHealthChecker returns a checker setting the status of the Catalogue
+// service on the health server.
+func NewHealthChecker(service Service, server *health.Server, logger log.Logger) *HealthChecker {
+	return &HealthChecker{
+		service:  service,
+		server:   server,
+		logger:   logger,
+		Interval: 10 * time.Second,
+	}
+}
+
+// Run checks the health of the service until the context is done.
+func (c *HealthChecker) Run(ctx context.Context) {
+	last := healthpb.HealthCheckResponse_UNKNOWN
+	for {
+		if status := c.Check(ctx); status != last {
+			c.logger.Log("health", status)
+			last = status
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.Interval):
+		}
+	}
+}
+
+// Check checks the health of the service once, sets its status and returns
+// it.
+func (c *HealthChecker) Check(ctx context.Context) healthpb.HealthCheckResponse_ServingStatus {
+	status := healthpb.HealthCheckResponse_SERVING
+	for _, h := range c.service.Health(ctx) {
+		if h.Status != "OK" {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+	}
+	c.server.SetServingStatus(pb.Catalogue_ServiceDesc.ServiceName, status)
+	return status
+}
+
+type grpcServer struct {
+	pb.UnimplementedCatalogueServer
+	list       grpctransport.Handler
+	count      grpctransport.Handler
+	get        grpctransport.Handler
+	categories grpctransport.Handler
+}
+
+// MakeGRPCServer makes the List, Count, Get and Categories endpoints
+// available as a gRPC Catalogue server. Their errors are told by status code,
+// as the HTTP transport tells them by status.
+func MakeGRPCServer(e Endpoints, logger log.Logger, tracer stdopentracing.Tracer) pb.CatalogueServer {
+	options := []grpctransport.ServerOption{
+		grpctransport.ServerErrorLogger(logger),
+	}
+	handler := func(name, method string, e endpoint.Endpoint, dec grpctransport.DecodeRequestFunc, enc grpctransport.EncodeResponseFunc) grpctransport.Handler {
+		return grpctransport.NewServer(
+			breaker(name)(e),
+			dec,
+			enc,
+			append(options, grpctransport.ServerBefore(opentracing.GRPCToContext(tracer, method, logger)))...,
+		)
+	}
+	return &grpcServer{
+		list:       handler("List", pb.Catalogue_List_FullMethodName, e.ListEndpoint, decodeGRPCListRequest, encodeGRPCListResponse),
+		count:      handler("Count", pb.Catalogue_Count_FullMethodName, e.CountEndpoint, decodeGRPCCountRequest, encodeGRPCCountResponse),
+		get:        handler("Get", pb.Catalogue_Get_FullMethodName, e.GetEndpoint, decodeGRPCGetRequest, encodeGRPCGetResponse),
+		categories: handler("Categories", pb.Catalogue_Categories_FullMethodName, e.CategoriesEndpoint, decodeGRPCCategoriesRequest, encodeGRPCCategoriesResponse),
+	}
+}
+
+func (s *grpcServer) List(ctx context.Context, req *pb.ListRequest) (*pb.ListResponse, error) {
+	_, rep, err := s.list.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.ListResponse), nil
+}
+
+func (s *grpcServer) Count(ctx context.Context, req *pb.CountRequest) (*pb.CountResponse, error) {
+	_, rep, err := s.count.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.CountResponse), nil
+}
+
+func (s *grpcServer) Get(ctx context.Context, req *pb.GetRequest) (*pb.Product, error) {
+	_, rep, err := s.get.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.Product), nil
+}
+
+func (s *grpcServer) Categories(ctx context.Context, req *pb.CategoriesRequest) (*pb.CategoriesResponse, error) {
+	_, rep, err := s.categories.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.CategoriesResponse), nil
+}
+
+// grpcError returns the status of an error, by the code matching the HTTP
+// status encodeError replies with.
+func grpcError(err error) error {
+	code := codes.Internal
+	switch err {
+	case ErrNotFound:
+		code = codes.NotFound
+	case ErrInvalidSort, ErrInvalidCursor, ErrUnknownCurrency, errBadRequest:
+		code = codes.InvalidArgument
+	case ErrDBConnection, gobreaker.ErrOpenState, gobreaker.ErrTooManyRequests:
+		code = codes.Unavailable
+	case context.DeadlineExceeded:
+		code = codes.DeadlineExceeded
+	case context.Canceled:
+		code = codes.Canceled
+	}
+	return status.Error(code, err.Error())
+}
+
+// decodeGRPCListRequest applies the defaults of GET /catalogue: the first
+// page of 10 products by ID.
+func decodeGRPCListRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.ListRequest)
+	pageNum, pageSize := int(req.PageNum), int(req.PageSize)
+	if pageNum == 0 {
+		pageNum = 1
+	}
+	if pageSize == 0 {
+		pageSize = 10
+	}
+	order := "id"
+	if req.Order != "" {
+		order = strings.ToLower(req.Order)
+	}
+	return listRequest{
+		Filter:    decodeGRPCFilter(req.Filter),
+		Order:     order,
+		Cursor:    req.Cursor,
+		Currency:  strings.ToUpper(req.Currency),
+		Languages: req.Languages,
+		PageNum:   pageNum,
+		PageSize:  pageSize,
+	}, nil
+}
+
+func decodeGRPCCountRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.CountRequest)
+	return countRequest{Filter: decodeGRPCFilter(req.Filter)}, nil
+}
+
+func decodeGRPCGetRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.GetRequest)
+	return getRequest{
+		ID:        req.Id,
+		Currency:  strings.ToUpper(req.Currency),
+		Languages: req.Languages,
+	}, nil
+}
+
+func decodeGRPCCategoriesRequest(_ context.Context, _ interface{}) (interface{}, error) {
+	return struct{}{}, nil
+}
+
+func decodeGRPCFilter(f *pb.Filter) Filter {
+	filter := Filter{
+		Categories: []string{},
+	}
+	if f == nil {
+		return filter
+	}
+	if len(f.Categories) > 0 {
+		filter.Categories = f.Categories
+	}
+	filter.MatchAll = f.MatchAll
+	filter.MinPrice, filter.MaxPrice = f.MinPrice, f.MaxPrice
+	filter.Brands, filter.Colors, filter.Sizes = f.Brands, f.Colors, f.Sizes
+	return filter
+}
+
+func encodeGRPCListResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(listResponse)
+	products := make([]*pb.Product, len(resp.Products))
+	for i, p := range resp.Products {
+		products[i] = encodeGRPCProduct(p)
+	}
+	return &pb.ListResponse{Products: products, NextCursor: resp.NextCursor}, nil
+}
+
+func encodeGRPCCountResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(countResponse)
+	return &pb.CountResponse{Size: int32(resp.N)}, nil
+}
+
+func encodeGRPCGetResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(getResponse)
+	return encodeGRPCProduct(resp.Product), nil
+}
+
+func encodeGRPCCategoriesResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(categoriesResponse)
+	return &pb.CategoriesResponse{Categories: resp.Categories}, nil
+}
+
+func encodeGRPCProduct(p Product) *pb.Product {
+	variants := make([]*pb.Variant, len(p.Variants))
+	for i, v := range p.Variants {
+		variants[i] = &pb.Variant{
+			Id:             v.ID,
+			Color:          v.Color,
+			Size:           v.Size,
+			PriceDelta:     v.PriceDelta,
+			Price:          v.Price,
+			FormattedPrice: v.FormattedPrice,
+			Qty:            int32(v.Qty),
+			ImageUrl:       v.ImageURL,
+		}
+	}
+	return &pb.Product{
+		Id:                 p.ID,
+		Brand:              p.Brand,
+		Title:              p.Title,
+		Description:        p.Description,
+		Weight:             p.Weight,
+		ProductSize:        p.ProductSize,
+		Colors:             p.Colors,
+		Qty:                int32(p.Qty),
+		Available:          int32(p.Available),
+		Rating:             p.Rating,
+		ReviewCount:        int32(p.ReviewCount),
+		Price:              p.Price,
+		Currency:           p.Currency,
+		FormattedPrice:     p.FormattedPrice,
+		SalePrice:          p.SalePrice,
+		FormattedSalePrice: p.FormattedSalePrice,
+		PromotionId:        p.PromotionID,
+		Locale:             p.Locale,
+		ImageUrl:           p.ImageURL,
+		Categories:         p.Categories,
+		Variants:           variants,
+		Version:            int32(p.Version),
+	}
+}
@@ -0,0 +1,114 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+package catalogue
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	kitgrpc "github.com/go-kit/kit/transport/grpc"
+	stdopentracing "github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"mushop/catalogue/pb"
+)
+
+func newTestGRPCClient(t *testing.T, s Service) pb.CatalogueClient {
+	ln := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.UnaryInterceptor(kitgrpc.Interceptor))
+	pb.RegisterCatalogueServer(server, MakeGRPCServer(MakeEndpoints(s, stdopentracing.NoopTracer{}), log.NewNopLogger(), stdopentracing.NoopTracer{}))
+	go server.Serve(ln)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return ln.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewCatalogueClient(conn)
+}
+
+func TestGRPC(t *testing.T) {
+	client := newTestGRPCClient(t, newTestMemoryService(t))
+	ctx := context.Background()
+
+	maxPrice := 10.0
+	list, err := client.List(ctx, &pb.ListRequest{Filter: &pb.Filter{Categories: []string{"Bowls", "Toys"}, MaxPrice: &maxPrice}, Order: "-price"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, p := range list.Products {
+		ids = append(ids, p.Id)
+	}
+	if want := []string{"A", "B"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("List: want %v, have %v", want, ids)
+	}
+	if n, err := client.Count(ctx, &pb.CountRequest{}); err != nil || n.Size != 4 {
+		t.Errorf("Count: want 4, have %v, %v", n, err)
+	}
+	if c, err := client.Categories(ctx, &pb.CategoriesRequest{}); err != nil || len(c.Categories) != 3 {
+		t.Errorf("Categories: want 3, have %v, %v", c, err)
+	}
+
+	p, err := client.Get(ctx, &pb.GetRequest{Id: "C"})
+	if err != nil || p.Title != "Dry food" || p.Price != 25 || !reflect.DeepEqual(p.Categories, []string{"Food", "Bowls"}) {
+		t.Errorf("Get(C): have %v, %v", p, err)
+	}
+	for _, tc := range []struct {
+		req  *pb.GetRequest
+		want codes.Code
+	}{
+		{&pb.GetRequest{Id: "E"}, codes.NotFound},
+		{&pb.GetRequest{Id: "A", Currency: "xyz"}, codes.InvalidArgument},
+	} {
+		if _, err := client.Get(ctx, tc.req); status.Code(err) != tc.want {
+			t.Errorf("Get(%v): want %v, have %v", tc.req, tc.want, err)
+		}
+	}
+	if _, err := client.List(ctx, &pb.ListRequest{Order: "nope"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("List by unknown order: want %v, have %v", codes.InvalidArgument, err)
+	}
+}
+
+// unhealthyService fails the health check of its store.
+type unhealthyService struct {
+	Service
+}
+
+func (s unhealthyService) Health(ctx context.Context) []Health {
+	return []Health{{"catalogue", "OK", ""}, {"postgres:catalogue-data", "err", ""}}
+}
+
+func TestHealthChecker(t *testing.T) {
+	ctx := context.Background()
+	server := health.NewServer()
+	request := &healthpb.HealthCheckRequest{Service: pb.Catalogue_ServiceDesc.ServiceName}
+
+	for _, tc := range []struct {
+		service Service
+		want    healthpb.HealthCheckResponse_ServingStatus
+	}{
+		{newTestMemoryService(t), healthpb.HealthCheckResponse_SERVING},
+		{unhealthyService{newTestMemoryService(t)}, healthpb.HealthCheckResponse_NOT_SERVING},
+	} {
+		NewHealthChecker(tc.service, server, log.NewNopLogger()).Check(ctx)
+		resp, err := server.Check(ctx, request)
+		if err != nil || resp.Status != tc.want {
+			t.Errorf("Check: want %v, have %v, %v", tc.want, resp, err)
+		}
+	}
+}
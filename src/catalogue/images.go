@@ -0,0 +1,414 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// images.go contains the handler of the product images. It resizes them as
+// asked by query parameters, converts them to smaller formats the client
+// accepts, and keeps the results in a bounded cache on disk.
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif" // registers the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"golang.org/x/image/draw"
+)
+
+// maxImageDimension bounds the width and height images can be resized to.
+const maxImageDimension = 2048
+
+// imageTypes are the media types of the files served, by extension. Other
+// files in the image directory are not served.
+var imageTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+}
+
+// Ways to fit an image into the requested width and height.
+const (
+	fitContain = "contain" // scale to fit inside, keeping the aspect ratio
+	fitCover   = "cover"   // scale to cover, keeping the aspect ratio, and crop
+	fitFill    = "fill"    // stretch to the exact size
+)
+
+// ImageServer serves the images of a directory. The w and h query parameters
+// resize an image, and fit tells how: contain (default), cover or fill.
+// Opaque images are converted to JPEG for clients accepting it.
+type ImageServer struct {
+	dir    string
+	cache  *imageCache // nil when disabled
+	logger log.Logger
+}
+
+// NewImageServer returns a server of the images in dir. Resized and converted
+// images are kept in cacheDir, up to cacheSize bytes; a cacheSize of 0
+// disables the cache.
+func NewImageServer(dir, cacheDir string, cacheSize int64, logger log.Logger) (*ImageServer, error) {
+	s := &ImageServer{
+		dir:    dir,
+		logger: logger,
+	}
+	if cacheSize > 0 {
+		cache, err := newImageCache(cacheDir, cacheSize)
+		if err != nil {
+			return nil, err
+		}
+		s.cache = cache
+	}
+	return s, nil
+}
+
+// imageRequest is what a request asks of an image.
+type imageRequest struct {
+	name          string // slash separated, relative to the image directory
+	width, height int    // 0 when not constrained
+	fit           string
+}
+
+func (s *ImageServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, status := parseImageRequest(r)
+	if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	f, err := os.Open(filepath.Join(s.dir, filepath.FromSlash(req.name)))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+
+	source := imageTypes[strings.ToLower(path.Ext(req.name))]
+	format := negotiateImageType(r.Header.Get("Accept"), source)
+	w.Header().Set("Vary", "Accept")
+	if req.width == 0 && req.height == 0 && format == source {
+		http.ServeContent(w, r, req.name, info.ModTime(), f)
+		return
+	}
+
+	key := imageCacheKey(req, info, format)
+	if s.cache != nil {
+		if cached, ok := s.cache.open(key); ok {
+			defer cached.Close()
+			http.ServeContent(w, r, "", info.ModTime(), cached)
+			return
+		}
+	}
+
+	body, err := renderImage(f, req, format)
+	if err != nil {
+		s.logger.Log("image", req.name, "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if s.cache != nil {
+		if err := s.cache.put(key, body); err != nil {
+			s.logger.Log("image", req.name, "cache", "put", "err", err)
+		}
+	}
+	// The content type is sniffed, as the format may have fallen back.
+	http.ServeContent(w, r, "", info.ModTime(), bytes.NewReader(body))
+}
+
+// parseImageRequest reads the image name and the resize parameters, and
+// answers the status to reject the request with otherwise.
+func parseImageRequest(r *http.Request) (imageRequest, int) {
+	name := r.URL.Path
+	if strings.ContainsAny(name, "\\\x00") {
+		return imageRequest{}, http.StatusBadRequest
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == ".." {
+			return imageRequest{}, http.StatusBadRequest
+		}
+	}
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if _, ok := imageTypes[strings.ToLower(path.Ext(name))]; !ok {
+		return imageRequest{}, http.StatusForbidden
+	}
+
+	req := imageRequest{name: name, fit: fitContain}
+	for param, v := range map[string]*int{"w": &req.width, "h": &req.height} {
+		s := r.FormValue(param)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxImageDimension {
+			return imageRequest{}, http.StatusBadRequest
+		}
+		*v = n
+	}
+	switch fit := r.FormValue("fit"); fit {
+	case "":
+	case fitContain, fitCover, fitFill:
+		req.fit = fit
+	default:
+		return imageRequest{}, http.StatusBadRequest
+	}
+	return req, http.StatusOK
+}
+
+// negotiateImageType returns the type to encode an image of the source type
+// in: JPEG if the client accepts it at least as much as the source type, as
+// it is the smaller one for photos. Without an Accept header, or when the
+// client accepts neither, the source type is kept.
+func negotiateImageType(accept, source string) string {
+	if accept == "" || source == "image/jpeg" {
+		return source
+	}
+	q := acceptQualities(accept)
+	if jpegQ := q("image/jpeg"); jpegQ > 0 && jpegQ >= q(source) {
+		return "image/jpeg"
+	}
+	return source
+}
+
+// acceptQualities parses an Accept header into a function returning the
+// quality a media type is accepted with, considering wildcards.
+func acceptQualities(accept string) func(mediaType string) float64 {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		q := 1.0
+		for _, param := range params[1:] {
+			if kv := strings.SplitN(strings.TrimSpace(param), "=", 2); len(kv) == 2 && kv[0] == "q" {
+				if v, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		qualities[mediaType] = q
+	}
+	return func(mediaType string) float64 {
+		for _, candidate := range []string{mediaType, strings.SplitN(mediaType, "/", 2)[0] + "/*", "*/*"} {
+			if q, ok := qualities[candidate]; ok {
+				return q
+			}
+		}
+		return 0
+	}
+}
+
+func imageCacheKey(req imageRequest, info os.FileInfo, format string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%d\x00%d\x00%d\x00%s\x00%s",
+		req.name, info.ModTime().UnixNano(), info.Size(), req.width, req.height, req.fit, format)))
+	return hex.EncodeToString(sum[:])
+}
+
+// isImageCacheKey tells whether a file name is a key of imageCacheKey, which
+// the files of the image cache are named by. Others are left alone.
+func isImageCacheKey(name string) bool {
+	if len(name) != 2*sha256.Size || strings.ToLower(name) != name {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+// renderImage decodes, resizes and encodes an image. Images with transparent
+// pixels stay PNG, as JPEG has no transparency.
+func renderImage(r io.Reader, req imageRequest, format string) ([]byte, error) {
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	img := resizeImage(src, req.width, req.height, req.fit)
+
+	var buf bytes.Buffer
+	if o, ok := img.(interface{ Opaque() bool }); format == "image/jpeg" && ok && o.Opaque() {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80})
+	} else {
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, img)
+	}
+	return buf.Bytes(), err
+}
+
+// resizeImage scales an image to the given width and height, of which 0
+// means unconstrained. Contained images are not enlarged.
+func resizeImage(src image.Image, width, height int, fit string) image.Image {
+	if width == 0 && height == 0 {
+		return src
+	}
+	b := src.Bounds()
+	sw, sh := float64(b.Dx()), float64(b.Dy())
+	if width == 0 || height == 0 || fit == fitContain {
+		scale := math.Inf(1)
+		if width > 0 {
+			scale = float64(width) / sw
+		}
+		if height > 0 {
+			scale = math.Min(scale, float64(height)/sh)
+		}
+		scale = math.Min(scale, 1)
+		width = int(math.Max(1, math.Round(sw*scale)))
+		height = int(math.Max(1, math.Round(sh*scale)))
+	} else if fit == fitCover {
+		scale := math.Max(float64(width)/sw, float64(height)/sh)
+		cw, ch := int(math.Round(float64(width)/scale)), int(math.Round(float64(height)/scale))
+		x, y := b.Min.X+(b.Dx()-cw)/2, b.Min.Y+(b.Dy()-ch)/2
+		b = image.Rect(x, y, x+cw, y+ch)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+	return dst
+}
+
+// imageCache keeps encoded images in files named by their key, dropping the
+// least recently used ones beyond its size. It survives restarts. Files in
+// its directory not named by a key are not its own, and are left alone.
+type imageCache struct {
+	dir  string
+	size int64
+
+	mtx     sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // of *imageCacheEntry, most recently used first
+	used    int64      // bytes
+}
+
+type imageCacheEntry struct {
+	key  string
+	size int64
+}
+
+// newImageCache returns the cache in dir, creating the directory or picking
+// up the images, and removing the partial writes, left in it.
+func newImageCache(dir string, size int64) (*imageCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type file struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+	var files []file
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		name := e.Name()
+		if strings.HasPrefix(name, ".tmp-") && len(name) > 5+2*sha256.Size && isImageCacheKey(name[5:5+2*sha256.Size]) {
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		if isImageCacheKey(name) {
+			files = append(files, file{name, info.Size(), info.ModTime()})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+
+	c := &imageCache{
+		dir:     dir,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, f := range files {
+		c.entries[f.name] = c.lru.PushBack(&imageCacheEntry{f.name, f.size})
+		c.used += f.size
+	}
+	c.evict()
+	return c, nil
+}
+
+// open returns the cached image of a key, if there is one.
+func (c *imageCache) open(key string) (*os.File, bool) {
+	c.mtx.Lock()
+	e, ok := c.entries[key]
+	if ok {
+		c.lru.MoveToFront(e)
+	}
+	c.mtx.Unlock()
+	if !ok {
+		return nil, false
+	}
+	// The file may have been evicted since; that is a miss.
+	f, err := os.Open(filepath.Join(c.dir, key))
+	return f, err == nil
+}
+
+// put caches an image. Images larger than the whole cache are not kept.
+func (c *imageCache) put(key string, body []byte) error {
+	if int64(len(body)) > c.size {
+		return nil
+	}
+	tmp, err := os.CreateTemp(c.dir, ".tmp-"+key+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if err := os.Rename(tmp.Name(), filepath.Join(c.dir, key)); err != nil {
+		return err
+	}
+	if e, ok := c.entries[key]; ok {
+		c.used -= e.Value.(*imageCacheEntry).size
+		c.lru.Remove(e)
+	}
+	c.entries[key] = c.lru.PushFront(&imageCacheEntry{key, int64(len(body))})
+	c.used += int64(len(body))
+	c.evict()
+	return nil
+}
+
+// evict removes the least recently used images until the cache fits its
+// size. The caller holds the lock.
+func (c *imageCache) evict() {
+	for c.used > c.size {
+		e := c.lru.Back()
+		entry := e.Value.(*imageCacheEntry)
+		c.lru.Remove(e)
+		delete(c.entries, entry.key)
+		c.used -= entry.size
+		os.Remove(filepath.Join(c.dir, entry.key))
+	}
+}
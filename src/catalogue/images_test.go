@@ -0,0 +1,206 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+package catalogue
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+)
+
+// writeImages fills a directory with an opaque 40x20 image, a transparent
+// one, a text file and a directory named like an image.
+func writeImages(t *testing.T, dir string) {
+	for name, alpha := range map[string]uint8{"opaque.png": 0xff, "clear.png": 0x80} {
+		img := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+		for x := 0; x < 40; x++ {
+			for y := 0; y < 20; y++ {
+				img.Set(x, y, color.NRGBA{uint8(x * 6), uint8(y * 12), 0x80, alpha})
+			}
+		}
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := png.Encode(f, img); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "dir.png"), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func serveImage(s http.Handler, target, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/", nil)
+	// Bypass the cleaning of the path a router would do.
+	req.URL.Path, req.URL.RawQuery, _ = strings.Cut(target, "?")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestImageServerRejects(t *testing.T) {
+	dir := t.TempDir()
+	writeImages(t, dir)
+	s, err := NewImageServer(dir, "", 0, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for target, want := range map[string]int{
+		"opaque.png":             http.StatusOK,
+		"../images/opaque.png":   http.StatusBadRequest,
+		"sub/../../opaque.png":   http.StatusBadRequest,
+		`..\opaque.png`:          http.StatusBadRequest,
+		"notes.txt":              http.StatusForbidden,
+		"":                       http.StatusForbidden,
+		"missing.png":            http.StatusNotFound,
+		"dir.png":                http.StatusNotFound,
+		"opaque.png?w=0":         http.StatusBadRequest,
+		"opaque.png?h=4096":      http.StatusBadRequest,
+		"opaque.png?w=x":         http.StatusBadRequest,
+		"opaque.png?w=10&fit=no": http.StatusBadRequest,
+	} {
+		if have := serveImage(s, target, "").Code; have != want {
+			t.Errorf("%s: want %d, have %d", target, want, have)
+		}
+	}
+}
+
+func TestImageServerResize(t *testing.T) {
+	dir := t.TempDir()
+	writeImages(t, dir)
+	s, err := NewImageServer(dir, "", 0, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for target, want := range map[string]image.Point{
+		"opaque.png":                     {40, 20},
+		"opaque.png?w=10":                {10, 5},
+		"opaque.png?h=10":                {20, 10},
+		"opaque.png?w=10&h=10":           {10, 5},
+		"opaque.png?w=100":               {40, 20},
+		"opaque.png?w=10&h=10&fit=cover": {10, 10},
+		"opaque.png?w=30&h=30&fit=fill":  {30, 30},
+	} {
+		rec := serveImage(s, target, "")
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: want %d, have %d", target, http.StatusOK, rec.Code)
+			continue
+		}
+		config, _, err := image.DecodeConfig(rec.Body)
+		if err != nil {
+			t.Errorf("%s: %v", target, err)
+			continue
+		}
+		if have := (image.Point{config.Width, config.Height}); have != want {
+			t.Errorf("%s: want %v, have %v", target, want, have)
+		}
+	}
+}
+
+func TestImageServerNegotiate(t *testing.T) {
+	dir := t.TempDir()
+	writeImages(t, dir)
+	s, err := NewImageServer(dir, "", 0, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		target, accept, want string
+	}{
+		{"opaque.png", "", "image/png"},
+		{"opaque.png", "image/jpeg", "image/jpeg"},
+		{"opaque.png", "image/webp,*/*", "image/jpeg"},
+		{"opaque.png", "image/png,image/jpeg;q=0.5", "image/png"},
+		{"opaque.png", "image/png", "image/png"},
+		{"opaque.png?w=10", "image/*", "image/jpeg"},
+		{"clear.png", "image/jpeg", "image/png"},
+	} {
+		rec := serveImage(s, tc.target, tc.accept)
+		if have := rec.Header().Get("Content-Type"); have != tc.want {
+			t.Errorf("%s, Accept %q: want %s, have %s", tc.target, tc.accept, tc.want, have)
+		}
+		if have := rec.Header().Get("Vary"); have != "Accept" {
+			t.Errorf("%s, Accept %q: want Vary Accept, have %q", tc.target, tc.accept, have)
+		}
+	}
+}
+
+func TestImageCache(t *testing.T) {
+	dir := t.TempDir()
+	writeImages(t, dir)
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	s, err := NewImageServer(dir, cacheDir, 1<<20, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := serveImage(s, "opaque.png?w=10", "").Body.String()
+	files, _ := os.ReadDir(cacheDir)
+	if len(files) != 1 {
+		t.Fatalf("cached files: want 1, have %d", len(files))
+	}
+	if second := serveImage(s, "opaque.png?w=10", "").Body.String(); second != first {
+		t.Errorf("cached image differs from the rendered one")
+	}
+
+	// Bounded: the least recently used image goes. Files of others, in a
+	// directory shared with them, stay.
+	key := files[0].Name()
+	for _, name := range []string{"notes.txt", ".tmp-notes", ".tmp-" + key + "-1"} {
+		if err := os.WriteFile(filepath.Join(cacheDir, name), []byte("not an image of the cache"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := newImageCache(cacheDir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.entries) != 0 {
+		t.Errorf("entries over size at start: want 0, have %d", len(c.entries))
+	}
+	var names []string
+	files, _ = os.ReadDir(cacheDir)
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	if want := []string{".tmp-notes", "notes.txt"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("files left at start: want %v, have %v", want, names)
+	}
+	c.put("a", []byte("123456"))
+	c.put("b", []byte("123456"))
+	if _, ok := c.open("a"); ok {
+		t.Errorf("a: want evicted")
+	}
+	if f, ok := c.open("b"); !ok {
+		t.Errorf("b: want cached")
+	} else {
+		f.Close()
+	}
+	c.put("c", []byte("12345678901"))
+	if _, ok := c.open("c"); ok {
+		t.Errorf("c: larger than the cache, want not cached")
+	}
+}
@@ -0,0 +1,400 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// importexport.go contains the file formats products are imported from and
+// exported to in bulk: CSV, for spreadsheets, and JSON, in the shape the API
+// uses for products.
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The formats of product files.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// FormatOf returns the format of a product file by its name, "" if the
+// extension is not one of a format.
+func FormatOf(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return FormatCSV
+	case ".json":
+		return FormatJSON
+	}
+	return ""
+}
+
+// csvColumns are the columns of product CSV files, in the order exports
+// write them. Imports take them in any order, and only id and title are
+// required. Version is informational: imports ignore it.
+var csvColumns = []string{"id", "brand", "title", "description", "weight", "product_size", "colors", "qty", "price", "currency", "image_url_1", "image_url_2", "categories", "variants", "version"}
+
+// csvListSeparator separates the categories of a product in the categories
+// column, as category names contain spaces and commas are awkward to type in
+// a spreadsheet cell.
+const csvListSeparator = "|"
+
+// ImportError is the reason a row of a product file cannot be imported. Rows
+// count from 1, not counting the header of CSV files; row 0 stands for the
+// file as a whole.
+type ImportError struct {
+	Row   int    `json:"row"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportErrors is returned when a product file cannot be imported, listing
+// every row in error.
+type ImportErrors []ImportError
+
+func (e ImportErrors) Error() string {
+	if len(e) == 0 {
+		return "invalid import"
+	}
+	first := e[0]
+	msg := first.Error
+	if first.Row > 0 {
+		msg = fmt.Sprintf("row %d: %s", first.Row, msg)
+	}
+	if len(e) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(e)-1)
+	}
+	return "invalid import: " + msg
+}
+
+// ImportReport tells which products an import created, updated and left
+// unchanged, by ID, or would have in a dry run.
+type ImportReport struct {
+	DryRun    bool     `json:"dryRun"`
+	Created   []string `json:"created"`
+	Updated   []string `json:"updated"`
+	Unchanged []string `json:"unchanged"`
+}
+
+// ReadProducts reads a product file in the given format. Rows that cannot be
+// read are reported together as ImportErrors.
+func ReadProducts(r io.Reader, format string) ([]Product, error) {
+	switch format {
+	case FormatCSV:
+		return readProductsCSV(r)
+	case FormatJSON:
+		return readProductsJSON(r)
+	}
+	return nil, ImportErrors{{Error: fmt.Sprintf("unknown format %q", format)}}
+}
+
+func readProductsJSON(r io.Reader) ([]Product, error) {
+	// Rows are decoded one by one, so that a bad one does not hide the others.
+	var rows []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, ImportErrors{{Error: "not a JSON array of products: " + err.Error()}}
+	}
+	var errs ImportErrors
+	products := make([]Product, len(rows))
+	for i, row := range rows {
+		if err := json.Unmarshal(row, &products[i]); err != nil {
+			errs = append(errs, ImportError{Row: i + 1, Error: err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return products, nil
+}
+
+func readProductsCSV(r io.Reader) ([]Product, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return []Product{}, nil
+	}
+	if err != nil {
+		return nil, ImportErrors{{Error: err.Error()}}
+	}
+
+	column := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff") // spreadsheets like a byte order mark
+		}
+		if _, ok := column[name]; ok || !contains(csvColumns, name) {
+			return nil, ImportErrors{{Error: fmt.Sprintf("unknown or repeated column %q", name)}}
+		}
+		column[name] = i
+	}
+	for _, name := range []string{"id", "title"} {
+		if _, ok := column[name]; !ok {
+			return nil, ImportErrors{{Error: fmt.Sprintf("missing column %q", name)}}
+		}
+	}
+
+	var errs ImportErrors
+	products := []Product{}
+	for row := 1; ; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, ImportError{Row: row, Error: err.Error()})
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) && parseErr.Err == csv.ErrFieldCount {
+				continue
+			}
+			break // the reader cannot resynchronize after a quoting error
+		}
+		field := func(name string) string {
+			if i, ok := column[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		p := Product{
+			ID:          field("id"),
+			Brand:       field("brand"),
+			Title:       field("title"),
+			Description: field("description"),
+			Weight:      field("weight"),
+			ProductSize: field("product_size"),
+			Colors:      field("colors"),
+			Currency:    field("currency"),
+			ImageURL:    []string{field("image_url_1"), field("image_url_2")},
+			Categories:  []string{},
+		}
+		if v := field("categories"); v != "" {
+			p.Categories = strings.Split(v, csvListSeparator)
+		}
+		var problems []string
+		if v := field("variants"); v != "" {
+			if err := json.Unmarshal([]byte(v), &p.Variants); err != nil {
+				problems = append(problems, "variants are not a JSON array of variants")
+			}
+		}
+		if v := field("qty"); v != "" {
+			if p.Qty, err = strconv.Atoi(v); err != nil {
+				problems = append(problems, fmt.Sprintf("qty %q is not a whole number", v))
+			}
+		}
+		if v := field("price"); v != "" {
+			price, err := strconv.ParseFloat(v, 32)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("price %q is not a number", v))
+			}
+			p.Price = float32(price)
+		}
+		if len(problems) > 0 {
+			errs = append(errs, ImportError{Row: row, ID: p.ID, Error: strings.Join(problems, "; ")})
+		}
+		products = append(products, p)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return products, nil
+}
+
+// WriteProducts writes a product file in the given format.
+func WriteProducts(w io.Writer, format string, products []Product) error {
+	switch format {
+	case FormatCSV:
+		return writeProductsCSV(w, products)
+	case FormatJSON:
+		b, err := json.MarshalIndent(products, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(b, '\n'))
+		return err
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+func writeProductsCSV(w io.Writer, products []Product) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns); err != nil {
+		return err
+	}
+	for _, p := range products {
+		images := make([]string, 2)
+		copy(images, p.ImageURL)
+		variants := ""
+		if len(p.Variants) > 0 {
+			b, err := json.Marshal(p.Variants)
+			if err != nil {
+				return err
+			}
+			variants = string(b)
+		}
+		err := cw.Write([]string{
+			p.ID,
+			p.Brand,
+			p.Title,
+			p.Description,
+			p.Weight,
+			p.ProductSize,
+			p.Colors,
+			strconv.Itoa(p.Qty),
+			strconv.FormatFloat(float64(p.Price), 'f', -1, 32),
+			p.Currency,
+			images[0],
+			images[1],
+			strings.Join(p.Categories, csvListSeparator),
+			variants,
+			strconv.Itoa(p.Version),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// validateImport checks the products of an import, as normalizeProduct does
+// for single writes but telling what is wrong with each row, and returns
+// them normalized.
+func validateImport(products []Product, categories []string) ([]Product, error) {
+	known := make(map[string]bool, len(categories))
+	for _, c := range categories {
+		known[c] = true
+	}
+
+	var errs ImportErrors
+	rows := make(map[string]int, len(products))
+	normalized := make([]Product, 0, len(products))
+	for i, p := range products {
+		row := i + 1
+		var problems []string
+		id := strings.TrimSpace(p.ID)
+		switch {
+		case id == "":
+			problems = append(problems, "id is required")
+		case rows[id] > 0:
+			problems = append(problems, fmt.Sprintf("id repeats row %d", rows[id]))
+		default:
+			rows[id] = row
+		}
+		if strings.TrimSpace(p.Title) == "" {
+			problems = append(problems, "title is required")
+		}
+		if p.Price < 0 {
+			problems = append(problems, "price cannot be negative")
+		}
+		if p.Qty < 0 {
+			problems = append(problems, "qty cannot be negative")
+		}
+		if _, ok := normalizeCurrency(p.Currency); !ok {
+			problems = append(problems, fmt.Sprintf("currency %q is not a currency code", p.Currency))
+		}
+		if len(p.ImageURL) > 2 {
+			problems = append(problems, "at most 2 images")
+		}
+		for _, c := range p.Categories {
+			if c = strings.TrimSpace(c); c != "" && !known[c] {
+				problems = append(problems, fmt.Sprintf("unknown category %q", c))
+			}
+		}
+		if len(problems) > 0 {
+			errs = append(errs, ImportError{Row: row, ID: id, Error: strings.Join(problems, "; ")})
+			continue
+		}
+		p, err := normalizeProduct(p)
+		if err != nil {
+			errs = append(errs, ImportError{Row: row, ID: id, Error: err.Error()})
+			continue
+		}
+		normalized = append(normalized, p)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return normalized, nil
+}
+
+// sameProduct tells whether an import changes nothing of a product.
+func sameProduct(a, b Product) bool {
+	if a.Brand != b.Brand || a.Title != b.Title || a.Description != b.Description ||
+		a.Weight != b.Weight || a.ProductSize != b.ProductSize || a.Colors != b.Colors ||
+		a.Qty != b.Qty || a.Price != b.Price || a.Currency != b.Currency || a.ImageURL1 != b.ImageURL1 || a.ImageURL2 != b.ImageURL2 ||
+		len(a.Categories) != len(b.Categories) || !sameVariants(a.Variants, b.Variants) {
+		return false
+	}
+	return len(intersect(a.Categories, b.Categories)) == len(a.Categories)
+}
+
+func contains(values []string, v string) bool {
+	for _, w := range values {
+		if w == v {
+			return true
+		}
+	}
+	return false
+}
+
+// exportBatch is how many products an export reads from the store at a time.
+const exportBatch = 500
+
+// readAll reads all products of the store, by ID.
+func readAll(ctx context.Context, store Store) ([]Product, error) {
+	order := Sort{Key: "id"}
+	products := []Product{}
+	var after *Position
+	for {
+		batch, err := store.List(ctx, Filter{}, order, nil, after, 0, exportBatch)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, batch...)
+		if len(batch) < exportBatch {
+			return products, nil
+		}
+		after = &Position{ID: batch[len(batch)-1].ID}
+	}
+}
+
+// indexByID returns the products by ID.
+func indexByID(products []Product) map[string]Product {
+	index := make(map[string]Product, len(products))
+	for _, p := range products {
+		index[p.ID] = p
+	}
+	return index
+}
+
+// planImport sorts the products of an import into those it creates, updates
+// and leaves unchanged, given the current products.
+func planImport(products []Product, current map[string]Product) (report ImportReport, changed []Product) {
+	report = ImportReport{Created: []string{}, Updated: []string{}, Unchanged: []string{}}
+	for _, p := range products {
+		existing, ok := current[p.ID]
+		switch {
+		case !ok:
+			report.Created = append(report.Created, p.ID)
+		case sameProduct(p, existing):
+			report.Unchanged = append(report.Unchanged, p.ID)
+			continue
+		default:
+			report.Updated = append(report.Updated, p.ID)
+		}
+		changed = append(changed, p)
+	}
+	return report, changed
+}
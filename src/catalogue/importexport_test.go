@@ -0,0 +1,175 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+package catalogue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProductsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryService(t)
+	products, err := s.Export(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := productIDs(products); !reflect.DeepEqual(have, []string{"A", "B", "C", "D"}) {
+		t.Fatalf("Export: have %v", have)
+	}
+
+	for _, format := range []string{FormatCSV, FormatJSON} {
+		var buf bytes.Buffer
+		if err := WriteProducts(&buf, format, products); err != nil {
+			t.Fatal(err)
+		}
+		read, err := ReadProducts(&buf, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		report, err := s.Import(ctx, read, true)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if len(report.Unchanged) != len(products) || len(report.Created)+len(report.Updated) > 0 {
+			t.Errorf("%s: want all unchanged, have %+v", format, report)
+		}
+	}
+}
+
+func TestReadProductsErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, csv string
+		rows      []int
+	}{
+		{"unknown column", "id,title,colour\nA,Bowl,red\n", []int{0}},
+		{"missing column", "id,brand\nA,Acme\n", []int{0}},
+		{"bad numbers", "id,title,qty,price\nA,Bowl,1,2.5\nB,Toy,many,1\nC,Food,1,cheap\n", []int{2, 3}},
+		{"field count", "id,title\nA,Bowl\nB\nC,Food\n", []int{2}},
+	} {
+		_, err := ReadProducts(strings.NewReader(tc.csv), FormatCSV)
+		errs, ok := err.(ImportErrors)
+		if !ok {
+			t.Errorf("%s: want ImportErrors, have %v", tc.name, err)
+			continue
+		}
+		var rows []int
+		for _, e := range errs {
+			rows = append(rows, e.Row)
+		}
+		if !reflect.DeepEqual(rows, tc.rows) {
+			t.Errorf("%s: want rows %v, have %v", tc.name, tc.rows, rows)
+		}
+	}
+
+	products, err := ReadProducts(strings.NewReader("\ufeffID, Title, categories\nA,Bowl,Bowls|Food\n"), FormatCSV)
+	if err != nil || len(products) != 1 || !reflect.DeepEqual(products[0].Categories, []string{"Bowls", "Food"}) {
+		t.Errorf("header with BOM and spaces: have %v, %v", products, err)
+	}
+	if _, err := ReadProducts(strings.NewReader(`[{"id": "A"}, {"id": 1}]`), FormatJSON); err == nil || err.(ImportErrors)[0].Row != 2 {
+		t.Errorf("JSON with bad row: have %v", err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryService(t)
+
+	_, err := s.Import(ctx, []Product{
+		{ID: "A", Title: "Steel bowl"},
+		{ID: "E", Title: ""},
+		{ID: "A", Title: "Again", Categories: []string{"Nope"}},
+	}, false)
+	errs, ok := err.(ImportErrors)
+	if !ok || len(errs) != 2 || errs[0].Row != 2 || errs[1].Row != 3 {
+		t.Fatalf("invalid rows: have %v", err)
+	}
+	if p, _ := s.Get(ctx, "A", "", nil); p.Version != 1 {
+		t.Errorf("invalid import changed A: %v", p)
+	}
+
+	a, _ := s.Get(ctx, "A", "", nil)
+	a.Price = 12
+	batch := []Product{a, {ID: "E", Title: "Ball", Categories: []string{"Toys"}}}
+	want := ImportReport{DryRun: true, Created: []string{"E"}, Updated: []string{"A"}, Unchanged: []string{}}
+	if report, err := s.Import(ctx, batch, true); err != nil || !reflect.DeepEqual(report, want) {
+		t.Errorf("dry run: want %+v, have %+v, %v", want, report, err)
+	}
+	if _, err := s.Get(ctx, "E", "", nil); err != ErrNotFound {
+		t.Errorf("dry run created E: %v", err)
+	}
+
+	want.DryRun = false
+	if report, err := s.Import(ctx, batch, false); err != nil || !reflect.DeepEqual(report, want) {
+		t.Errorf("import: want %+v, have %+v, %v", want, report, err)
+	}
+	if p, _ := s.Get(ctx, "A", "", nil); p.Price != 12 || p.Version != 2 {
+		t.Errorf("A after import: %v", p)
+	}
+	if p, _ := s.Get(ctx, "E", "", nil); p.Title != "Ball" || p.Version != 1 {
+		t.Errorf("E after import: %v", p)
+	}
+}
+
+func TestImportHTTP(t *testing.T) {
+	h := newTestHandler(newTestMemoryService(t))
+
+	req := httptest.NewRequest("POST", "/catalogue/import?dryRun=true", strings.NewReader("id,title,price\nA,Steel bowl,10\nE,,1\nF,Ball,x\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var body struct {
+		Errors ImportErrors `json:"errors"`
+	}
+	json.NewDecoder(rec.Body).Decode(&body)
+	if rec.Code != http.StatusBadRequest || len(body.Errors) != 1 || body.Errors[0].Row != 3 {
+		t.Errorf("unreadable row: have %d %+v", rec.Code, body.Errors)
+	}
+
+	req = httptest.NewRequest("POST", "/catalogue/import?dryRun=true", strings.NewReader("id,title,price\nA,Steel bowl,10\nE,,1\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	json.NewDecoder(rec.Body).Decode(&body)
+	if rec.Code != http.StatusBadRequest || len(body.Errors) != 1 || body.Errors[0].Row != 2 {
+		t.Errorf("invalid row: have %d %+v", rec.Code, body.Errors)
+	}
+
+	req = httptest.NewRequest("POST", "/catalogue/import?dryRun=maybe", strings.NewReader("[]"))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("dryRun=maybe: want %d, have %d", http.StatusBadRequest, rec.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/catalogue/import", strings.NewReader(`[{"id": "E", "title": "Ball"}]`))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var report ImportReport
+	json.NewDecoder(rec.Body).Decode(&report)
+	if rec.Code != http.StatusOK || !reflect.DeepEqual(report.Created, []string{"E"}) {
+		t.Errorf("import: have %d %+v", rec.Code, report)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/admin/catalogue/export?format=csv", nil))
+	if ct := rec.Header().Get("Content-Type"); rec.Code != http.StatusOK || !strings.HasPrefix(ct, "text/csv") {
+		t.Fatalf("export: have %d %q", rec.Code, ct)
+	}
+	if lines := strings.Count(rec.Body.String(), "\n"); lines != 6 {
+		t.Errorf("export: want header and 5 products, have %d lines", lines)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/catalogue/export", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /catalogue/export: want %d, have %d", http.StatusNotFound, rec.Code)
+	}
+}
@@ -0,0 +1,163 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// locale.go contains the translations of product titles and descriptions,
+// and the choice of the locale a product is presented in.
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLocale is the locale of the titles and descriptions of products
+// themselves. Translations are for other locales.
+const DefaultLocale = "en"
+
+// ErrInvalidTranslation is returned when a translation lacks a product ID or
+// a title, or is for an invalid locale or the default one.
+var ErrInvalidTranslation = errors.New("invalid translation")
+
+// Translation is the title and description of a product in a locale. An
+// empty description falls back to that of the product.
+type Translation struct {
+	ID          string `json:"id" db:"sku"`
+	Locale      string `json:"locale" db:"locale"`
+	Title       string `json:"title" db:"title"`
+	Description string `json:"description" db:"description"`
+}
+
+var localeTag = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+
+// normalizeLocale returns a BCP 47 language tag in its usual case, e.g.
+// "pt-BR" for "PT-br", or false if it is not one.
+func normalizeLocale(tag string) (string, bool) {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	if !localeTag.MatchString(tag) {
+		return "", false
+	}
+	parts := strings.Split(tag, "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		switch len(parts[i]) {
+		case 2:
+			parts[i] = strings.ToUpper(parts[i]) // region
+		case 4:
+			parts[i] = strings.ToUpper(parts[i][:1]) + strings.ToLower(parts[i][1:]) // script
+		default:
+			parts[i] = strings.ToLower(parts[i])
+		}
+	}
+	return strings.Join(parts, "-"), true
+}
+
+// localeCandidates expands the locales a client prefers, most preferred
+// first, into those translations are looked up for: each locale followed by
+// its language alone, e.g. "fr-CA" by "fr". The list ends at the default
+// locale, which needs no translation.
+func localeCandidates(languages []string) []string {
+	var candidates []string
+	seen := make(map[string]bool)
+	for _, l := range languages {
+		tag, ok := normalizeLocale(l)
+		if !ok {
+			continue
+		}
+		for _, t := range []string{tag, strings.SplitN(tag, "-", 2)[0]} {
+			if t == DefaultLocale {
+				return candidates
+			}
+			if !seen[t] {
+				seen[t] = true
+				candidates = append(candidates, t)
+			}
+		}
+	}
+	return candidates
+}
+
+// parseAcceptLanguage returns the locales of an Accept-Language header, most
+// preferred first, leaving out the wildcard and those refused with q=0.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v := strings.TrimSpace(param); strings.HasPrefix(v, "q=") {
+				var err error
+				if q, err = strconv.ParseFloat(v[2:], 64); err != nil {
+					q = 0
+				}
+			}
+		}
+		if tag != "" && tag != "*" && q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	languages := make([]string, len(tags))
+	for i, t := range tags {
+		languages[i] = t.tag
+	}
+	return languages
+}
+
+// normalizeTranslation validates a translation submitted for writing.
+func normalizeTranslation(t Translation) (Translation, error) {
+	t.ID = strings.TrimSpace(t.ID)
+	t.Title = strings.TrimSpace(t.Title)
+	t.Description = strings.TrimSpace(t.Description)
+	locale, ok := normalizeLocale(t.Locale)
+	if !ok || locale == DefaultLocale || t.ID == "" || t.Title == "" {
+		return Translation{}, ErrInvalidTranslation
+	}
+	t.Locale = locale
+	return t, nil
+}
+
+// translate returns a product in the first of the candidate locales it has a
+// translation for, or as it is.
+func translate(p Product, translations map[string]Translation, candidates []string) Product {
+	for _, locale := range candidates {
+		if t, ok := translations[locale]; ok {
+			p.Title = t.Title
+			if t.Description != "" {
+				p.Description = t.Description
+			}
+			p.Locale = locale
+			return p
+		}
+	}
+	return p
+}
+
+// contentLanguage returns the locales products are presented in, for the
+// Content-Language header.
+func contentLanguage(products ...Product) string {
+	var locales []string
+	for _, p := range products {
+		locale := p.Locale
+		if locale == "" {
+			locale = DefaultLocale
+		}
+		if !contains(locales, locale) {
+			locales = append(locales, locale)
+		}
+	}
+	if len(locales) == 0 {
+		return DefaultLocale
+	}
+	return strings.Join(locales, ", ")
+}
@@ -0,0 +1,179 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+package catalogue
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+)
+
+var testTranslations = []Translation{
+	{ID: "A", Locale: "fr", Title: "Gamelle en acier", Description: "Une gamelle."},
+	{ID: "C", Locale: "FR", Title: "Croquettes"},
+}
+
+func TestLocaleCandidates(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		want   []string
+	}{
+		{"", nil},
+		{"fr-CA", []string{"fr-CA", "fr"}},
+		{"de;q=0.5, pt_br, *", []string{"pt-BR", "pt", "de"}},
+		{"es, en-GB;q=0.9, fr;q=0.8", []string{"es", "en-GB"}},
+		{"it;q=0, nl", []string{"nl"}},
+		{"zh-hant-tw", []string{"zh-Hant-TW", "zh"}},
+	} {
+		if have := localeCandidates(parseAcceptLanguage(tc.header)); !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("%q: want %v, have %v", tc.header, tc.want, have)
+		}
+	}
+}
+
+func TestTranslations(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryService(t)
+
+	for _, bad := range []Translation{
+		{ID: "A", Locale: "en", Title: "Bowl"},
+		{ID: "A", Locale: "fr"},
+		{ID: "A", Locale: "français", Title: "Gamelle"},
+	} {
+		if _, err := s.SetTranslations(ctx, []Translation{bad}); err != ErrInvalidTranslation {
+			t.Errorf("SetTranslations(%v): want %v, have %v", bad, ErrInvalidTranslation, err)
+		}
+	}
+	if _, err := s.SetTranslations(ctx, append(testTranslations, Translation{ID: "E", Locale: "fr", Title: "Balle"})); err != ErrNotFound {
+		t.Errorf("translation of unknown product: want %v, have %v", ErrNotFound, err)
+	}
+	if translations, _ := s.Translations(ctx, "A"); len(translations) != 0 {
+		t.Errorf("failed SetTranslations wrote %v", translations)
+	}
+	if _, err := s.SetTranslations(ctx, testTranslations); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := s.Get(ctx, "A", "", []string{"fr-CA", "en"})
+	if err != nil || p.Title != "Gamelle en acier" || p.Description != "Une gamelle." || p.Locale != "fr" {
+		t.Errorf("Get in fr-CA: have %v, %v", p, err)
+	}
+	if p, _ := s.Get(ctx, "C", "", []string{"fr"}); p.Title != "Croquettes" || p.Description != "Crunchy." {
+		t.Errorf("Get without translated description: have %v", p)
+	}
+	if p, _ := s.Get(ctx, "A", "", []string{"de", "en", "fr"}); p.Title != "Steel bowl" || p.Locale != "" {
+		t.Errorf("Get preferring en over fr: have %v", p)
+	}
+
+	products, _, err := s.List(ctx, Filter{}, "title", "", "", []string{"fr"}, 1, 10)
+	if have := productIDs(products); err != nil || !reflect.DeepEqual(have, []string{"C", "A", "B", "D"}) {
+		t.Errorf("List by title in fr: have %v, %v", have, err)
+	}
+	if products, _ := s.Search(ctx, "gamelle", nil, []string{"fr"}, 1, 10); !reflect.DeepEqual(productIDs(products), []string{"A"}) {
+		t.Errorf("Search in fr: have %v", productIDs(products))
+	}
+	if products, _ := s.Search(ctx, "gamelle", nil, nil, 1, 10); len(products) != 0 {
+		t.Errorf("Search in en: have %v", productIDs(products))
+	}
+
+	if err := s.DeleteTranslation(ctx, "A", "FR"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteTranslation(ctx, "A", "fr"); err != ErrNotFound {
+		t.Errorf("DeleteTranslation twice: want %v, have %v", ErrNotFound, err)
+	}
+	if translations, _ := s.Translations(ctx, "C"); len(translations) != 1 || translations[0].Locale != "fr" {
+		t.Errorf("Translations(C): have %v", translations)
+	}
+}
+
+func TestTranslationsHTTP(t *testing.T) {
+	h := newTestHandler(newTestMemoryService(t))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/translations", strings.NewReader(`[{"id": "A", "locale": "fr", "title": "Gamelle en acier"}]`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /translations: have %d %s", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/translations", strings.NewReader(`[{"id": "A", "locale": "fr"}]`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /translations without title: want %d, have %d", http.StatusBadRequest, rec.Code)
+	}
+
+	for _, tc := range []struct {
+		query, acceptLanguage, want string
+	}{
+		{"", "fr-FR, en;q=0.5", "fr"},
+		{"", "de", "en"},
+		{"?lang=de,fr", "en", "fr"},
+	} {
+		req := httptest.NewRequest("GET", "/catalogue/A"+tc.query, nil)
+		req.Header.Set("Accept-Language", tc.acceptLanguage)
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if have := rec.Header().Get("Content-Language"); rec.Code != http.StatusOK || have != tc.want {
+			t.Errorf("GET %q in %q: want %s, have %d %s", tc.query, tc.acceptLanguage, tc.want, rec.Code, have)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/catalogue?lang=fr&size=2", nil))
+	if have := rec.Header().Get("Content-Language"); have != "fr, en" || !strings.Contains(rec.Header().Get("Vary"), "Accept-Language") {
+		t.Errorf("GET /catalogue in fr: have %q, Vary %q", have, rec.Header().Get("Vary"))
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("DELETE", "/catalogue/A/translations/fr", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE translation: want %d, have %d", http.StatusNoContent, rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/catalogue/A/translations", nil))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("GET translations: have %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestPostgresStoreTranslated(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	store := NewPostgresStore(sqlx.NewDb(db, "sqlmock"), logger)
+
+	cols := []string{"ID", "TITLE", "DESCRIPTION", "LOCALE"}
+	mock.ExpectQuery("SELECT .*COALESCE\\(translation.title, products.title\\) AS title.* LEFT JOIN LATERAL .*locale = ANY\\(\\$1\\) ORDER BY array_position\\(\\$1, .* ORDER BY COALESCE\\(translation.title, products.title, ''\\) ASC, products.sku ASC LIMIT \\$2 OFFSET \\$3").
+		WithArgs(sqlmock.AnyArg(), 10, 0).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow("1", "titre1", "description1", "fr").AddRow("2", "title2", "description2", ""))
+	mock.ExpectQuery("SELECT .* LEFT JOIN LATERAL .* CROSS JOIN plainto_tsquery\\('french', \\$2\\) query WHERE \\(setweight\\(to_tsvector\\('french', .*\\) @@ query").
+		WithArgs(sqlmock.AnyArg(), "titre", 10, 0).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow("1", "titre1", "description1", "fr"))
+	mock.ExpectQuery("SELECT .* LEFT JOIN LATERAL .* WHERE products.sku = \\$2 GROUP BY").
+		WithArgs(sqlmock.AnyArg(), "1").
+		WillReturnRows(sqlmock.NewRows(cols).AddRow("1", "titre1", "description1", "fr"))
+
+	products, err := store.List(ctx, Filter{}, Sort{Key: "title"}, []string{"fr-CA", "fr"}, nil, 0, 10)
+	if err != nil || len(products) != 2 || products[0].Locale != "fr" || products[1].Locale != "" {
+		t.Errorf("List: have %v, %v", products, err)
+	}
+	if products, err := store.Search(ctx, "titre", nil, []string{"fr-CA", "fr"}, 0, 10); err != nil || len(products) != 1 {
+		t.Errorf("Search: have %v, %v", products, err)
+	}
+	if p, err := store.Get(ctx, "1", []string{"fr"}); err != nil || p.Title != "titre1" || p.Locale != "fr" {
+		t.Errorf("Get: have %v, %v", p, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
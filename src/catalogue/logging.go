@@ -6,6 +6,8 @@
 package catalogue
 
 import (
+	"context"
+	"strconv"
 	"strings"
 	"time"
 
@@ -27,12 +29,15 @@ type loggingMiddleware struct {
 	logger log.Logger
 }
 
-func (mw loggingMiddleware) List(categories []string, order string, pageNum, pageSize int) (products []Product, err error) {
+func (mw loggingMiddleware) List(ctx context.Context, filter Filter, order, cursor, currency string, languages []string, pageNum, pageSize int) (products []Product, next string, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "List",
-			"categories", strings.Join(categories, ", "),
+			"filter", formatFilter(filter),
 			"order", order,
+			"cursor", cursor,
+			"currency", currency,
+			"languages", strings.Join(languages, ","),
 			"pageNum", pageNum,
 			"pageSize", pageSize,
 			"result", len(products),
@@ -40,36 +45,223 @@ func (mw loggingMiddleware) List(categories []string, order string, pageNum, pag
 			"took", time.Since(begin),
 		)
 	}(time.Now())
-	return mw.next.List(categories, order, pageNum, pageSize)
+	return mw.next.List(ctx, filter, order, cursor, currency, languages, pageNum, pageSize)
 }
 
-func (mw loggingMiddleware) Count(categories []string) (n int, err error) {
+func (mw loggingMiddleware) Count(ctx context.Context, filter Filter) (n int, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "Count",
-			"categories", strings.Join(categories, ", "),
+			"filter", formatFilter(filter),
 			"result", n,
 			"err", err,
 			"took", time.Since(begin),
 		)
 	}(time.Now())
-	return mw.next.Count(categories)
+	return mw.next.Count(ctx, filter)
 }
 
-func (mw loggingMiddleware) Get(id string) (s Product, err error) {
+func (mw loggingMiddleware) Facets(ctx context.Context, filter Filter) (facets Facets, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "Facets",
+			"filter", formatFilter(filter),
+			"brands", len(facets.Brands),
+			"colors", len(facets.Colors),
+			"categories", len(facets.Categories),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.Facets(ctx, filter)
+}
+
+func (mw loggingMiddleware) Search(ctx context.Context, query string, categories, languages []string, pageNum, pageSize int) (products []Product, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "Search",
+			"query", query,
+			"categories", strings.Join(categories, ", "),
+			"languages", strings.Join(languages, ","),
+			"pageNum", pageNum,
+			"pageSize", pageSize,
+			"result", len(products),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.Search(ctx, query, categories, languages, pageNum, pageSize)
+}
+
+func (mw loggingMiddleware) Suggest(ctx context.Context, query string, size int) (suggestions []Suggestion, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "Suggest",
+			"query", query,
+			"size", size,
+			"result", len(suggestions),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.Suggest(ctx, query, size)
+}
+
+func (mw loggingMiddleware) Get(ctx context.Context, id, currency string, languages []string) (s Product, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "Get",
 			"id", id,
+			"currency", currency,
+			"languages", strings.Join(languages, ","),
+			"locale", s.Locale,
 			"product", s.ID,
 			"err", err,
 			"took", time.Since(begin),
 		)
 	}(time.Now())
-	return mw.next.Get(id)
+	return mw.next.Get(ctx, id, currency, languages)
+}
+
+func (mw loggingMiddleware) Batch(ctx context.Context, ids []string, currency string, languages []string) (products []Product, missing []string, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "Batch",
+			"ids", len(ids),
+			"currency", currency,
+			"languages", strings.Join(languages, ","),
+			"result", len(products),
+			"missing", len(missing),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.Batch(ctx, ids, currency, languages)
 }
 
-func (mw loggingMiddleware) Categories() (categories []string, err error) {
+func (mw loggingMiddleware) Related(ctx context.Context, id, currency string, languages []string, size int) (products []Product, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "Related",
+			"id", id,
+			"currency", currency,
+			"languages", strings.Join(languages, ","),
+			"size", size,
+			"result", len(products),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.Related(ctx, id, currency, languages, size)
+}
+
+func (mw loggingMiddleware) Create(ctx context.Context, product Product) (p Product, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "Create",
+			"id", product.ID,
+			"version", p.Version,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.Create(ctx, product)
+}
+
+func (mw loggingMiddleware) Update(ctx context.Context, product Product) (p Product, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "Update",
+			"id", product.ID,
+			"fromVersion", product.Version,
+			"version", p.Version,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.Update(ctx, product)
+}
+
+func (mw loggingMiddleware) Delete(ctx context.Context, id string, version int) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "Delete",
+			"id", id,
+			"version", version,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.Delete(ctx, id, version)
+}
+
+func (mw loggingMiddleware) Import(ctx context.Context, products []Product, dryRun bool) (report ImportReport, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "Import",
+			"products", len(products),
+			"dryRun", dryRun,
+			"created", len(report.Created),
+			"updated", len(report.Updated),
+			"unchanged", len(report.Unchanged),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.Import(ctx, products, dryRun)
+}
+
+func (mw loggingMiddleware) Export(ctx context.Context) (products []Product, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "Export",
+			"result", len(products),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.Export(ctx)
+}
+
+func (mw loggingMiddleware) Translations(ctx context.Context, id string) (translations []Translation, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "Translations",
+			"id", id,
+			"result", len(translations),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.Translations(ctx, id)
+}
+
+func (mw loggingMiddleware) SetTranslations(ctx context.Context, translations []Translation) (result []Translation, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "SetTranslations",
+			"translations", len(translations),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.SetTranslations(ctx, translations)
+}
+
+func (mw loggingMiddleware) DeleteTranslation(ctx context.Context, id, locale string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "DeleteTranslation",
+			"id", id,
+			"locale", locale,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.DeleteTranslation(ctx, id, locale)
+}
+
+func (mw loggingMiddleware) Categories(ctx context.Context) (categories []string, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "Categories",
@@ -78,10 +270,218 @@ func (mw loggingMiddleware) Categories() (categories []string, err error) {
 			"took", time.Since(begin),
 		)
 	}(time.Now())
-	return mw.next.Categories()
+	return mw.next.Categories(ctx)
+}
+
+func (mw loggingMiddleware) CategoryTree(ctx context.Context) (roots []Category, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "CategoryTree",
+			"result", len(roots),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.CategoryTree(ctx)
+}
+
+func (mw loggingMiddleware) Rates(ctx context.Context) (rates Rates, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "Rates",
+			"base", rates.Base,
+			"result", len(rates.Rates),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.Rates(ctx)
+}
+
+func (mw loggingMiddleware) SetRates(ctx context.Context, rates Rates) (result Rates, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "SetRates",
+			"base", rates.Base,
+			"rates", len(rates.Rates),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.SetRates(ctx, rates)
+}
+
+func (mw loggingMiddleware) SetCoViews(ctx context.Context, coViews CoViews) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "SetCoViews",
+			"products", len(coViews),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.SetCoViews(ctx, coViews)
+}
+
+func (mw loggingMiddleware) Promotions(ctx context.Context) (promotions []Promotion, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "Promotions",
+			"result", len(promotions),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.Promotions(ctx)
+}
+
+func (mw loggingMiddleware) CreatePromotion(ctx context.Context, promotion Promotion) (result Promotion, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "CreatePromotion",
+			"id", promotion.ID,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.CreatePromotion(ctx, promotion)
 }
 
-func (mw loggingMiddleware) Health() (health []Health) {
+func (mw loggingMiddleware) ExpirePromotion(ctx context.Context, id string) (promotion Promotion, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ExpirePromotion",
+			"id", id,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.ExpirePromotion(ctx, id)
+}
+
+func (mw loggingMiddleware) PriceHistory(ctx context.Context, id string) (changes []PriceChange, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "PriceHistory",
+			"id", id,
+			"result", len(changes),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.PriceHistory(ctx, id)
+}
+
+func (mw loggingMiddleware) Reviews(ctx context.Context, id string, pageNum, pageSize int) (reviews []Review, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "Reviews",
+			"id", id,
+			"pageNum", pageNum,
+			"pageSize", pageSize,
+			"result", len(reviews),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.Reviews(ctx, id, pageNum, pageSize)
+}
+
+func (mw loggingMiddleware) CreateReview(ctx context.Context, review Review) (result Review, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "CreateReview",
+			"sku", review.SKU,
+			"rating", review.Rating,
+			"id", result.ID,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.CreateReview(ctx, review)
+}
+
+func (mw loggingMiddleware) ListReviews(ctx context.Context, status string, pageNum, pageSize int) (reviews []Review, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ListReviews",
+			"status", status,
+			"pageNum", pageNum,
+			"pageSize", pageSize,
+			"result", len(reviews),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.ListReviews(ctx, status, pageNum, pageSize)
+}
+
+func (mw loggingMiddleware) ModerateReview(ctx context.Context, id, status string) (review Review, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ModerateReview",
+			"id", id,
+			"status", status,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.ModerateReview(ctx, id, status)
+}
+
+func (mw loggingMiddleware) Reserve(ctx context.Context, reservation Reservation) (result Reservation, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "Reserve",
+			"sku", reservation.SKU,
+			"cart", reservation.Cart,
+			"qty", reservation.Qty,
+			"id", result.ID,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.Reserve(ctx, reservation)
+}
+
+func (mw loggingMiddleware) CommitReservation(ctx context.Context, id string) (reservation Reservation, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "CommitReservation",
+			"id", id,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.CommitReservation(ctx, id)
+}
+
+func (mw loggingMiddleware) ReleaseReservation(ctx context.Context, id string) (reservation Reservation, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ReleaseReservation",
+			"id", id,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.ReleaseReservation(ctx, id)
+}
+
+func (mw loggingMiddleware) ReplayChanges(ctx context.Context, from int64) (replayed int, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ReplayChanges",
+			"from", from,
+			"replayed", replayed,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+	return mw.next.ReplayChanges(ctx, from)
+}
+
+func (mw loggingMiddleware) Health(ctx context.Context) (health []Health) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "Health",
@@ -89,5 +489,34 @@ func (mw loggingMiddleware) Health() (health []Health) {
 			"took", time.Since(begin),
 		)
 	}(time.Now())
-	return mw.next.Health()
+	return mw.next.Health(ctx)
+}
+
+// formatFilter renders the fields of a filter that are set, e.g.
+// "categories=Bowls brand=Petsafe,Petmate maxPrice=20".
+func formatFilter(f Filter) string {
+	var parts []string
+	for _, field := range []struct {
+		name   string
+		values []string
+	}{
+		{"categories", f.Categories},
+		{"brand", f.Brands},
+		{"color", f.Colors},
+		{"size", f.Sizes},
+	} {
+		if len(field.values) > 0 {
+			parts = append(parts, field.name+"="+strings.Join(field.values, ","))
+		}
+	}
+	if f.MatchAll {
+		parts = append(parts, "match=all")
+	}
+	if f.MinPrice != nil {
+		parts = append(parts, "minPrice="+strconv.FormatFloat(*f.MinPrice, 'f', -1, 64))
+	}
+	if f.MaxPrice != nil {
+		parts = append(parts, "maxPrice="+strconv.FormatFloat(*f.MaxPrice, 'f', -1, 64))
+	}
+	return strings.Join(parts, " ")
 }
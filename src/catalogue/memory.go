@@ -0,0 +1,911 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// memory.go contains the store keeping the catalogue in memory, seeded from a
+// JSON fixture, for running the service without a database.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Fixture is the content of a catalogue, in the JSON the API uses for
+// products, e.g. dbdata/catalogue.json.
+type Fixture struct {
+	Categories []Category `json:"categories"`
+	Products   []Product  `json:"products"`
+}
+
+// ReadFixture reads a fixture from a JSON file.
+func ReadFixture(path string) (Fixture, error) {
+	var fixture Fixture
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Fixture{}, err
+	}
+	if err = json.Unmarshal(b, &fixture); err != nil {
+		return Fixture{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return fixture, nil
+}
+
+// NewMemoryStore returns a store keeping the catalogue in memory, starting
+// with the content of the fixture. Products without a version start at 1.
+func NewMemoryStore(fixture Fixture) (Store, error) {
+	s := &memoryStore{
+		products:     make(map[string]Product),
+		translations: make(map[string]map[string]Translation),
+		known:        make(map[string]bool),
+		prices:       make(map[string][]PriceChange),
+		reservations: make(map[string]Reservation),
+		reviews:      make(map[string]Review),
+		now:          time.Now,
+	}
+	categories, err := normalizeCategories(fixture.Categories)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range categories {
+		s.known[c.Name] = true
+	}
+	s.categories = categories
+	for i, p := range fixture.Products {
+		p, err := normalizeProduct(p)
+		if err == nil {
+			if p.Version <= 0 {
+				p.Version = 1
+			}
+			err = s.Create(context.Background(), p)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("product %d (%q): %w", i, fixture.Products[i].ID, err)
+		}
+	}
+	return s, nil
+}
+
+type memoryStore struct {
+	mtx          sync.RWMutex
+	products     map[string]Product
+	translations map[string]map[string]Translation // by product ID, then locale
+	categories   []Category                        // parents first
+	known        map[string]bool                   // the categories, by name
+	promotions   []Promotion
+	prices       map[string][]PriceChange // by product ID, oldest first
+	reservations map[string]Reservation   // by ID
+	reviews      map[string]Review        // by ID
+	now          func() time.Time
+	changes      []Change // the outbox, oldest first
+	published    int      // the changes published, those before it
+
+	publishing sync.Mutex // held by PublishChanges
+}
+
+func (s *memoryStore) List(ctx context.Context, filter Filter, order Sort, locales []string, after *Position, offset, limit int) ([]Product, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	products := s.present(s.filter(filter), locales)
+	sort.Slice(products, func(i, j int) bool {
+		c := compareProducts(order, filter, products[i], order.value(filter, products[j]), products[j].ID)
+		return c < 0
+	})
+	if after != nil {
+		i := sort.Search(len(products), func(i int) bool {
+			return compareProducts(order, filter, products[i], after.Value, after.ID) > 0
+		})
+		products = products[i:]
+	}
+	return page(products, offset, limit), nil
+}
+
+func (s *memoryStore) Count(ctx context.Context, filter Filter) (int, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return len(s.filter(filter)), nil
+}
+
+func (s *memoryStore) Facets(ctx context.Context, filter Filter) (Facets, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	// Each facet ignores the filter on its own attribute.
+	unbranded := filter
+	unbranded.Brands = nil
+	uncolored := filter
+	uncolored.Colors = nil
+	uncategorized := filter
+	uncategorized.Categories = nil
+	unpriced := filter
+	unpriced.MinPrice, unpriced.MaxPrice = nil, nil
+
+	facets := Facets{
+		Brands: facetCounts(s.filter(unbranded), func(p Product) []string {
+			return []string{p.Brand}
+		}),
+		Colors: facetCounts(s.filter(uncolored), func(p Product) []string {
+			return lower(splitValues(p.Colors))
+		}),
+		Categories: facetCounts(s.filter(uncategorized), func(p Product) []string {
+			return p.Categories
+		}),
+		Prices: newPriceBuckets(),
+	}
+	for _, p := range s.filter(unpriced) {
+		price, ok := unpriced.price(p)
+		if !ok {
+			continue
+		}
+		bucket := 0
+		for bucket < len(priceBounds) && price >= priceBounds[bucket] {
+			bucket++
+		}
+		facets.Prices[bucket].Count++
+	}
+	return facets, nil
+}
+
+// facetCounts counts the products per value, most frequent first. Empty
+// values, and the "0" the catalogue data uses for "none", are left out.
+func facetCounts(products []Product, values func(Product) []string) []FacetCount {
+	counts := make(map[string]int)
+	for _, p := range products {
+		for _, v := range distinct(values(p)) {
+			if v != "0" {
+				counts[v]++
+			}
+		}
+	}
+	result := make([]FacetCount, 0, len(counts))
+	for v, n := range counts {
+		result = append(result, FacetCount{Value: v, Count: n})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Value < result[j].Value
+	})
+	return result
+}
+
+// Search approximates the full-text search of PostgreSQL: all terms of the
+// query must start a word of the product, a plural matching its singular.
+// Matches in the title rank above those in the brand, which rank above those
+// in the description.
+func (s *memoryStore) Search(ctx context.Context, query string, categories []string, locales []string, offset, limit int) ([]Product, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	terms := words(query)
+	for i, t := range terms {
+		if len(t) > 3 {
+			terms[i] = strings.TrimSuffix(t, "s")
+		}
+	}
+
+	ranks := make(map[string]float64)
+	var products []Product
+	for _, p := range s.present(s.filter(Filter{Categories: categories}), locales) {
+		var rank float64
+		for _, t := range terms {
+			r := 1.0*matches(p.Title, t) + 0.4*matches(p.Brand, t) + 0.2*matches(p.Description, t)
+			if r == 0 {
+				rank = 0
+				break
+			}
+			rank += r
+		}
+		if rank > 0 {
+			ranks[p.ID] = rank
+			products = append(products, p)
+		}
+	}
+	sort.Slice(products, func(i, j int) bool {
+		if ri, rj := ranks[products[i].ID], ranks[products[j].ID]; ri != rj {
+			return ri > rj
+		}
+		return products[i].ID < products[j].ID
+	})
+	return page(products, offset, limit), nil
+}
+
+// words splits text into lower case words.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// matches counts the words of a text starting with the term.
+func matches(text, term string) float64 {
+	var n float64
+	for _, w := range words(text) {
+		if strings.HasPrefix(w, term) {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *memoryStore) Get(ctx context.Context, id string, locales []string) (Product, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	p, ok := s.products[id]
+	if !ok {
+		return Product{}, ErrNotFound
+	}
+	return s.present([]Product{p.clone()}, locales)[0], nil
+}
+
+func (s *memoryStore) GetMany(ctx context.Context, ids []string, locales []string) ([]Product, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	products := []Product{}
+	for _, id := range ids {
+		if p, ok := s.products[id]; ok {
+			products = append(products, p.clone())
+		}
+	}
+	return s.present(products, locales), nil
+}
+
+func (s *memoryStore) Related(ctx context.Context, id string, coViews map[string]float64, locales []string, limit int) ([]Product, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	product, ok := s.products[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	scores := make(map[string]float64)
+	related := []Product{}
+	for _, p := range s.products {
+		if score := relatedScore(product, p, coViews[p.ID]); p.ID != id && score > 0 {
+			scores[p.ID] = score
+			related = append(related, p.clone())
+		}
+	}
+	sort.Slice(related, func(i, j int) bool {
+		if si, sj := scores[related[i].ID], scores[related[j].ID]; si != sj {
+			return si > sj
+		}
+		return related[i].ID < related[j].ID
+	})
+	if len(related) > limit {
+		related = related[:limit]
+	}
+	return s.present(related, locales), nil
+}
+
+func (s *memoryStore) Create(ctx context.Context, product Product) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, ok := s.products[product.ID]; ok {
+		return ErrProductExists
+	}
+	if !s.knownCategories(product.Categories) {
+		return ErrUnknownCategory
+	}
+	if s.variantTaken(product) {
+		return ErrVariantExists
+	}
+	s.recordPrice(product)
+	s.products[product.ID] = product.clone()
+	s.recordWrite(product)
+	return nil
+}
+
+func (s *memoryStore) Update(ctx context.Context, product Product) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if err := s.checkVersion(product.ID, product.Version); err != nil {
+		return err
+	}
+	if !s.knownCategories(product.Categories) {
+		return ErrUnknownCategory
+	}
+	if s.variantTaken(product) {
+		return ErrVariantExists
+	}
+	s.recordPrice(product)
+	product = product.clone()
+	product.Version++
+	s.products[product.ID] = product
+	s.recordWrite(product)
+	return nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, id string, version int) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if err := s.checkVersion(id, version); err != nil {
+		return err
+	}
+	delete(s.products, id)
+	delete(s.translations, id)
+	for rid, r := range s.reservations {
+		if r.SKU == id {
+			delete(s.reservations, rid)
+		}
+	}
+	for rid, r := range s.reviews {
+		if r.SKU == id {
+			delete(s.reviews, rid)
+		}
+	}
+	s.recordChange(ProductDeleted, changeDetail{ID: id, Version: version})
+	return nil
+}
+
+func (s *memoryStore) Import(ctx context.Context, products []Product) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	owners := make(map[string]string) // product IDs by variant ID
+	for _, p := range products {
+		if !s.knownCategories(p.Categories) {
+			return ErrUnknownCategory
+		}
+		if s.variantTaken(p) {
+			return ErrVariantExists
+		}
+		for _, v := range p.Variants {
+			if owner, ok := owners[v.ID]; ok && owner != p.ID {
+				return ErrVariantExists
+			}
+			owners[v.ID] = p.ID
+		}
+	}
+	for _, p := range products {
+		p = p.clone()
+		p.Version = 1
+		if existing, ok := s.products[p.ID]; ok {
+			p.Version = existing.Version + 1
+		}
+		s.recordPrice(p)
+		s.products[p.ID] = p
+		s.recordWrite(p)
+	}
+	return nil
+}
+
+func (s *memoryStore) Translations(ctx context.Context, id string) ([]Translation, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	if _, ok := s.products[id]; !ok {
+		return []Translation{}, ErrNotFound
+	}
+	translations := []Translation{}
+	for _, t := range s.translations[id] {
+		translations = append(translations, t)
+	}
+	sort.Slice(translations, func(i, j int) bool { return translations[i].Locale < translations[j].Locale })
+	return translations, nil
+}
+
+func (s *memoryStore) SetTranslations(ctx context.Context, translations []Translation) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, t := range translations {
+		if _, ok := s.products[t.ID]; !ok {
+			return ErrNotFound
+		}
+	}
+	for _, t := range translations {
+		if s.translations[t.ID] == nil {
+			s.translations[t.ID] = make(map[string]Translation)
+		}
+		s.translations[t.ID][t.Locale] = t
+	}
+	return nil
+}
+
+func (s *memoryStore) DeleteTranslation(ctx context.Context, id, locale string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, ok := s.translations[id][locale]; !ok {
+		return ErrNotFound
+	}
+	delete(s.translations[id], locale)
+	return nil
+}
+
+// present presents products in the locales, on sale by the promotions active
+// now, with the stock reservations leave available and the rating of their
+// reviews. The caller holds the lock.
+func (s *memoryStore) present(products []Product, locales []string) []Product {
+	now := s.now()
+	for i, p := range products {
+		if len(locales) > 0 {
+			p = translate(p, s.translations[p.ID], locales)
+		}
+		p.Available = available(p.Qty, s.reserved(p.ID, now))
+		p.Rating, p.ReviewCount = s.rating(p.ID)
+		products[i] = promote(p, s.promotions, now)
+	}
+	return products
+}
+
+// reserved counts the units of a product held by reservations at a time.
+// The caller holds the lock.
+func (s *memoryStore) reserved(id string, at time.Time) int {
+	n := 0
+	for _, r := range s.reservations {
+		if r.SKU == id && at.Before(r.Expires) {
+			n += r.Qty
+		}
+	}
+	return n
+}
+
+// rating returns the rating of a product and the number of its approved
+// reviews. The caller holds the lock.
+func (s *memoryStore) rating(id string) (float64, int) {
+	stars, n := 0, 0
+	for _, r := range s.reviews {
+		if r.SKU == id && r.Status == ReviewApproved {
+			stars += r.Rating
+			n++
+		}
+	}
+	return averageRating(stars, n), n
+}
+
+// recordPrice adds the price of a product written to its history, if it
+// changed. The caller holds the lock.
+func (s *memoryStore) recordPrice(p Product) {
+	history := s.prices[p.ID]
+	if n := len(history); n > 0 && history[n-1].Price == p.Price && history[n-1].Currency == p.Currency {
+		return
+	}
+	s.prices[p.ID] = append(history, PriceChange{Price: p.Price, Currency: p.Currency, Changed: s.now().UTC()})
+}
+
+func (s *memoryStore) Promotions(ctx context.Context) ([]Promotion, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	promotions := make([]Promotion, len(s.promotions))
+	for i, p := range s.promotions {
+		promotions[len(promotions)-1-i] = p
+	}
+	return promotions, nil
+}
+
+func (s *memoryStore) CreatePromotion(ctx context.Context, promotion Promotion) (Promotion, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, p := range s.promotions {
+		if p.ID == promotion.ID {
+			return Promotion{}, ErrPromotionExists
+		}
+	}
+	promotion.Created = s.now().UTC()
+	s.promotions = append(s.promotions, promotion)
+	return promotion, nil
+}
+
+func (s *memoryStore) ExpirePromotion(ctx context.Context, id string, at time.Time) (Promotion, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for i, p := range s.promotions {
+		if p.ID != id {
+			continue
+		}
+		if at.Before(p.Ends) {
+			p.Ends = at
+		}
+		if at.Before(p.Starts) {
+			p.Starts = at
+		}
+		s.promotions[i] = p
+		return p, nil
+	}
+	return Promotion{}, ErrNotFound
+}
+
+func (s *memoryStore) PriceHistory(ctx context.Context, id string) ([]PriceChange, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	history := s.prices[id]
+	if _, ok := s.products[id]; !ok && len(history) == 0 {
+		return []PriceChange{}, ErrNotFound
+	}
+	changes := make([]PriceChange, len(history))
+	for i, c := range history {
+		changes[len(changes)-1-i] = c
+	}
+	return changes, nil
+}
+
+func (s *memoryStore) Reviews(ctx context.Context, id, status string, offset, limit int) ([]Review, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	if _, ok := s.products[id]; id != "" && !ok {
+		return []Review{}, ErrNotFound
+	}
+	reviews := []Review{}
+	for _, r := range s.reviews {
+		if (id == "" || r.SKU == id) && r.Status == status {
+			reviews = append(reviews, r)
+		}
+	}
+	sort.Slice(reviews, func(i, j int) bool {
+		if !reviews[i].Created.Equal(reviews[j].Created) {
+			return reviews[i].Created.After(reviews[j].Created)
+		}
+		return reviews[i].ID > reviews[j].ID
+	})
+	if offset >= len(reviews) {
+		return []Review{}, nil
+	}
+	reviews = reviews[offset:]
+	if len(reviews) > limit {
+		reviews = reviews[:limit]
+	}
+	return reviews, nil
+}
+
+func (s *memoryStore) CreateReview(ctx context.Context, review Review) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, ok := s.products[review.SKU]; !ok {
+		return ErrNotFound
+	}
+	s.reviews[review.ID] = review
+	return nil
+}
+
+func (s *memoryStore) ModerateReview(ctx context.Context, id, status string, at time.Time) (Review, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	r, ok := s.reviews[id]
+	if !ok {
+		return Review{}, ErrNotFound
+	}
+	r.Status, r.Moderated = status, &at
+	s.reviews[id] = r
+	return r, nil
+}
+
+func (s *memoryStore) Reserve(ctx context.Context, reservation Reservation) (Reservation, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	p, ok := s.products[reservation.SKU]
+	if !ok {
+		return Reservation{}, ErrNotFound
+	}
+	if len(p.Variants) > 0 {
+		return Reservation{}, ErrInvalidReservation
+	}
+	if available(p.Qty, s.reserved(p.ID, reservation.Created)) < reservation.Qty {
+		return Reservation{}, ErrInsufficientStock
+	}
+	s.reservations[reservation.ID] = reservation
+	return reservation, nil
+}
+
+func (s *memoryStore) CommitReservation(ctx context.Context, id string, at time.Time) (Reservation, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	r, ok := s.reservations[id]
+	if !ok {
+		return Reservation{}, ErrNotFound
+	}
+	if !at.Before(r.Expires) {
+		return Reservation{}, ErrReservationExpired
+	}
+	p := s.products[r.SKU]
+	if len(p.Variants) > 0 {
+		return Reservation{}, ErrInvalidReservation
+	}
+	if p.Qty < r.Qty {
+		return Reservation{}, ErrInsufficientStock
+	}
+	p.Qty -= r.Qty
+	p.Version++
+	s.products[p.ID] = p
+	delete(s.reservations, id)
+	s.recordWrite(p)
+	return r, nil
+}
+
+func (s *memoryStore) ReleaseReservation(ctx context.Context, id string) (Reservation, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	r, ok := s.reservations[id]
+	if !ok {
+		return Reservation{}, ErrNotFound
+	}
+	delete(s.reservations, id)
+	return r, nil
+}
+
+func (s *memoryStore) ReleaseExpired(ctx context.Context, at time.Time) (int, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	n := 0
+	for id, r := range s.reservations {
+		if !at.Before(r.Expires) {
+			delete(s.reservations, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// recordWrite records in the outbox that a product was written, created when
+// at version 1 and updated otherwise. The caller holds the lock.
+func (s *memoryStore) recordWrite(p Product) {
+	changeType := ProductUpdated
+	if p.Version == 1 {
+		changeType = ProductCreated
+	}
+	s.recordChange(changeType, changeDetail{ID: p.ID, Version: p.Version, Price: p.Price, Currency: p.Currency})
+}
+
+// recordChange records a change in the outbox. The caller holds the lock.
+func (s *memoryStore) recordChange(changeType string, detail changeDetail) {
+	b, _ := json.Marshal(detail)
+	s.changes = append(s.changes, Change{
+		Offset: int64(len(s.changes) + 1),
+		Type:   changeType,
+		ID:     detail.ID,
+		Detail: b,
+		Time:   s.now().UTC(),
+	})
+}
+
+func (s *memoryStore) PublishChanges(ctx context.Context, limit int, publish func([]Change) error) (int, error) {
+	s.publishing.Lock()
+	defer s.publishing.Unlock()
+
+	s.mtx.RLock()
+	start := s.published
+	changes := append([]Change(nil), s.changes[start:]...)
+	s.mtx.RUnlock()
+	if len(changes) > limit {
+		changes = changes[:limit]
+	}
+	if len(changes) == 0 {
+		return 0, nil
+	}
+	if err := publish(changes); err != nil {
+		return 0, err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.published == start { // unless replayed meanwhile
+		s.published += len(changes)
+	}
+	return len(changes), nil
+}
+
+func (s *memoryStore) ReplayChanges(ctx context.Context, from int64) (int, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	i := int(from) - 1 // offsets start at 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= s.published {
+		return 0, nil
+	}
+	n := s.published - i
+	s.published = i
+	return n, nil
+}
+
+// checkVersion tells whether a product exists at the given version. The
+// caller holds the lock.
+func (s *memoryStore) checkVersion(id string, version int) error {
+	p, ok := s.products[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if p.Version != version {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// variantTaken tells whether a variant of a product is a variant of another
+// product. The caller holds the lock.
+func (s *memoryStore) variantTaken(product Product) bool {
+	for _, v := range product.Variants {
+		for _, p := range s.products {
+			if p.ID == product.ID {
+				continue
+			}
+			for _, w := range p.Variants {
+				if v.ID == w.ID {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (s *memoryStore) knownCategories(categories []string) bool {
+	for _, c := range categories {
+		if !s.known[c] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *memoryStore) Categories(ctx context.Context) ([]string, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	names := make([]string, len(s.categories))
+	for i, c := range s.categories {
+		names[i] = c.Name
+	}
+	return names, nil
+}
+
+func (s *memoryStore) CategoryTree(ctx context.Context) ([]Category, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return categoryTree(s.categories), nil
+}
+
+// subtree returns a category and its descendants. The caller holds the lock.
+func (s *memoryStore) subtree(name string) []string {
+	names := []string{name}
+	for _, c := range s.categories { // parents first
+		if contains(names, c.Parent) {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+func (s *memoryStore) Health(ctx context.Context) Health {
+	return Health{"memory:catalogue-data", "OK", time.Now().String()}
+}
+
+// filter returns copies of the products matching the filter, in no
+// particular order. The caller holds the lock.
+func (s *memoryStore) filter(f Filter) []Product {
+	var subtrees [][]string // of the categories, matching their descendants
+	var anyCategory []string
+	for _, c := range distinct(f.Categories) {
+		subtree := s.subtree(c)
+		subtrees = append(subtrees, subtree)
+		anyCategory = append(anyCategory, subtree...)
+	}
+	colors := lower(f.Colors)
+	sizes := lower(f.Sizes)
+
+	products := []Product{}
+	for _, p := range s.products {
+		switch {
+		case f.MatchAll && !inAll(subtrees, p.Categories):
+		case len(subtrees) > 0 && len(intersect(anyCategory, p.Categories)) == 0:
+		case (f.MinPrice != nil || f.MaxPrice != nil) && !inPriceRange(f, p):
+		case len(f.Brands) > 0 && len(intersect(f.Brands, []string{p.Brand})) == 0:
+		case len(colors) > 0 && len(intersect(colors, lower(splitValues(p.Colors)))) == 0:
+		case len(sizes) > 0 && len(intersect(sizes, lower(splitValues(p.ProductSize)))) == 0:
+		case !matchesVariant(p, colors, sizes):
+		default:
+			products = append(products, p.clone())
+		}
+	}
+	return products
+}
+
+// inPriceRange tells whether a product has a price between the bounds of the
+// filter, in its currency.
+func inPriceRange(f Filter, p Product) bool {
+	price, ok := f.price(p)
+	return ok && (f.MinPrice == nil || price >= *f.MinPrice) && (f.MaxPrice == nil || price <= *f.MaxPrice)
+}
+
+// inAll tells whether some of the categories are in each of the subtrees.
+func inAll(subtrees [][]string, categories []string) bool {
+	for _, subtree := range subtrees {
+		if len(intersect(subtree, categories)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// intersect returns the values of a that are in b.
+func intersect(a, b []string) []string {
+	var result []string
+	for _, v := range a {
+		for _, w := range b {
+			if v == w {
+				result = append(result, v)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// splitValues splits the free-form, comma separated attribute lists of
+// products (colors, product_size).
+func splitValues(list string) []string {
+	return distinct(strings.Split(list, ","))
+}
+
+// compareProducts compares a product to a position in an order, with prices
+// in the currency of the filter: negative if the product comes first,
+// positive if it comes after.
+func compareProducts(order Sort, f Filter, p Product, value interface{}, id string) int {
+	c := 0
+	switch v := value.(type) {
+	case string:
+		c = strings.Compare(order.value(f, p).(string), v)
+	case float64:
+		switch order.Key {
+		case "price":
+			c = compareValues(order.value(f, p).(float64), v)
+		case "rating":
+			c = compareValues(p.Rating, v)
+		default:
+			c = compareValues(float64(p.Qty), v)
+		}
+	case int:
+		c = compareValues(p.Qty, v)
+	}
+	if c == 0 {
+		c = strings.Compare(p.ID, id)
+	}
+	if order.Descending {
+		return -c
+	}
+	return c
+}
+
+func compareValues[T float32 | float64 | int](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// page returns the products from offset, up to limit of them.
+func page(products []Product, offset, limit int) []Product {
+	if offset >= len(products) {
+		return []Product{}
+	}
+	products = products[offset:]
+	if len(products) > limit {
+		products = products[:limit]
+	}
+	return products
+}
+
+// clone returns a copy of a product not sharing its slices.
+func (p Product) clone() Product {
+	p.ImageURL = append([]string(nil), p.ImageURL...)
+	p.Categories = append([]string(nil), p.Categories...)
+	if p.Variants != nil {
+		variants := make([]Variant, len(p.Variants))
+		for i, v := range p.Variants {
+			v.ImageURL = append([]string(nil), v.ImageURL...)
+			variants[i] = v
+		}
+		p.Variants = variants
+	}
+	return p
+}
@@ -0,0 +1,199 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+package catalogue
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+var testFixture = Fixture{
+	Categories: []Category{{Name: "Bowls"}, {Name: "Toys"}, {Name: "Food"}},
+	Products: []Product{
+		{ID: "A", Brand: "Acme", Title: "Steel bowl", Description: "A bowl for food.", Colors: "Red, Blue", Price: 9.99, Qty: 3, Categories: []string{"Bowls"}},
+		{ID: "B", Brand: "Acme", Title: "Mouse toy", Description: "Fits in a bowl.", Colors: "red", Price: 4.5, Qty: 10, Categories: []string{"Toys"}},
+		{ID: "C", Brand: "Chow", Title: "Dry food", Description: "Crunchy.", Colors: "0", Price: 25, Qty: 0, Categories: []string{"Food", "Bowls"}},
+		{ID: "D", Brand: "Chow", Title: "Wet food", Description: "Tasty.", Colors: "0", Price: 4.5, Qty: 7},
+	},
+}
+
+func newTestMemoryService(t *testing.T) Service {
+	store, err := NewMemoryStore(testFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewCatalogueService(store)
+}
+
+func TestMemoryStoreFixture(t *testing.T) {
+	ctx := context.Background()
+	fixture, err := ReadFixture("dbdata/catalogue.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewMemoryStore(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := store.Count(ctx, Filter{}); n != 27 {
+		t.Errorf("Count: want 27, have %d", n)
+	}
+	if c, _ := store.Categories(ctx); len(c) != 19 {
+		t.Errorf("Categories: want 19, have %d", len(c))
+	}
+
+	bad := Fixture{Products: []Product{{ID: "A", Title: "A", Categories: []string{"Nope"}}}}
+	if _, err := NewMemoryStore(bad); err == nil {
+		t.Errorf("unknown category in fixture: want error")
+	}
+}
+
+func TestMemoryStoreList(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryService(t)
+	min := 4.6
+
+	for _, tc := range []struct {
+		filter Filter
+		order  string
+		want   []string
+	}{
+		{Filter{}, "", []string{"A", "B", "C", "D"}},
+		{Filter{}, "-price", []string{"C", "A", "D", "B"}},
+		{Filter{}, "qty", []string{"C", "A", "D", "B"}},
+		{Filter{Categories: []string{"Bowls", "Toys"}}, "", []string{"A", "B", "C"}},
+		{Filter{Categories: []string{"Bowls", "Food"}, MatchAll: true}, "", []string{"C"}},
+		{Filter{MinPrice: &min}, "", []string{"A", "C"}},
+		{Filter{Colors: []string{"RED"}}, "", []string{"A", "B"}},
+		{Filter{Brands: []string{"Chow"}}, "title", []string{"C", "D"}},
+	} {
+		products, _, err := s.List(ctx, tc.filter, tc.order, "", "", nil, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := productIDs(products); !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("%s %s: want %v, have %v", formatFilter(tc.filter), tc.order, tc.want, have)
+		}
+		if n, _ := s.Count(ctx, tc.filter); n != len(tc.want) {
+			t.Errorf("Count %s: want %d, have %d", formatFilter(tc.filter), len(tc.want), n)
+		}
+	}
+
+	// Pages by cursor, with ties on the price.
+	var ids []string
+	cursor := ""
+	for i := 0; i < 4; i++ {
+		products, next, err := s.List(ctx, Filter{}, "price", cursor, "", nil, 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, productIDs(products)...)
+		if cursor = next; cursor == "" {
+			break
+		}
+	}
+	if want := []string{"B", "D", "A", "C"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("pages by price: want %v, have %v", want, ids)
+	}
+}
+
+func TestMemoryStoreFacets(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryService(t)
+
+	facets, err := s.Facets(ctx, Filter{Brands: []string{"Acme"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []FacetCount{{"Acme", 2}, {"Chow", 2}}; !reflect.DeepEqual(facets.Brands, want) {
+		t.Errorf("Brands: want %v, have %v", want, facets.Brands)
+	}
+	if want := []FacetCount{{"red", 2}, {"blue", 1}}; !reflect.DeepEqual(facets.Colors, want) {
+		t.Errorf("Colors: want %v, have %v", want, facets.Colors)
+	}
+	if want := []FacetCount{{"Bowls", 1}, {"Toys", 1}}; !reflect.DeepEqual(facets.Categories, want) {
+		t.Errorf("Categories: want %v, have %v", want, facets.Categories)
+	}
+	var counts []int
+	for _, b := range facets.Prices {
+		counts = append(counts, b.Count)
+	}
+	if want := []int{2, 0, 0, 0, 0}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("Prices: want %v, have %v", want, counts)
+	}
+}
+
+func TestMemoryStoreSearch(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryService(t)
+
+	for query, want := range map[string][]string{
+		"bowls":      {"A", "B"},
+		"food":       {"C", "D", "A"},
+		"steel bowl": {"A"},
+		"nothing":    {},
+	} {
+		products, err := s.Search(ctx, query, nil, nil, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := productIDs(products); !reflect.DeepEqual(have, want) {
+			t.Errorf("%q: want %v, have %v", query, want, have)
+		}
+	}
+	products, _ := s.Search(ctx, "food", []string{"Bowls"}, nil, 1, 10)
+	if have, want := productIDs(products), []string{"C", "A"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("food in Bowls: want %v, have %v", want, have)
+	}
+}
+
+func TestMemoryStoreWrite(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryService(t)
+
+	if _, err := s.Create(ctx, Product{ID: "A", Title: "Again"}); err != ErrProductExists {
+		t.Errorf("Create existing: want %v, have %v", ErrProductExists, err)
+	}
+	if _, err := s.Create(ctx, Product{ID: "E", Title: "E", Categories: []string{"Nope"}}); err != ErrUnknownCategory {
+		t.Errorf("Create in unknown category: want %v, have %v", ErrUnknownCategory, err)
+	}
+	p, err := s.Create(ctx, Product{ID: "E", Title: "Bell", Categories: []string{"Toys"}})
+	if err != nil || p.Version != 1 {
+		t.Fatalf("Create: have %v, %v", p, err)
+	}
+
+	p.Title = "Ball"
+	if _, err := s.Update(ctx, Product{ID: "E", Title: "Ball", Version: 2}); err != ErrVersionConflict {
+		t.Errorf("Update stale: want %v, have %v", ErrVersionConflict, err)
+	}
+	if p, err = s.Update(ctx, p); err != nil || p.Version != 2 {
+		t.Fatalf("Update: have %v, %v", p, err)
+	}
+	if have, _ := s.Get(ctx, "E", "", nil); have.Title != "Ball" || have.Version != 2 {
+		t.Errorf("Get after Update: have %v", have)
+	}
+
+	if err := s.Delete(ctx, "E", 1); err != ErrVersionConflict {
+		t.Errorf("Delete stale: want %v, have %v", ErrVersionConflict, err)
+	}
+	if err := s.Delete(ctx, "E", 2); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, "E", "", nil); err != ErrNotFound {
+		t.Errorf("Get after Delete: want %v, have %v", ErrNotFound, err)
+	}
+	if err := s.Delete(ctx, "E", 2); err != ErrNotFound {
+		t.Errorf("Delete again: want %v, have %v", ErrNotFound, err)
+	}
+}
+
+func productIDs(products []Product) []string {
+	ids := []string{}
+	for _, p := range products {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
@@ -0,0 +1,257 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// migrate.go contains the versioned migrations of the PostgreSQL schema of
+// the catalogue, embedded from the migrations directory, and their runner.
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is a step of the schema, applied by its up script and reverted
+// by its down script. Migration n is named migrations/000n_name.up.sql and
+// migrations/000n_name.down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migrations are the embedded migrations, by version.
+var migrations = loadMigrations()
+
+func loadMigrations() []Migration {
+	files, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		panic(err)
+	}
+	byVersion := make(map[int]*Migration)
+	for _, f := range files {
+		m := migrationName.FindStringSubmatch(f.Name())
+		if m == nil {
+			panic("migrations: unexpected file " + f.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		b, err := migrationFiles.ReadFile(path.Join("migrations", f.Name()))
+		if err != nil {
+			panic(err)
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		}
+		if m[3] == "up" {
+			migration.up = string(b)
+		} else {
+			migration.down = string(b)
+		}
+	}
+
+	result := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	for i, m := range result {
+		if m.Version != i+1 || m.up == "" || m.down == "" {
+			panic(fmt.Sprintf("migrations: %04d_%s is out of sequence or misses a script", m.Version, m.Name))
+		}
+	}
+	return result
+}
+
+// Migrations returns the migrations of the schema, oldest first.
+func Migrations() []Migration {
+	return append([]Migration{}, migrations...)
+}
+
+// SchemaVersion returns the version of the schema the code expects, that of
+// the last migration.
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// ErrSchemaOutdated is returned when the schema of the database is older
+// than the code expects.
+var ErrSchemaOutdated = errors.New("database schema is outdated")
+
+// MigrationStatus tells whether a migration is applied to a database.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time // nil when pending
+}
+
+// migrationLock is the key of the advisory lock serializing migrations, so
+// that replicas starting together do not apply the same migration twice.
+const migrationLock = 7217401
+
+// Migrator applies migrations to a database, recording the applied ones in
+// the schema_migrations table. Its queries stop when their context is done.
+type Migrator struct {
+	db *sqlx.DB
+}
+
+// NewMigrator returns a migrator of the database.
+func NewMigrator(db *sqlx.DB) *Migrator {
+	return &Migrator{db: db}
+}
+
+// Version returns the version of the schema, 0 for a database without
+// migrations.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	var version int
+	err := m.db.GetContext(ctx, &version, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations")
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "42P01" { // undefined_table
+		return 0, nil
+	}
+	return version, err
+}
+
+// Check returns ErrSchemaOutdated if the database lacks migrations the code
+// expects. A newer schema is fine: migrations keep the previous version of
+// the code working.
+func (m *Migrator) Check(ctx context.Context) error {
+	version, err := m.Version(ctx)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return err
+	}
+	if version < SchemaVersion() {
+		return fmt.Errorf("%w: version %d, expected %d", ErrSchemaOutdated, version, SchemaVersion())
+	}
+	return nil
+}
+
+// Status returns the migrations and whether they are applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	type applied struct {
+		Version   int       `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	var rows []applied
+	err := m.db.SelectContext(ctx, &rows, "SELECT version, applied_at FROM schema_migrations")
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "42P01" { // undefined_table
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	appliedAt := make(map[int]time.Time, len(rows))
+	for _, r := range rows {
+		appliedAt[r.Version] = r.AppliedAt
+	}
+
+	status := make([]MigrationStatus, len(migrations))
+	for i, migration := range migrations {
+		status[i].Migration = migration
+		if t, ok := appliedAt[migration.Version]; ok {
+			status[i].AppliedAt = &t
+		}
+	}
+	return status, nil
+}
+
+// Up applies the pending migrations, each in a transaction of its own, and
+// returns those it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	for _, migration := range migrations {
+		ok, err := m.apply(ctx, migration)
+		if err != nil {
+			return applied, fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		if ok {
+			applied = append(applied, migration)
+		}
+	}
+	return applied, nil
+}
+
+// apply runs the up script of a migration, unless it is applied already.
+func (m *Migrator) apply(ctx context.Context, migration Migration) (bool, error) {
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err = tx.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", migration.Version); err != nil || exists {
+		return false, err
+	}
+	if _, err = tx.ExecContext(ctx, migration.up); err != nil {
+		return false, err
+	}
+	if _, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// Down reverts the last applied migration and returns it, or nil if there
+// was none.
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	tx, err := m.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var version int
+	if err = tx.GetContext(ctx, &version, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations"); err != nil || version == 0 {
+		return nil, err
+	}
+	if version > len(migrations) {
+		return nil, fmt.Errorf("migration %04d is newer than this build", version)
+	}
+	migration := migrations[version-1]
+	if _, err = tx.ExecContext(ctx, migration.down); err != nil {
+		return nil, fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", version); err != nil {
+		return nil, err
+	}
+	return &migration, tx.Commit()
+}
+
+// begin starts a transaction holding the migration lock, in which the
+// schema_migrations table exists.
+func (m *Migrator) begin(ctx context.Context) (*sqlx.Tx, error) {
+	tx, err := m.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLock); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if _, err = tx.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMPTZ NOT NULL DEFAULT now())"); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
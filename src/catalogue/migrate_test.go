@@ -0,0 +1,151 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+package catalogue
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+func TestMigrations(t *testing.T) {
+	all := Migrations()
+	if len(all) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for i, m := range all {
+		if m.Version != i+1 || m.Name == "" || m.up == "" || m.down == "" {
+			t.Errorf("migration %d: have %d %q", i+1, m.Version, m.Name)
+		}
+	}
+	if SchemaVersion() != len(all) {
+		t.Errorf("SchemaVersion(): want %d, have %d", len(all), SchemaVersion())
+	}
+}
+
+// expectMigrationTx expects the start of a migration transaction.
+func expectMigrationTx(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).
+		WithArgs(migrationLock).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestMigratorUp(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	m := NewMigrator(sqlx.NewDb(db, "sqlmock"))
+
+	// The first migration is applied already.
+	for _, migration := range migrations {
+		expectMigrationTx(mock)
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)")).
+			WithArgs(migration.Version).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(migration.Version == 1))
+		if migration.Version == 1 {
+			mock.ExpectRollback()
+			continue
+		}
+		mock.ExpectExec(regexp.QuoteMeta(migration.up)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO schema_migrations (version, name) VALUES ($1, $2)")).
+			WithArgs(migration.Version, migration.Name).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations)-1 || applied[0].Version != 2 {
+		t.Errorf("Up(): want migrations 2 to %d, have %v", len(migrations), applied)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMigratorDown(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	m := NewMigrator(sqlx.NewDb(db, "sqlmock"))
+
+	last := migrations[len(migrations)-1]
+	expectMigrationTx(mock)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(version), 0) FROM schema_migrations")).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(last.Version))
+	mock.ExpectExec(regexp.QuoteMeta(last.down)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM schema_migrations WHERE version = $1")).
+		WithArgs(last.Version).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Nothing left to revert.
+	expectMigrationTx(mock)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(version), 0) FROM schema_migrations")).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(0))
+	mock.ExpectRollback()
+
+	if reverted, err := m.Down(ctx); err != nil || reverted == nil || reverted.Version != last.Version {
+		t.Errorf("Down(): want %d, have %v, %v", last.Version, reverted, err)
+	}
+	if reverted, err := m.Down(ctx); err != nil || reverted != nil {
+		t.Errorf("Down() at version 0: want nothing, have %v, %v", reverted, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMigratorCheck(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	m := NewMigrator(sqlx.NewDb(db, "sqlmock"))
+
+	query := regexp.QuoteMeta("SELECT COALESCE(MAX(version), 0) FROM schema_migrations")
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(SchemaVersion()))
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(SchemaVersion() + 1))
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(SchemaVersion() - 1))
+	mock.ExpectQuery(query).WillReturnError(&pq.Error{Code: "42P01"})
+	mock.ExpectQuery(query).WillDelayFor(time.Second).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(SchemaVersion()))
+
+	for _, want := range []error{nil, nil, ErrSchemaOutdated, ErrSchemaOutdated} {
+		if err := m.Check(ctx); !errors.Is(err, want) {
+			t.Errorf("Check(): want %v, have %v", want, err)
+		}
+	}
+
+	// A database that does not answer in time fails the check.
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := m.Check(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Check() past its deadline: want %v, have %v", context.DeadlineExceeded, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
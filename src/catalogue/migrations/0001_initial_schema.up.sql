@@ -0,0 +1,27 @@
+-- The catalogue as it was before it was versioned. IF NOT EXISTS adopts the
+-- tables of databases set up by hand.
+CREATE TABLE IF NOT EXISTS products (
+    sku VARCHAR(20) NOT NULL PRIMARY KEY,
+    brand VARCHAR(20),
+    title VARCHAR(40),
+    description VARCHAR(500),
+    weight VARCHAR(10),
+    product_size VARCHAR(25),
+    colors VARCHAR(20),
+    qty INTEGER,
+    price NUMERIC(10, 2),
+    image_url_1 VARCHAR(50),
+    image_url_2 VARCHAR(50)
+);
+
+CREATE TABLE IF NOT EXISTS categories (
+    category_id SERIAL PRIMARY KEY,
+    name VARCHAR(30) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS product_category (
+    product_category_id SERIAL PRIMARY KEY,
+    sku VARCHAR(40) NOT NULL REFERENCES products (sku),
+    category_id INTEGER NOT NULL REFERENCES categories (category_id),
+    UNIQUE (sku, category_id)
+);
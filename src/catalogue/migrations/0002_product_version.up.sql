@@ -0,0 +1,2 @@
+-- Versions guard writes against lost updates.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,7 @@
+-- The expression must stay the same as searchDocument for searches to use
+-- the index.
+CREATE INDEX IF NOT EXISTS products_search ON products USING GIN ((
+    setweight(to_tsvector('english', COALESCE(products.title, '')), 'A') ||
+    setweight(to_tsvector('english', COALESCE(products.brand, '')), 'B') ||
+    setweight(to_tsvector('english', COALESCE(products.description, '')), 'C')
+));
@@ -0,0 +1,3 @@
+-- Prices are in the currency of their product. Products priced before
+-- currencies were in US dollars.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
@@ -0,0 +1,9 @@
+-- Titles and descriptions of products in other locales than the English of
+-- the products table. Translations go with their product.
+CREATE TABLE IF NOT EXISTS product_translation (
+    sku VARCHAR(20) NOT NULL REFERENCES products (sku) ON DELETE CASCADE,
+    locale VARCHAR(35) NOT NULL,
+    title VARCHAR(80) NOT NULL,
+    description VARCHAR(1000) NOT NULL DEFAULT '',
+    PRIMARY KEY (sku, locale)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE products ALTER COLUMN colors TYPE VARCHAR(20) USING left(colors, 20), ALTER COLUMN product_size TYPE VARCHAR(25) USING left(product_size, 25);
+DROP TABLE IF EXISTS product_variant;
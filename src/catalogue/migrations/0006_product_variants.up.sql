@@ -0,0 +1,18 @@
+-- Variants are the colours and sizes a product comes in, each a SKU of its
+-- own with its stock and price delta. They go with their product, whose
+-- colors and product_size list those of all its variants, hence the longer
+-- columns.
+CREATE TABLE IF NOT EXISTS product_variant (
+    sku VARCHAR(40) NOT NULL PRIMARY KEY,
+    product_sku VARCHAR(20) NOT NULL REFERENCES products (sku) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    color VARCHAR(20) NOT NULL DEFAULT '',
+    size VARCHAR(25) NOT NULL DEFAULT '',
+    price_delta NUMERIC(10, 2) NOT NULL DEFAULT 0,
+    qty INTEGER NOT NULL DEFAULT 0,
+    image_url_1 VARCHAR(50) NOT NULL DEFAULT '',
+    image_url_2 VARCHAR(50) NOT NULL DEFAULT '',
+    UNIQUE (product_sku, position)
+);
+
+ALTER TABLE products ALTER COLUMN colors TYPE VARCHAR(200), ALTER COLUMN product_size TYPE VARCHAR(200);
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS products_price_history ON products;
+DROP FUNCTION IF EXISTS record_price_change();
+DROP TABLE IF EXISTS price_history;
+DROP TABLE IF EXISTS promotions;
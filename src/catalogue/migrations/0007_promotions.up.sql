@@ -0,0 +1,45 @@
+-- Promotions put products on sale between two times: those of a SKU, of a
+-- category or of a brand, by a percentage or an amount off their price.
+-- Expired promotions are kept, for audit.
+CREATE TABLE IF NOT EXISTS promotions (
+    id VARCHAR(40) NOT NULL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL DEFAULT '',
+    sku VARCHAR(20) NOT NULL DEFAULT '',
+    category VARCHAR(30) NOT NULL DEFAULT '',
+    brand VARCHAR(20) NOT NULL DEFAULT '',
+    percent_off NUMERIC(5, 2) NOT NULL DEFAULT 0,
+    amount_off NUMERIC(10, 2) NOT NULL DEFAULT 0,
+    starts TIMESTAMPTZ NOT NULL,
+    ends TIMESTAMPTZ NOT NULL,
+    created TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK ((sku <> '')::int + (category <> '')::int + (brand <> '')::int = 1),
+    CHECK (starts <= ends)
+);
+
+CREATE INDEX IF NOT EXISTS promotions_ends ON promotions (ends);
+
+-- Every price a product had, recorded by a trigger whatever writes it.
+CREATE TABLE IF NOT EXISTS price_history (
+    id BIGSERIAL PRIMARY KEY,
+    sku VARCHAR(20) NOT NULL,
+    price NUMERIC(10, 2),
+    currency CHAR(3) NOT NULL,
+    changed TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS price_history_sku ON price_history (sku, changed);
+
+CREATE OR REPLACE FUNCTION record_price_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' OR NEW.price IS DISTINCT FROM OLD.price OR NEW.currency IS DISTINCT FROM OLD.currency THEN
+        INSERT INTO price_history (sku, price, currency) VALUES (NEW.sku, NEW.price, NEW.currency);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER products_price_history AFTER INSERT OR UPDATE OF price, currency ON products
+    FOR EACH ROW EXECUTE FUNCTION record_price_change();
+
+-- The history starts with the current prices.
+INSERT INTO price_history (sku, price, currency) SELECT sku, price, currency FROM products;
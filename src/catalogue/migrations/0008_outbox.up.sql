@@ -0,0 +1,13 @@
+-- The outbox holds the changes of products, recorded in the transaction
+-- making them, until the publisher relays them to Kafka. Published changes
+-- are kept, so that they can be replayed from an offset, their id.
+CREATE TABLE IF NOT EXISTS catalogue_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(40) NOT NULL,
+    sku VARCHAR(20) NOT NULL,
+    detail JSONB NOT NULL,
+    created TIMESTAMPTZ NOT NULL DEFAULT now(),
+    published TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS catalogue_outbox_unpublished ON catalogue_outbox (id) WHERE published IS NULL;
@@ -0,0 +1,14 @@
+-- Reservations hold units of the stock of a product for a cart until they
+-- expire, or are committed by an order, which takes the units out of the
+-- stock. Expired reservations no longer hold any, and are released lazily.
+CREATE TABLE IF NOT EXISTS reservations (
+    id VARCHAR(40) NOT NULL PRIMARY KEY,
+    sku VARCHAR(20) NOT NULL REFERENCES products (sku) ON DELETE CASCADE,
+    cart VARCHAR(40) NOT NULL,
+    qty INTEGER NOT NULL CHECK (qty > 0),
+    expires TIMESTAMPTZ NOT NULL,
+    created TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS reservations_sku ON reservations (sku, expires);
+CREATE INDEX IF NOT EXISTS reservations_expires ON reservations (expires);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS categories_parent;
+DROP INDEX IF EXISTS categories_slug;
+ALTER TABLE categories
+    DROP COLUMN IF EXISTS description,
+    DROP COLUMN IF EXISTS position,
+    DROP COLUMN IF EXISTS slug,
+    DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,14 @@
+-- Categories form a tree: a category may have a parent, and is listed among
+-- its siblings by position, then name. Slugs name categories in URLs. The
+-- categories there are become roots, with slugs derived from their names.
+ALTER TABLE categories
+    ADD COLUMN IF NOT EXISTS parent_id INTEGER REFERENCES categories (category_id),
+    ADD COLUMN IF NOT EXISTS slug VARCHAR(40),
+    ADD COLUMN IF NOT EXISTS position INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS description VARCHAR(500) NOT NULL DEFAULT '';
+
+UPDATE categories SET slug = trim(BOTH '-' FROM lower(regexp_replace(name, '[^a-zA-Z0-9]+', '-', 'g'))) WHERE slug IS NULL;
+ALTER TABLE categories ALTER COLUMN slug SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS categories_slug ON categories (slug);
+CREATE INDEX IF NOT EXISTS categories_parent ON categories (parent_id);
@@ -0,0 +1,16 @@
+-- Reviews rate products from 1 to 5 stars, with text. They are pending until
+-- a moderator approves or rejects them; the rating of a product averages its
+-- approved reviews.
+CREATE TABLE IF NOT EXISTS reviews (
+    id VARCHAR(40) NOT NULL PRIMARY KEY,
+    sku VARCHAR(20) NOT NULL REFERENCES products (sku) ON DELETE CASCADE,
+    author VARCHAR(60) NOT NULL,
+    rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    text VARCHAR(2000) NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    created TIMESTAMPTZ NOT NULL DEFAULT now(),
+    moderated TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS reviews_sku ON reviews (sku, status, created DESC);
+CREATE INDEX IF NOT EXISTS reviews_status ON reviews (status, created DESC);
@@ -0,0 +1 @@
+ALTER TABLE promotions DROP CONSTRAINT IF EXISTS promotions_amount_currency, DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,6 @@
+-- Amounts off are in the currency of their promotion, and apply to the
+-- products priced in it alone. Those created before currencies were in US
+-- dollars.
+ALTER TABLE promotions ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT '';
+UPDATE promotions SET currency = 'USD' WHERE amount_off <> 0 AND currency = '';
+ALTER TABLE promotions ADD CONSTRAINT promotions_amount_currency CHECK ((amount_off <> 0) = (currency <> ''));
@@ -0,0 +1,135 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// outbox.go contains the change feed of the catalogue: the changes of
+// products that writes record in an outbox, in the same transaction, and the
+// publisher relaying them to Kafka for the systems that follow the catalogue.
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/go-kit/kit/log"
+)
+
+// The types of the changes of products.
+const (
+	ProductCreated = "product.created"
+	ProductUpdated = "product.updated"
+	ProductDeleted = "product.deleted"
+)
+
+// Change is a change of a product recorded in the outbox. Its offset orders
+// it among all changes. Its detail tells the product ID and the version the
+// change made, or deleted, and the price it left the product at, if any.
+type Change struct {
+	Offset int64           `json:"offset" db:"id"`
+	Type   string          `json:"type" db:"type"`
+	ID     string          `json:"id" db:"sku"`
+	Detail json.RawMessage `json:"detail" db:"detail"`
+	Time   time.Time       `json:"time" db:"created"`
+}
+
+// changeDetail is the detail of a change.
+type changeDetail struct {
+	ID       string  `json:"id"`
+	Version  int     `json:"version"`
+	Price    float32 `json:"price,omitempty"`
+	Currency string  `json:"currency,omitempty"`
+}
+
+// Event and EventRecord are the envelope of the events service, which
+// changes are published in too.
+type Event struct {
+	Time   string      `json:"time"`
+	Type   string      `json:"type"`
+	Detail interface{} `json:"detail"`
+}
+
+type EventRecord struct {
+	Event
+	Source string `json:"source"`
+	Track  string `json:"track"`
+}
+
+// changeRecord returns the event record of a change, tracked by product.
+func changeRecord(c Change) EventRecord {
+	return EventRecord{
+		Event:  Event{Time: c.Time.UTC().Format(time.RFC3339Nano), Type: c.Type, Detail: c.Detail},
+		Source: "catalogue",
+		Track:  c.ID,
+	}
+}
+
+// ChangePublisher relays the changes recorded in the outbox of a store to a
+// Kafka topic, oldest first and keyed by product ID. Changes are marked
+// published once Kafka has them, so a change may be published more than once
+// but is never lost: consumers tell repeats by product ID and version.
+type ChangePublisher struct {
+	store    Store
+	producer sarama.SyncProducer
+	topic    string
+	logger   log.Logger
+
+	// Batch is the most changes published at once, and Interval the time
+	// waited for more once the outbox is drained.
+	Batch    int
+	Interval time.Duration
+}
+
+// NewChangePublisher returns a publisher of the changes of the store to the
+// topic, through a producer waiting for acknowledgements.
+func NewChangePublisher(store Store, producer sarama.SyncProducer, topic string, logger log.Logger) *ChangePublisher {
+	return &ChangePublisher{
+		store:    store,
+		producer: producer,
+		topic:    topic,
+		logger:   logger,
+		Batch:    100,
+		Interval: time.Second,
+	}
+}
+
+// Run publishes changes until the context is done.
+func (p *ChangePublisher) Run(ctx context.Context) {
+	for {
+		n, err := p.Publish(ctx)
+		if err != nil {
+			p.logger.Log("outbox", "publish", "err", err)
+		}
+		if n < p.Batch || err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(p.Interval):
+			}
+		}
+	}
+}
+
+// Publish publishes the next batch of changes, and returns how many.
+func (p *ChangePublisher) Publish(ctx context.Context) (int, error) {
+	return p.store.PublishChanges(ctx, p.Batch, p.send)
+}
+
+func (p *ChangePublisher) send(changes []Change) error {
+	messages := make([]*sarama.ProducerMessage, len(changes))
+	for i, c := range changes {
+		value, err := json.Marshal(changeRecord(c))
+		if err != nil {
+			return err
+		}
+		messages[i] = &sarama.ProducerMessage{
+			Topic: p.topic,
+			Key:   sarama.StringEncoder(c.ID),
+			Value: sarama.ByteEncoder(value),
+		}
+	}
+	return p.producer.SendMessages(messages)
+}
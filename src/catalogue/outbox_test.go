@@ -0,0 +1,154 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+package catalogue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/go-kit/kit/log"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestChangePublisher(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewMemoryStore(testFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewCatalogueService(store)
+	p, _ := s.Get(ctx, "B", "", nil)
+	p.Price = 5
+	if _, err := s.Update(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "D", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	publisher := NewChangePublisher(store, producer, "catalogue", log.NewNopLogger())
+	publisher.Batch = 4
+
+	var records []string
+	record := func(value []byte) error {
+		var r struct {
+			Type   string       `json:"type"`
+			Source string       `json:"source"`
+			Track  string       `json:"track"`
+			Detail changeDetail `json:"detail"`
+		}
+		if err := json.Unmarshal(value, &r); err != nil || r.Source != "catalogue" || r.Track != r.Detail.ID {
+			return errors.New("not a catalogue event record: " + string(value))
+		}
+		records = append(records, r.Type+" "+r.Track)
+		return nil
+	}
+	for i := 0; i < 4; i++ {
+		producer.ExpectSendMessageWithCheckerFunctionAndSucceed(record)
+	}
+	if n, err := publisher.Publish(ctx); n != 4 || err != nil {
+		t.Errorf("Publish: want 4, have %d, %v", n, err)
+	}
+	producer.ExpectSendMessageAndSucceed()
+	producer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	if n, err := publisher.Publish(ctx); n != 0 || err == nil {
+		t.Errorf("Publish failing: want 0 and an error, have %d, %v", n, err)
+	}
+	for i := 0; i < 2; i++ {
+		producer.ExpectSendMessageWithCheckerFunctionAndSucceed(record)
+	}
+	if n, err := publisher.Publish(ctx); n != 2 || err != nil {
+		t.Errorf("Publish again: want 2, have %d, %v", n, err)
+	}
+	if n, err := publisher.Publish(ctx); n != 0 || err != nil {
+		t.Errorf("Publish with none left: want 0, have %d, %v", n, err)
+	}
+	want := []string{"product.created A", "product.created B", "product.created C", "product.created D", "product.updated B", "product.deleted D"}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("published: want %q, have %q", want, records)
+	}
+
+	if n, err := s.ReplayChanges(ctx, 5); n != 2 || err != nil {
+		t.Errorf("ReplayChanges(5): want 2, have %d, %v", n, err)
+	}
+	records = nil
+	for i := 0; i < 2; i++ {
+		producer.ExpectSendMessageWithCheckerFunctionAndSucceed(record)
+	}
+	publisher.Publish(ctx)
+	if want := want[4:]; !reflect.DeepEqual(records, want) {
+		t.Errorf("replayed: want %q, have %q", want, records)
+	}
+}
+
+func TestReplayChangesHTTP(t *testing.T) {
+	h := newTestHandler(newTestMemoryService(t))
+
+	for path, want := range map[string]int{
+		"/changes/replay?from=1": http.StatusOK,
+		"/changes/replay?from=0": http.StatusBadRequest,
+		"/changes/replay":        http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", path, nil))
+		if rec.Code != want {
+			t.Errorf("POST %s: want %d, have %d", path, want, rec.Code)
+		}
+	}
+}
+
+func TestPostgresStorePublishChanges(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	store := NewPostgresStore(sqlx.NewDb(db, "sqlmock"), logger)
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	cols := []string{"id", "type", "sku", "detail", "created"}
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM catalogue_outbox WHERE published IS NULL ORDER BY id LIMIT \\$1 FOR UPDATE SKIP LOCKED").WithArgs(10).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(7, ProductCreated, "1", []byte(`{"id": "1", "version": 1}`), now).AddRow(8, ProductDeleted, "1", []byte(`{"id": "1", "version": 1}`), now))
+	mock.ExpectExec("UPDATE catalogue_outbox SET published = now\\(\\) WHERE id = ANY\\(\\$1\\)").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM catalogue_outbox").WithArgs(10).WillReturnRows(sqlmock.NewRows(cols).AddRow(9, ProductCreated, "2", []byte(`{}`), now))
+	mock.ExpectRollback()
+	mock.ExpectExec("UPDATE catalogue_outbox SET published = NULL WHERE id >= \\$1 AND published IS NOT NULL").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 2))
+
+	var offsets []int64
+	n, err := store.PublishChanges(ctx, 10, func(changes []Change) error {
+		for _, c := range changes {
+			offsets = append(offsets, c.Offset)
+		}
+		return nil
+	})
+	if want := []int64{7, 8}; n != 2 || err != nil || !reflect.DeepEqual(offsets, want) {
+		t.Errorf("PublishChanges: want %v, have %d %v, %v", want, n, offsets, err)
+	}
+	failed := errors.New("no brokers")
+	if _, err := store.PublishChanges(ctx, 10, func([]Change) error { return failed }); err != failed {
+		t.Errorf("PublishChanges failing: want %v, have %v", failed, err)
+	}
+	if n, err := store.ReplayChanges(ctx, 7); n != 2 || err != nil {
+		t.Errorf("ReplayChanges: want 2, have %d, %v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,1118 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// postgres.go contains the store keeping the catalogue in a PostgreSQL
+// database.
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// variantsColumn is the variants of a product as a JSON array, in the shape
+// of Variant, or empty.
+var variantsColumn = "COALESCE((SELECT json_agg(json_build_object('id', product_variant.sku, 'color', product_variant.color, 'size', product_variant.size, 'priceDelta', product_variant.price_delta, 'qty', product_variant.qty, 'imageUrl', json_build_array(product_variant.image_url_1, product_variant.image_url_2)) ORDER BY product_variant.position) FROM product_variant WHERE product_variant.product_sku = products.sku)::text, '') AS variants"
+
+// promotionColumns are the sale price and promotion of a product, from
+// promotionJoin.
+var promotionColumns = "COALESCE(promotion.sale_price, 0) AS sale_price, COALESCE(promotion.id, '') AS promotion_id"
+
+// availableColumn is the stock of a product not held by reservations that
+// have not expired, as available computes it.
+var availableColumn = "GREATEST(COALESCE(products.qty, 0) - COALESCE((SELECT SUM(reservations.qty) FROM reservations WHERE reservations.sku = products.sku AND reservations.expires > now()), 0), 0) AS available"
+
+// ratingColumns are the rating of a product and the number of its approved
+// reviews, from ratingJoin.
+var ratingColumns = "COALESCE(ratings.rating, 0) AS rating, ratings.review_count"
+
+// ratingJoin joins the rating of each product, as averageRating computes it.
+var ratingJoin = "LEFT JOIN LATERAL (SELECT ROUND(AVG(reviews.rating), 2)::float8 AS rating, COUNT(*) AS review_count FROM reviews WHERE reviews.sku = products.sku AND reviews.status = 'approved') ratings ON true"
+
+var baseColumns = "products.sku AS id, products.brand, products.title, products.description, products.weight, products.product_size, products.colors, products.qty, " + availableColumn + ", " + ratingColumns + ", products.price, products.currency, products.image_url_1, products.image_url_2, products.version, categories_name, " + variantsColumn + ", " + promotionColumns
+
+var categoriesJoin = "LEFT JOIN (SELECT product_category.sku , STRING_AGG(categories.name, ', ' ORDER BY product_category.sku) AS categories_name FROM product_category LEFT OUTER JOIN categories ON product_category.category_id=categories.category_id GROUP BY product_category.sku) categoriesbundle ON products.sku=categoriesbundle.sku"
+
+// promotionJoin joins the promotion active now that lowers the price of each
+// product the most, as promote picks it: amounts off apply to products in
+// their currency alone.
+var promotionJoin = "LEFT JOIN LATERAL (SELECT promotions.id, GREATEST(products.price * (100 - promotions.percent_off) / 100 - promotions.amount_off, 0) AS sale_price FROM promotions WHERE promotions.starts <= now() AND now() < promotions.ends AND (promotions.amount_off = 0 OR promotions.currency = products.currency) AND (promotions.sku = products.sku OR promotions.brand <> '' AND promotions.brand = products.brand OR promotions.category IN (SELECT categories.name FROM product_category JOIN categories ON categories.category_id = product_category.category_id WHERE product_category.sku = products.sku)) ORDER BY 2, promotions.id LIMIT 1) promotion ON true"
+
+// searchDocument is the weighted full-text document a product is matched
+// against: title ranks above brand, which ranks above description.
+var searchDocument = "setweight(to_tsvector('english', COALESCE(products.title, '')), 'A') || setweight(to_tsvector('english', COALESCE(products.brand, '')), 'B') || setweight(to_tsvector('english', COALESCE(products.description, '')), 'C')"
+
+// translatedSearchDocument is searchDocument over the translated title and
+// description, in the text search configuration given by %[1]s. Being an
+// expression of the joined translation, it cannot use the index of
+// searchDocument.
+var translatedSearchDocument = "setweight(to_tsvector('%[1]s', COALESCE(translation.title, products.title, '')), 'A') || setweight(to_tsvector('%[1]s', COALESCE(products.brand, '')), 'B') || setweight(to_tsvector('%[1]s', COALESCE(NULLIF(translation.description, ''), products.description, '')), 'C')"
+
+// searchConfigs are the text search configurations of PostgreSQL by
+// language, which stem the words of their language. Others are searched
+// without stemming.
+var searchConfigs = map[string]string{
+	"da": "danish",
+	"de": "german",
+	"en": "english",
+	"es": "spanish",
+	"fi": "finnish",
+	"fr": "french",
+	"hu": "hungarian",
+	"it": "italian",
+	"nl": "dutch",
+	"no": "norwegian",
+	"pt": "portuguese",
+	"ro": "romanian",
+	"ru": "russian",
+	"sv": "swedish",
+	"tr": "turkish",
+}
+
+// searchConfig returns the text search configuration of a locale.
+func searchConfig(locale string) string {
+	if config, ok := searchConfigs[strings.SplitN(locale, "-", 2)[0]]; ok {
+		return config
+	}
+	return "simple"
+}
+
+// sortColumns maps the sort keys to their column, but for the price, which
+// is that of the filter. Nullable columns are coalesced so that they compare,
+// and so can be used in keyset pagination.
+var sortColumns = map[string]string{
+	"id":     "products.sku",
+	"title":  "COALESCE(products.title, '')",
+	"brand":  "COALESCE(products.brand, '')",
+	"qty":    "COALESCE(products.qty, 0)",
+	"rating": "COALESCE(ratings.rating, 0)",
+}
+
+// translatedSortColumns replace sortColumns for products presented in other
+// locales.
+var translatedSortColumns = map[string]string{
+	"title": "COALESCE(translation.title, products.title, '')",
+}
+
+// sortColumn returns the column of a sort key, the translated one if there is
+// one and products are translated, and the price in the currency of the
+// filter for the price.
+func (c *conditions) sortColumn(key string, f Filter, translated bool) string {
+	if key == "price" {
+		return "COALESCE(" + c.priceColumn(f) + ", 0)"
+	}
+	if column, ok := translatedSortColumns[key]; ok && translated {
+		return column
+	}
+	return sortColumns[key]
+}
+
+// orderBy returns the ORDER BY clause of an order by a column. Ties are broken
+// by SKU, in the same direction, so that the order is total and pages never
+// overlap.
+func orderBy(order Sort, column string) string {
+	direction := "ASC"
+	if order.Descending {
+		direction = "DESC"
+	}
+	if column == "products.sku" {
+		return column + " " + direction
+	}
+	return column + " " + direction + ", products.sku " + direction
+}
+
+// addAfter adds the condition selecting the rows that follow a position in
+// an order by a column.
+func (c *conditions) addAfter(order Sort, after Position, column string) {
+	op := ">"
+	if order.Descending {
+		op = "<"
+	}
+	if column == "products.sku" {
+		c.add("products.sku "+op+" ?", after.ID)
+		return
+	}
+	c.add("("+column+", products.sku) "+op+" (?, ?)", after.Value, after.ID)
+}
+
+// translatedColumns replace baseColumns for products presented in other
+// locales, taking the title and description from the translation joined by
+// translationJoin when there is one.
+var translatedColumns = "products.sku AS id, products.brand, COALESCE(translation.title, products.title) AS title, COALESCE(NULLIF(translation.description, ''), products.description) AS description, products.weight, products.product_size, products.colors, products.qty, " + availableColumn + ", " + ratingColumns + ", products.price, products.currency, products.image_url_1, products.image_url_2, products.version, categories_name, " + variantsColumn + ", " + promotionColumns + ", COALESCE(translation.locale, '') AS locale"
+
+// translationJoin joins the translation of each product in the first of the
+// locales, given by the placeholder of their array, it has one for.
+var translationJoin = "LEFT JOIN LATERAL (SELECT product_translation.title, product_translation.description, product_translation.locale FROM product_translation WHERE product_translation.sku = products.sku AND product_translation.locale = ANY(%[1]s) ORDER BY array_position(%[1]s, product_translation.locale) LIMIT 1) translation ON true"
+
+var baseGroupBy = "products.sku, products.brand, products.title, products.description, products.weight, products.product_size, products.colors, products.qty, products.price, products.currency, products.image_url_1, products.image_url_2, products.version, categories_name, promotion.id, promotion.sale_price, ratings.rating, ratings.review_count"
+
+// selectProducts returns the SELECT and FROM clauses of the queries of
+// products, and the columns they group by. Products are presented in the
+// locales if there are any, which comes first among the arguments.
+func selectProducts(where *conditions, locales []string) (query, groupBy string) {
+	if len(locales) == 0 {
+		return baseQuery, baseGroupBy
+	}
+	join := fmt.Sprintf(translationJoin, where.arg(pq.Array(locales)))
+	return "SELECT " + translatedColumns + " FROM products " + categoriesJoin + " " + promotionJoin + " " + ratingJoin + " " + join, baseGroupBy + ", translation.title, translation.description, translation.locale"
+}
+
+var baseQuery = "SELECT " + baseColumns + " FROM products " + categoriesJoin + " " + promotionJoin + " " + ratingJoin
+
+// NewPostgresStore returns a store keeping the catalogue in a PostgreSQL
+// database.
+func NewPostgresStore(db *sqlx.DB, logger log.Logger) Store {
+	return &postgresStore{
+		db:     db,
+		logger: logger,
+	}
+}
+
+type postgresStore struct {
+	db     *sqlx.DB
+	logger log.Logger
+}
+
+func (s *postgresStore) List(ctx context.Context, filter Filter, order Sort, locales []string, after *Position, offset, limit int) ([]Product, error) {
+	var products []Product
+	var where conditions
+	query, groupBy := selectProducts(&where, locales)
+	translated := len(locales) > 0
+
+	where.addCategories(filter)
+	where.addAttributes(filter)
+	column := where.sortColumn(order.Key, filter, translated)
+	if after != nil {
+		where.addAfter(order, *after, column)
+	}
+
+	query += where.where()
+
+	query += " GROUP BY " + groupBy
+
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %s OFFSET %s", orderBy(order, column), where.arg(limit), where.arg(offset))
+
+	err := s.db.SelectContext(ctx, &products, query, where.args...)
+	if err != nil {
+		return []Product{}, s.dbError(ctx, err)
+	}
+	for i, s := range products {
+		products[i].ImageURL = []string{s.ImageURL1, s.ImageURL2}
+		products[i].Categories = splitCategories(s.CategoryString)
+		products[i].Variants = parseVariants(s.VariantString)
+	}
+	if products == nil {
+		products = []Product{}
+	}
+
+	return products, nil
+}
+
+func (s *postgresStore) Count(ctx context.Context, filter Filter) (int, error) {
+	// No joins: the filter selects products, so counting them cannot count
+	// any twice, and counts products without categories just like List.
+	query := "SELECT COUNT(*) FROM products"
+
+	var where conditions
+	where.addCategories(filter)
+	where.addAttributes(filter)
+	query += where.where()
+
+	sel, err := s.db.PrepareContext(ctx, query)
+
+	if err != nil {
+		return 0, s.dbError(ctx, err)
+	}
+	defer sel.Close()
+
+	var count int
+	err = sel.QueryRowContext(ctx, where.args...).Scan(&count)
+
+	if err != nil {
+		return 0, s.dbError(ctx, err)
+	}
+
+	return count, nil
+}
+
+func (s *postgresStore) Facets(ctx context.Context, filter Filter) (Facets, error) {
+	var facets Facets
+	var err error
+
+	// Each facet ignores the filter on its own attribute.
+	unbranded := filter
+	unbranded.Brands = nil
+	facets.Brands, err = s.facetCounts(ctx, "products.brand", "", unbranded)
+	if err != nil {
+		return Facets{}, err
+	}
+
+	uncolored := filter
+	uncolored.Colors = nil
+	facets.Colors, err = s.facetCounts(ctx, "lower(color)", ", "+fmt.Sprintf(splitList, "products.colors")+" color", uncolored)
+	if err != nil {
+		return Facets{}, err
+	}
+
+	uncategorized := filter
+	uncategorized.Categories = nil
+	facets.Categories, err = s.facetCounts(ctx, "categories.name", " JOIN product_category ON products.sku=product_category.sku JOIN categories ON product_category.category_id=categories.category_id", uncategorized)
+	if err != nil {
+		return Facets{}, err
+	}
+
+	unpriced := filter
+	unpriced.MinPrice, unpriced.MaxPrice = nil, nil
+	facets.Prices, err = s.priceBuckets(ctx, unpriced)
+	if err != nil {
+		return Facets{}, err
+	}
+
+	return facets, nil
+}
+
+// facetCounts counts the distinct products matching the filter per value of
+// the given expression over products and the joined tables. Empty values,
+// and the "0" the catalogue data uses for "none", are left out.
+func (s *postgresStore) facetCounts(ctx context.Context, value, join string, filter Filter) ([]FacetCount, error) {
+	var where conditions
+	where.addCategories(filter)
+	where.addAttributes(filter)
+	where.add(value + " <> ''")
+	where.add(value + " <> '0'")
+
+	query := "SELECT " + value + ", COUNT(DISTINCT products.sku) FROM products" + join + where.where() + " GROUP BY 1 ORDER BY 2 DESC, 1"
+	rows, err := s.db.QueryContext(ctx, query, where.args...)
+	if err != nil {
+		return []FacetCount{}, s.dbError(ctx, err)
+	}
+	defer rows.Close()
+
+	counts := []FacetCount{}
+	for rows.Next() {
+		var c FacetCount
+		if err = rows.Scan(&c.Value, &c.Count); err != nil {
+			return []FacetCount{}, s.dbError(ctx, err)
+		}
+		counts = append(counts, c)
+	}
+	return counts, nil
+}
+
+// priceBuckets counts the products matching the filter per price bucket, in
+// the currency of the filter, including the empty buckets.
+func (s *postgresStore) priceBuckets(ctx context.Context, filter Filter) ([]PriceBucket, error) {
+	var where conditions
+	bounds := where.arg(pq.Array(priceBounds))
+	price := where.priceColumn(filter)
+	where.addCategories(filter)
+	where.addAttributes(filter)
+	where.add(price + " IS NOT NULL")
+
+	query := "SELECT width_bucket(" + price + "::float8, " + bounds + "::float8[]), COUNT(*) FROM products" + where.where() + " GROUP BY 1"
+	rows, err := s.db.QueryContext(ctx, query, where.args...)
+	if err != nil {
+		return []PriceBucket{}, s.dbError(ctx, err)
+	}
+	defer rows.Close()
+
+	buckets := newPriceBuckets()
+	for rows.Next() {
+		var bucket, count int
+		if err = rows.Scan(&bucket, &count); err != nil {
+			return []PriceBucket{}, s.dbError(ctx, err)
+		}
+		if bucket >= 0 && bucket < len(buckets) {
+			buckets[bucket].Count = count
+		}
+	}
+	return buckets, nil
+}
+
+func (s *postgresStore) Search(ctx context.Context, query string, categories []string, locales []string, offset, limit int) ([]Product, error) {
+	var where conditions
+	sql, _ := selectProducts(&where, locales)
+	terms := where.arg(query)
+	document, config := searchDocument, "english"
+	if len(locales) > 0 {
+		config = searchConfig(locales[0])
+		document = fmt.Sprintf(translatedSearchDocument, config)
+	}
+	where.add("(" + document + ") @@ query")
+	where.addCategories(Filter{Categories: categories})
+
+	sql += " CROSS JOIN plainto_tsquery('" + config + "', " + terms + ") query" + where.where()
+	sql += fmt.Sprintf(" ORDER BY ts_rank(%s, query) DESC, products.sku LIMIT %s OFFSET %s", document, where.arg(limit), where.arg(offset))
+
+	var products []Product
+	err := s.db.SelectContext(ctx, &products, sql, where.args...)
+	if err != nil {
+		return []Product{}, s.dbError(ctx, err)
+	}
+	for i, s := range products {
+		products[i].ImageURL = []string{s.ImageURL1, s.ImageURL2}
+		products[i].Categories = splitCategories(s.CategoryString)
+		products[i].Variants = parseVariants(s.VariantString)
+	}
+	if products == nil {
+		products = []Product{}
+	}
+
+	return products, nil
+}
+
+func (s *postgresStore) Get(ctx context.Context, id string, locales []string) (Product, error) {
+	var where conditions
+	query, groupBy := selectProducts(&where, locales)
+	where.add("products.sku = ?", id)
+	query += where.where() + " GROUP BY " + groupBy
+
+	var product Product
+	err := s.db.GetContext(ctx, &product, query, where.args...)
+	if err == sql.ErrNoRows {
+		return Product{}, ErrNotFound
+	}
+	if err != nil {
+		return Product{}, s.dbError(ctx, err)
+	}
+
+	product.ImageURL = []string{product.ImageURL1, product.ImageURL2}
+	product.Categories = splitCategories(product.CategoryString)
+	product.Variants = parseVariants(product.VariantString)
+
+	return product, nil
+}
+
+// GetMany reads the products in a single query, whatever their number.
+func (s *postgresStore) GetMany(ctx context.Context, ids []string, locales []string) ([]Product, error) {
+	var where conditions
+	query, groupBy := selectProducts(&where, locales)
+	where.add("products.sku = ANY(?)", pq.Array(ids))
+	query += where.where() + " GROUP BY " + groupBy
+
+	var products []Product
+	if err := s.db.SelectContext(ctx, &products, query, where.args...); err != nil {
+		return []Product{}, s.dbError(ctx, err)
+	}
+	for i, s := range products {
+		products[i].ImageURL = []string{s.ImageURL1, s.ImageURL2}
+		products[i].Categories = splitCategories(s.CategoryString)
+		products[i].Variants = parseVariants(s.VariantString)
+	}
+	if products == nil {
+		products = []Product{}
+	}
+	return products, nil
+}
+
+// relatedJoin joins the score of each product in relation to the product
+// whose ID is given by the placeholder %[1]s, as relatedScore computes it
+// from the co-view signals joined as coview.
+var relatedJoin = "CROSS JOIN LATERAL (SELECT %[2]d * (SELECT COUNT(*) FROM product_category mine JOIN product_category theirs ON theirs.category_id = mine.category_id WHERE mine.sku = %[1]s AND theirs.sku = products.sku) + CASE WHEN products.brand <> '' AND products.brand = (SELECT product.brand FROM products product WHERE product.sku = %[1]s) THEN %[3]d ELSE 0 END + %[4]d * COALESCE(coview.signal, 0) AS score) related"
+
+func (s *postgresStore) Related(ctx context.Context, id string, coViews map[string]float64, locales []string, limit int) ([]Product, error) {
+	var where conditions
+	query, _ := selectProducts(&where, locales)
+	ids := make([]string, 0, len(coViews))
+	signals := make([]float64, 0, len(coViews))
+	for other, signal := range coViews {
+		ids = append(ids, other)
+		signals = append(signals, signal)
+	}
+	query += fmt.Sprintf(" LEFT JOIN unnest(%s::text[], %s::float8[]) coview(sku, signal) ON coview.sku = products.sku", where.arg(pq.Array(ids)), where.arg(pq.Array(signals)))
+	product := where.arg(id)
+	query += " " + fmt.Sprintf(relatedJoin, product, categoryScore, brandScore, coViewScore)
+	where.add("products.sku <> " + product)
+	where.add("related.score > 0")
+	query += where.where() + fmt.Sprintf(" ORDER BY related.score DESC, products.sku LIMIT %s", where.arg(limit))
+
+	var products []Product
+	if err := s.db.SelectContext(ctx, &products, query, where.args...); err != nil {
+		return []Product{}, s.dbError(ctx, err)
+	}
+	if len(products) == 0 {
+		var exists bool
+		if err := s.db.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM products WHERE sku = $1)", id); err != nil {
+			return []Product{}, s.dbError(ctx, err)
+		}
+		if !exists {
+			return []Product{}, ErrNotFound
+		}
+		return []Product{}, nil
+	}
+	for i, s := range products {
+		products[i].ImageURL = []string{s.ImageURL1, s.ImageURL2}
+		products[i].Categories = splitCategories(s.CategoryString)
+		products[i].Variants = parseVariants(s.VariantString)
+	}
+	return products, nil
+}
+
+func (s *postgresStore) Create(ctx context.Context, product Product) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return s.dbError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO products (sku, brand, title, description, weight, product_size, colors, qty, price, currency, image_url_1, image_url_2, version) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)",
+		product.ID, product.Brand, product.Title, product.Description, product.Weight, product.ProductSize, product.Colors, product.Qty, product.Price, product.Currency, product.ImageURL1, product.ImageURL2, product.Version)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code {
+			case "23505": // unique_violation
+				return ErrProductExists
+			case "22001", "22003": // string_data_right_truncation, numeric_value_out_of_range
+				return ErrInvalidProduct
+			}
+		}
+		return s.dbError(ctx, err)
+	}
+	if err = s.setProductCategories(ctx, tx, product.ID, product.Categories); err != nil {
+		return err
+	}
+	if err = s.setProductVariants(ctx, tx, product.ID, product.Variants); err != nil {
+		return err
+	}
+	if err = s.recordChange(ctx, tx, product.ID); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return s.dbError(ctx, err)
+	}
+
+	return nil
+}
+
+func (s *postgresStore) Update(ctx context.Context, product Product) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return s.dbError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "UPDATE products SET brand = $3, title = $4, description = $5, weight = $6, product_size = $7, colors = $8, qty = $9, price = $10, currency = $11, image_url_1 = $12, image_url_2 = $13, version = version + 1 WHERE sku = $1 AND version = $2",
+		product.ID, product.Version, product.Brand, product.Title, product.Description, product.Weight, product.ProductSize, product.Colors, product.Qty, product.Price, product.Currency, product.ImageURL1, product.ImageURL2)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && (pqErr.Code == "22001" || pqErr.Code == "22003") { // string_data_right_truncation, numeric_value_out_of_range
+			return ErrInvalidProduct
+		}
+		return s.dbError(ctx, err)
+	}
+	if err = s.checkVersionedWrite(ctx, tx, res, product.ID); err != nil {
+		return err
+	}
+	if err = s.setProductCategories(ctx, tx, product.ID, product.Categories); err != nil {
+		return err
+	}
+	if err = s.setProductVariants(ctx, tx, product.ID, product.Variants); err != nil {
+		return err
+	}
+	if err = s.recordChange(ctx, tx, product.ID); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return s.dbError(ctx, err)
+	}
+
+	return nil
+}
+
+func (s *postgresStore) Delete(ctx context.Context, id string, version int) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return s.dbError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	// product_category rows go first, they reference the product. Should the
+	// version check below fail, the rollback restores them.
+	if _, err = tx.ExecContext(ctx, "DELETE FROM product_category WHERE sku = $1", id); err != nil {
+		return s.dbError(ctx, err)
+	}
+	res, err := tx.ExecContext(ctx, "DELETE FROM products WHERE sku = $1 AND version = $2", id, version)
+	if err != nil {
+		return s.dbError(ctx, err)
+	}
+	if err = s.checkVersionedWrite(ctx, tx, res, id); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO catalogue_outbox (type, sku, detail) VALUES ($1, $2, json_build_object('id', $2::text, 'version', $3::int))",
+		ProductDeleted, id, version)
+	if err != nil {
+		return s.dbError(ctx, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return s.dbError(ctx, err)
+	}
+
+	return nil
+}
+
+func (s *postgresStore) Import(ctx context.Context, products []Product) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return s.dbError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	for _, product := range products {
+		_, err = tx.ExecContext(ctx, "INSERT INTO products (sku, brand, title, description, weight, product_size, colors, qty, price, currency, image_url_1, image_url_2, version) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, 1) ON CONFLICT (sku) DO UPDATE SET brand = EXCLUDED.brand, title = EXCLUDED.title, description = EXCLUDED.description, weight = EXCLUDED.weight, product_size = EXCLUDED.product_size, colors = EXCLUDED.colors, qty = EXCLUDED.qty, price = EXCLUDED.price, currency = EXCLUDED.currency, image_url_1 = EXCLUDED.image_url_1, image_url_2 = EXCLUDED.image_url_2, version = products.version + 1",
+			product.ID, product.Brand, product.Title, product.Description, product.Weight, product.ProductSize, product.Colors, product.Qty, product.Price, product.Currency, product.ImageURL1, product.ImageURL2)
+		if err != nil {
+			// Values too long or too large for their column are the one
+			// thing the service cannot validate: report them with the
+			// product.
+			if pqErr, ok := err.(*pq.Error); ok && (pqErr.Code == "22001" || pqErr.Code == "22003") { // string_data_right_truncation, numeric_value_out_of_range
+				return ImportErrors{{ID: product.ID, Error: pqErr.Message}}
+			}
+			return s.dbError(ctx, err)
+		}
+		if err = s.setProductCategories(ctx, tx, product.ID, product.Categories); err != nil {
+			return err
+		}
+		if err = s.setProductVariants(ctx, tx, product.ID, product.Variants); err != nil {
+			return err
+		}
+		if err = s.recordChange(ctx, tx, product.ID); err != nil {
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return s.dbError(ctx, err)
+	}
+
+	return nil
+}
+
+func (s *postgresStore) Translations(ctx context.Context, id string) ([]Translation, error) {
+	var exists bool
+	if err := s.db.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM products WHERE sku = $1)", id); err != nil {
+		return []Translation{}, s.dbError(ctx, err)
+	}
+	if !exists {
+		return []Translation{}, ErrNotFound
+	}
+
+	translations := []Translation{}
+	err := s.db.SelectContext(ctx, &translations, "SELECT sku, locale, title, description FROM product_translation WHERE sku = $1 ORDER BY locale", id)
+	if err != nil {
+		return []Translation{}, s.dbError(ctx, err)
+	}
+	return translations, nil
+}
+
+func (s *postgresStore) SetTranslations(ctx context.Context, translations []Translation) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return s.dbError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	for _, t := range translations {
+		_, err = tx.ExecContext(ctx, "INSERT INTO product_translation (sku, locale, title, description) VALUES ($1, $2, $3, $4) ON CONFLICT (sku, locale) DO UPDATE SET title = EXCLUDED.title, description = EXCLUDED.description",
+			t.ID, t.Locale, t.Title, t.Description)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok {
+				switch pqErr.Code {
+				case "23503": // foreign_key_violation
+					return ErrNotFound
+				case "22001": // string_data_right_truncation
+					return ErrInvalidTranslation
+				}
+			}
+			return s.dbError(ctx, err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return s.dbError(ctx, err)
+	}
+
+	return nil
+}
+
+func (s *postgresStore) DeleteTranslation(ctx context.Context, id, locale string) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM product_translation WHERE sku = $1 AND locale = $2", id, locale)
+	if err != nil {
+		return s.dbError(ctx, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return s.dbError(ctx, err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// promotionFields are the columns of a promotion.
+var promotionFields = "id, name, sku, category, brand, percent_off, amount_off, currency, starts, ends, created"
+
+func (s *postgresStore) Promotions(ctx context.Context) ([]Promotion, error) {
+	promotions := []Promotion{}
+	if err := s.db.SelectContext(ctx, &promotions, "SELECT "+promotionFields+" FROM promotions ORDER BY created DESC, id"); err != nil {
+		return []Promotion{}, s.dbError(ctx, err)
+	}
+	return promotions, nil
+}
+
+func (s *postgresStore) CreatePromotion(ctx context.Context, promotion Promotion) (Promotion, error) {
+	err := s.db.GetContext(ctx, &promotion.Created, "INSERT INTO promotions (id, name, sku, category, brand, percent_off, amount_off, currency, starts, ends) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING created",
+		promotion.ID, promotion.Name, promotion.SKU, promotion.Category, promotion.Brand, promotion.PercentOff, promotion.AmountOff, promotion.Currency, promotion.Starts, promotion.Ends)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code {
+			case "23505": // unique_violation
+				return Promotion{}, ErrPromotionExists
+			case "22001", "22003", "23514": // string_data_right_truncation, numeric_value_out_of_range, check_violation
+				return Promotion{}, ErrInvalidPromotion
+			}
+		}
+		return Promotion{}, s.dbError(ctx, err)
+	}
+	return promotion, nil
+}
+
+func (s *postgresStore) ExpirePromotion(ctx context.Context, id string, at time.Time) (Promotion, error) {
+	var promotion Promotion
+	err := s.db.GetContext(ctx, &promotion, "UPDATE promotions SET ends = LEAST(ends, $2), starts = LEAST(starts, $2) WHERE id = $1 RETURNING "+promotionFields, id, at)
+	if err == sql.ErrNoRows {
+		return Promotion{}, ErrNotFound
+	}
+	if err != nil {
+		return Promotion{}, s.dbError(ctx, err)
+	}
+	return promotion, nil
+}
+
+func (s *postgresStore) PriceHistory(ctx context.Context, id string) ([]PriceChange, error) {
+	changes := []PriceChange{}
+	err := s.db.SelectContext(ctx, &changes, "SELECT COALESCE(price, 0) AS price, currency, changed FROM price_history WHERE sku = $1 ORDER BY changed DESC, id DESC", id)
+	if err != nil {
+		return []PriceChange{}, s.dbError(ctx, err)
+	}
+	if len(changes) > 0 {
+		return changes, nil
+	}
+
+	var exists bool
+	if err := s.db.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM products WHERE sku = $1)", id); err != nil {
+		return []PriceChange{}, s.dbError(ctx, err)
+	}
+	if !exists {
+		return []PriceChange{}, ErrNotFound
+	}
+	return changes, nil
+}
+
+func (s *postgresStore) PublishChanges(ctx context.Context, limit int, publish func([]Change) error) (int, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, s.dbError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	// Other publishers skip the changes locked here, and publish the next.
+	changes := []Change{}
+	err = tx.SelectContext(ctx, &changes, "SELECT id, type, sku, detail, created FROM catalogue_outbox WHERE published IS NULL ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED", limit)
+	if err != nil {
+		return 0, s.dbError(ctx, err)
+	}
+	if len(changes) == 0 {
+		return 0, nil
+	}
+	if err = publish(changes); err != nil {
+		return 0, err
+	}
+	offsets := make([]int64, len(changes))
+	for i, c := range changes {
+		offsets[i] = c.Offset
+	}
+	if _, err = tx.ExecContext(ctx, "UPDATE catalogue_outbox SET published = now() WHERE id = ANY($1)", pq.Array(offsets)); err != nil {
+		return 0, s.dbError(ctx, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, s.dbError(ctx, err)
+	}
+	return len(changes), nil
+}
+
+func (s *postgresStore) ReplayChanges(ctx context.Context, from int64) (int, error) {
+	res, err := s.db.ExecContext(ctx, "UPDATE catalogue_outbox SET published = NULL WHERE id >= $1 AND published IS NOT NULL", from)
+	if err != nil {
+		return 0, s.dbError(ctx, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, s.dbError(ctx, err)
+	}
+	return int(n), nil
+}
+
+// reservationFields are the columns of a reservation.
+var reservationFields = "id, sku, cart, qty, expires, created"
+
+// reviewFields are the columns of a review.
+var reviewFields = "id, sku, author, rating, text, status, created, moderated"
+
+func (s *postgresStore) Reviews(ctx context.Context, id, status string, offset, limit int) ([]Review, error) {
+	var where conditions
+	if id != "" {
+		where.add("sku = ?", id)
+	}
+	where.add("status = ?", status)
+	query := fmt.Sprintf("SELECT %s FROM reviews%s ORDER BY created DESC, id DESC LIMIT %s OFFSET %s", reviewFields, where.where(), where.arg(limit), where.arg(offset))
+	reviews := []Review{}
+	if err := s.db.SelectContext(ctx, &reviews, query, where.args...); err != nil {
+		return []Review{}, s.dbError(ctx, err)
+	}
+	if len(reviews) > 0 || id == "" {
+		return reviews, nil
+	}
+
+	var exists bool
+	if err := s.db.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM products WHERE sku = $1)", id); err != nil {
+		return []Review{}, s.dbError(ctx, err)
+	}
+	if !exists {
+		return []Review{}, ErrNotFound
+	}
+	return reviews, nil
+}
+
+func (s *postgresStore) CreateReview(ctx context.Context, review Review) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO reviews (id, sku, author, rating, text, status, created) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		review.ID, review.SKU, review.Author, review.Rating, review.Text, review.Status, review.Created)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code {
+			case "23503": // foreign_key_violation
+				return ErrNotFound
+			case "22001", "23514": // string_data_right_truncation, check_violation
+				return ErrInvalidReview
+			}
+		}
+		return s.dbError(ctx, err)
+	}
+	return nil
+}
+
+func (s *postgresStore) ModerateReview(ctx context.Context, id, status string, at time.Time) (Review, error) {
+	var review Review
+	err := s.db.GetContext(ctx, &review, "UPDATE reviews SET status = $2, moderated = $3 WHERE id = $1 RETURNING "+reviewFields, id, status, at)
+	if err == sql.ErrNoRows {
+		return Review{}, ErrNotFound
+	}
+	if err != nil {
+		return Review{}, s.dbError(ctx, err)
+	}
+	return review, nil
+}
+
+func (s *postgresStore) Reserve(ctx context.Context, reservation Reservation) (Reservation, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Reservation{}, s.dbError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	qty, err := s.lockStock(ctx, tx, reservation.SKU)
+	if err != nil {
+		return Reservation{}, err
+	}
+	var reserved int
+	err = tx.GetContext(ctx, &reserved, "SELECT COALESCE(SUM(qty), 0) FROM reservations WHERE sku = $1 AND expires > $2", reservation.SKU, reservation.Created)
+	if err != nil {
+		return Reservation{}, s.dbError(ctx, err)
+	}
+	if available(qty, reserved) < reservation.Qty {
+		return Reservation{}, ErrInsufficientStock
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO reservations (id, sku, cart, qty, expires, created) VALUES ($1, $2, $3, $4, $5, $6)",
+		reservation.ID, reservation.SKU, reservation.Cart, reservation.Qty, reservation.Expires, reservation.Created)
+	if err != nil {
+		return Reservation{}, s.dbError(ctx, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return Reservation{}, s.dbError(ctx, err)
+	}
+	return reservation, nil
+}
+
+func (s *postgresStore) CommitReservation(ctx context.Context, id string, at time.Time) (Reservation, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Reservation{}, s.dbError(ctx, err)
+	}
+	defer tx.Rollback()
+
+	// Deleting the reservation first makes a concurrent commit of it wait,
+	// then find it gone.
+	var reservation Reservation
+	err = tx.GetContext(ctx, &reservation, "DELETE FROM reservations WHERE id = $1 RETURNING "+reservationFields, id)
+	if err == sql.ErrNoRows {
+		return Reservation{}, ErrNotFound
+	}
+	if err != nil {
+		return Reservation{}, s.dbError(ctx, err)
+	}
+	if !at.Before(reservation.Expires) {
+		return Reservation{}, ErrReservationExpired // left to ReleaseExpired
+	}
+	qty, err := s.lockStock(ctx, tx, reservation.SKU)
+	if err != nil {
+		return Reservation{}, err
+	}
+	if qty < reservation.Qty {
+		return Reservation{}, ErrInsufficientStock
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE products SET qty = qty - $2, version = version + 1 WHERE sku = $1", reservation.SKU, reservation.Qty)
+	if err != nil {
+		return Reservation{}, s.dbError(ctx, err)
+	}
+	if err = s.recordChange(ctx, tx, reservation.SKU); err != nil {
+		return Reservation{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return Reservation{}, s.dbError(ctx, err)
+	}
+	return reservation, nil
+}
+
+// lockStock locks a product for the reservations of its stock, and the
+// commits taking from it, to wait for one another, and returns its qty. The
+// stock of a product with variants is theirs, so it cannot be reserved.
+func (s *postgresStore) lockStock(ctx context.Context, tx *sqlx.Tx, sku string) (int, error) {
+	var stock struct {
+		Qty      int  `db:"qty"`
+		Variants bool `db:"variants"`
+	}
+	err := tx.GetContext(ctx, &stock, "SELECT COALESCE(qty, 0) AS qty, EXISTS (SELECT 1 FROM product_variant WHERE product_sku = products.sku) AS variants FROM products WHERE sku = $1 FOR UPDATE", sku)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, s.dbError(ctx, err)
+	}
+	if stock.Variants {
+		return 0, ErrInvalidReservation
+	}
+	return stock.Qty, nil
+}
+
+func (s *postgresStore) ReleaseReservation(ctx context.Context, id string) (Reservation, error) {
+	var reservation Reservation
+	err := s.db.GetContext(ctx, &reservation, "DELETE FROM reservations WHERE id = $1 RETURNING "+reservationFields, id)
+	if err == sql.ErrNoRows {
+		return Reservation{}, ErrNotFound
+	}
+	if err != nil {
+		return Reservation{}, s.dbError(ctx, err)
+	}
+	return reservation, nil
+}
+
+func (s *postgresStore) ReleaseExpired(ctx context.Context, at time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM reservations WHERE expires <= $1", at)
+	if err != nil {
+		return 0, s.dbError(ctx, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, s.dbError(ctx, err)
+	}
+	return int(n), nil
+}
+
+// dbError logs a database error, and returns the error the store fails with:
+// that of the context when it is done, as when a query ran past its deadline
+// or the client went away, and ErrDBConnection otherwise.
+func (s *postgresStore) dbError(ctx context.Context, err error) error {
+	s.logger.Log("database error", err)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return ErrDBConnection
+}
+
+// splitCategories splits the categories_name column, which is empty for
+// products without categories.
+func splitCategories(list string) []string {
+	categories := []string{}
+	for _, c := range strings.Split(list, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			categories = append(categories, c)
+		}
+	}
+	return categories
+}
+
+// checkVersionedWrite tells apart the reasons a write guarded by
+// "WHERE sku = ? AND version = ?" can touch no rows: either the product does
+// not exist, or it was changed since the caller read it.
+func (s *postgresStore) checkVersionedWrite(ctx context.Context, tx *sqlx.Tx, res sql.Result, id string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return s.dbError(ctx, err)
+	}
+	if n > 0 {
+		return nil
+	}
+	var exists bool
+	if err = tx.GetContext(ctx, &exists, "SELECT EXISTS (SELECT 1 FROM products WHERE sku = $1)", id); err != nil {
+		return s.dbError(ctx, err)
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return ErrVersionConflict
+}
+
+// recordChange records in the outbox that a product was written, created
+// when at version 1 and updated otherwise.
+func (s *postgresStore) recordChange(ctx context.Context, tx *sqlx.Tx, id string) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO catalogue_outbox (type, sku, detail) SELECT CASE WHEN version = 1 THEN $1 ELSE $2 END, sku, json_build_object('id', sku, 'version', version, 'price', price, 'currency', currency) FROM products WHERE sku = $3",
+		ProductCreated, ProductUpdated, id)
+	if err != nil {
+		return s.dbError(ctx, err)
+	}
+	return nil
+}
+
+// setProductCategories replaces the product_category rows of a product with
+// the given category names, all of which must exist.
+func (s *postgresStore) setProductCategories(ctx context.Context, tx *sqlx.Tx, id string, categories []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_category WHERE sku = $1", id); err != nil {
+		return s.dbError(ctx, err)
+	}
+	if len(categories) == 0 {
+		return nil
+	}
+
+	var ids []int
+	err := tx.SelectContext(ctx, &ids, "SELECT category_id FROM categories WHERE name = ANY($1)", pq.Array(categories))
+	if err != nil {
+		return s.dbError(ctx, err)
+	}
+	if len(ids) != len(categories) {
+		return ErrUnknownCategory
+	}
+	for _, categoryID := range ids {
+		if _, err = tx.ExecContext(ctx, "INSERT INTO product_category (sku, category_id) VALUES ($1, $2)", id, categoryID); err != nil {
+			return s.dbError(ctx, err)
+		}
+	}
+	return nil
+}
+
+// setProductVariants replaces the variants of a product.
+func (s *postgresStore) setProductVariants(ctx context.Context, tx *sqlx.Tx, id string, variants []Variant) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_variant WHERE product_sku = $1", id); err != nil {
+		return s.dbError(ctx, err)
+	}
+	for i, v := range variants {
+		images := make([]string, 2)
+		copy(images, v.ImageURL)
+		_, err := tx.ExecContext(ctx, "INSERT INTO product_variant (sku, product_sku, position, color, size, price_delta, qty, image_url_1, image_url_2) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+			v.ID, id, i, v.Color, v.Size, v.PriceDelta, v.Qty, images[0], images[1])
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok {
+				switch pqErr.Code {
+				case "23505": // unique_violation
+					return ErrVariantExists
+				case "22001", "22003": // string_data_right_truncation, numeric_value_out_of_range
+					return ErrInvalidProduct
+				}
+			}
+			return s.dbError(ctx, err)
+		}
+	}
+	return nil
+}
+
+func (s *postgresStore) Health(ctx context.Context) Health {
+	dbstatus := "OK"
+
+	err := s.db.PingContext(ctx)
+	if err != nil {
+		dbstatus = "err"
+	}
+
+	return Health{"postgres:catalogue-data", dbstatus, time.Now().String()}
+}
+
+func (s *postgresStore) Categories(ctx context.Context) ([]string, error) {
+	var categories []string
+	query := "SELECT name FROM categories"
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return []string{}, s.dbError(ctx, err)
+	}
+	var category string
+	for rows.Next() {
+		err = rows.Scan(&category)
+		if err != nil {
+			s.logger.Log("database error", err)
+			continue
+		}
+		categories = append(categories, category)
+	}
+	return categories, nil
+}
+
+func (s *postgresStore) CategoryTree(ctx context.Context) ([]Category, error) {
+	categories := []Category{}
+	err := s.db.SelectContext(ctx, &categories, "SELECT categories.name, categories.slug, COALESCE(parent.name, '') AS parent, categories.position, categories.description FROM categories LEFT JOIN categories parent ON parent.category_id = categories.parent_id")
+	if err != nil {
+		return []Category{}, s.dbError(ctx, err)
+	}
+	return categoryTree(categories), nil
+}
+
+// SeedPostgres adds the categories and products of a fixture to the database,
+// leaving the products already there alone, and returns how many products it
+// added. Categories already there are arranged as in the fixture.
+func SeedPostgres(ctx context.Context, db *sqlx.DB, fixture Fixture, logger log.Logger) (int, error) {
+	categories, err := normalizeCategories(fixture.Categories)
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range categories { // parents first
+		_, err := db.ExecContext(ctx, "INSERT INTO categories (name, slug, parent_id, position, description) VALUES ($1, $2, (SELECT category_id FROM categories WHERE name = $3), $4, $5) ON CONFLICT (name) DO UPDATE SET slug = EXCLUDED.slug, parent_id = EXCLUDED.parent_id, position = EXCLUDED.position, description = EXCLUDED.description",
+			c.Name, c.Slug, c.Parent, c.Position, c.Description)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	store := NewPostgresStore(db, logger)
+	created := 0
+	for i, p := range fixture.Products {
+		p, err := normalizeProduct(p)
+		if err == nil {
+			if p.Version <= 0 {
+				p.Version = 1
+			}
+			err = store.Create(ctx, p)
+		}
+		if err == ErrProductExists {
+			continue
+		}
+		if err != nil {
+			return created, fmt.Errorf("product %d (%q): %w", i, fixture.Products[i].ID, err)
+		}
+		created++
+	}
+	return created, nil
+}
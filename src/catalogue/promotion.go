@@ -0,0 +1,130 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// promotion.go contains the promotions putting products on sale for a time,
+// and the history of the prices of products.
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+// ErrInvalidPromotion is returned when a promotion does not target exactly
+// one of a SKU, a category or a brand, does not take exactly one of a
+// percentage or an amount off, has a currency without an amount, or ends
+// before it starts.
+var ErrInvalidPromotion = errors.New("invalid promotion")
+
+// ErrPromotionExists is returned when creating a promotion whose ID is taken.
+var ErrPromotionExists = errors.New("promotion already exists")
+
+// Promotion puts the products of a SKU, a category or a brand on sale from
+// its start until its end, by a percentage or an amount off their price. The
+// amount is in the currency of the promotion, and only products priced in it
+// are on sale by the amount.
+type Promotion struct {
+	ID         string    `json:"id" db:"id"`
+	Name       string    `json:"name" db:"name"`
+	SKU        string    `json:"sku,omitempty" db:"sku"`
+	Category   string    `json:"category,omitempty" db:"category"`
+	Brand      string    `json:"brand,omitempty" db:"brand"`
+	PercentOff float64   `json:"percentOff,omitempty" db:"percent_off"`
+	AmountOff  float64   `json:"amountOff,omitempty" db:"amount_off"`
+	Currency   string    `json:"currency,omitempty" db:"currency"`
+	Starts     time.Time `json:"starts" db:"starts"`
+	Ends       time.Time `json:"ends" db:"ends"`
+	Created    time.Time `json:"created" db:"created"`
+}
+
+// PriceChange is a price a product had from a time on.
+type PriceChange struct {
+	Price    float32   `json:"price" db:"price"`
+	Currency string    `json:"currency" db:"currency"`
+	Changed  time.Time `json:"changed" db:"changed"`
+}
+
+// normalizePromotion validates a promotion submitted for creation. It starts
+// now unless told otherwise, and an amount off is in the default currency
+// unless told otherwise.
+func normalizePromotion(p Promotion, now time.Time) (Promotion, error) {
+	p.ID = strings.TrimSpace(p.ID)
+	p.Name = strings.TrimSpace(p.Name)
+	p.SKU = strings.TrimSpace(p.SKU)
+	p.Category = strings.TrimSpace(p.Category)
+	p.Brand = strings.TrimSpace(p.Brand)
+	if p.Starts.IsZero() {
+		p.Starts = now
+	}
+	p.Starts, p.Ends = p.Starts.UTC().Truncate(time.Microsecond), p.Ends.UTC().Truncate(time.Microsecond)
+	p.Created = time.Time{}
+	p.Currency = strings.ToUpper(strings.TrimSpace(p.Currency))
+	validCurrency := true
+	if p.AmountOff != 0 {
+		p.Currency, validCurrency = normalizeCurrency(p.Currency)
+	}
+
+	targets := 0
+	for _, t := range []string{p.SKU, p.Category, p.Brand} {
+		if t != "" {
+			targets++
+		}
+	}
+	switch {
+	case p.ID == "" || len(p.ID) > 40 || len(p.Name) > 100:
+	case targets != 1:
+	case (p.PercentOff != 0) == (p.AmountOff != 0):
+	case p.PercentOff < 0 || p.PercentOff > 100 || p.AmountOff < 0:
+	case !validCurrency || p.AmountOff == 0 && p.Currency != "":
+	case math.IsNaN(p.PercentOff) || math.IsNaN(p.AmountOff) || math.IsInf(p.AmountOff, 0):
+	case p.Ends.IsZero() || !p.Starts.Before(p.Ends):
+	default:
+		return p, nil
+	}
+	return Promotion{}, ErrInvalidPromotion
+}
+
+// active tells whether a promotion is on at a time.
+func (p Promotion) active(at time.Time) bool {
+	return !at.Before(p.Starts) && at.Before(p.Ends)
+}
+
+// targets tells whether a promotion is of a product, which an amount off
+// must be in the currency of.
+func (p Promotion) targets(product Product) bool {
+	switch {
+	case p.AmountOff != 0 && p.Currency != product.Currency:
+		return false
+	case p.SKU != "":
+		return p.SKU == product.ID
+	case p.Category != "":
+		return contains(product.Categories, p.Category)
+	}
+	return p.Brand == product.Brand
+}
+
+// salePrice is the price on sale by a promotion, never below zero.
+func (p Promotion) salePrice(price float32) float32 {
+	return float32(math.Max(float64(price)*(100-p.PercentOff)/100-p.AmountOff, 0))
+}
+
+// promote puts a product on sale by the promotion active at a time that
+// lowers its price the most, if there is any.
+func promote(product Product, promotions []Promotion, at time.Time) Product {
+	product.SalePrice, product.PromotionID = 0, ""
+	for _, p := range promotions {
+		if !p.active(at) || !p.targets(product) {
+			continue
+		}
+		price := p.salePrice(product.Price)
+		if product.PromotionID == "" || price < product.SalePrice || price == product.SalePrice && p.ID < product.PromotionID {
+			product.SalePrice, product.PromotionID = price, p.ID
+		}
+	}
+	return product
+}
@@ -0,0 +1,237 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+package catalogue
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+func TestNormalizePromotion(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	p, err := normalizePromotion(Promotion{ID: " summer ", SKU: "A", PercentOff: 10, Ends: now.Add(time.Hour)}, now)
+	if err != nil || p.ID != "summer" || !p.Starts.Equal(now) || p.Currency != "" {
+		t.Errorf("normalizePromotion: have %+v, %v", p, err)
+	}
+	p, err = normalizePromotion(Promotion{ID: "off", SKU: "A", AmountOff: 1, Ends: now.Add(time.Hour)}, now)
+	if err != nil || p.Currency != DefaultCurrency {
+		t.Errorf("normalizePromotion of an amount: have %+v, %v", p, err)
+	}
+
+	for name, p := range map[string]Promotion{
+		"no id":            {SKU: "A", PercentOff: 10},
+		"no target":        {ID: "x", PercentOff: 10},
+		"two targets":      {ID: "x", SKU: "A", Brand: "Acme", PercentOff: 10},
+		"no discount":      {ID: "x", SKU: "A"},
+		"two discounts":    {ID: "x", SKU: "A", PercentOff: 10, AmountOff: 1},
+		"over 100%":        {ID: "x", SKU: "A", PercentOff: 120},
+		"negative":         {ID: "x", SKU: "A", AmountOff: -1},
+		"bad currency":     {ID: "x", SKU: "A", AmountOff: 1, Currency: "euro"},
+		"percent currency": {ID: "x", SKU: "A", PercentOff: 10, Currency: "EUR"},
+		"ends at start":    {ID: "x", SKU: "A", PercentOff: 10, Starts: now.Add(time.Hour)},
+		"ends before now":  {ID: "x", SKU: "A", PercentOff: 10, Ends: now.Add(-time.Hour)},
+	} {
+		if p.Ends.IsZero() {
+			p.Ends = now.Add(time.Hour)
+		}
+		if _, err := normalizePromotion(p, now); err != ErrInvalidPromotion {
+			t.Errorf("%s: want %v, have %v", name, ErrInvalidPromotion, err)
+		}
+	}
+}
+
+func TestPromotions(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewMemoryStore(testFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewCatalogueService(store)
+	now := time.Now()
+
+	for _, p := range []Promotion{
+		{ID: "acme", Brand: "Acme", PercentOff: 10, Ends: now.Add(time.Hour)},
+		{ID: "mouse", SKU: "B", AmountOff: 1, Ends: now.Add(time.Hour)},
+		{ID: "wet", SKU: "D", AmountOff: 1, Currency: "EUR", Ends: now.Add(time.Hour)},
+		{ID: "bowls", Category: "Bowls", PercentOff: 20, Starts: now.Add(time.Hour), Ends: now.Add(2 * time.Hour)},
+	} {
+		if _, err := s.CreatePromotion(ctx, p); err != nil {
+			t.Fatalf("CreatePromotion(%s): %v", p.ID, err)
+		}
+	}
+	if _, err := s.CreatePromotion(ctx, Promotion{ID: "acme", SKU: "A", PercentOff: 5, Ends: now.Add(time.Hour)}); err != ErrPromotionExists {
+		t.Errorf("CreatePromotion with taken ID: want %v, have %v", ErrPromotionExists, err)
+	}
+	if _, err := s.CreatePromotion(ctx, Promotion{ID: "toys", Category: "Nope", PercentOff: 5, Ends: now.Add(time.Hour)}); err != ErrUnknownCategory {
+		t.Errorf("CreatePromotion of unknown category: want %v, have %v", ErrUnknownCategory, err)
+	}
+
+	onSale := func(ids ...string) []string {
+		var sales []string
+		for _, id := range ids {
+			p, err := s.Get(ctx, id, "", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sales = append(sales, p.PromotionID+" "+p.FormattedSalePrice)
+		}
+		return sales
+	}
+	// Euros off do not apply to products priced in dollars.
+	if have, want := onSale("A", "B", "C", "D"), []string{"acme $8.99", "mouse $3.50", " ", " "}; !reflect.DeepEqual(have, want) {
+		t.Errorf("on sale now: want %q, have %q", want, have)
+	}
+	store.(*memoryStore).now = func() time.Time { return now.Add(90 * time.Minute) }
+	if have, want := onSale("A", "B", "C"), []string{"bowls $7.99", " ", "bowls $20.00"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("on sale later: want %q, have %q", want, have)
+	}
+
+	if p, err := s.ExpirePromotion(ctx, "bowls"); err != nil || p.Ends.After(now.Add(time.Minute)) {
+		t.Errorf("ExpirePromotion: have %+v, %v", p, err)
+	}
+	if _, err := s.ExpirePromotion(ctx, "nope"); err != ErrNotFound {
+		t.Errorf("ExpirePromotion(nope): want %v, have %v", ErrNotFound, err)
+	}
+	if have, want := onSale("A", "C"), []string{" ", " "}; !reflect.DeepEqual(have, want) {
+		t.Errorf("on sale after expiry: want %q, have %q", want, have)
+	}
+	promotions, _ := s.Promotions(ctx)
+	var ids []string
+	for _, p := range promotions {
+		ids = append(ids, p.ID)
+	}
+	if want := []string{"bowls", "wet", "mouse", "acme"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("Promotions: want %v, have %v", want, ids)
+	}
+}
+
+func TestPriceHistory(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryService(t)
+	p, err := s.Get(ctx, "A", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Title = "Steel bowl, large"
+	if p, err = s.Update(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	p.Price = 12
+	if _, err = s.Update(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := s.PriceHistory(ctx, "A")
+	var prices []float32
+	for _, c := range changes {
+		prices = append(prices, c.Price)
+	}
+	if want := []float32{12, 9.99}; err != nil || !reflect.DeepEqual(prices, want) {
+		t.Errorf("PriceHistory(A): want %v, have %v, %v", want, prices, err)
+	}
+	if _, err := s.PriceHistory(ctx, "E"); err != ErrNotFound {
+		t.Errorf("PriceHistory(E): want %v, have %v", ErrNotFound, err)
+	}
+}
+
+func TestPromotionsHTTP(t *testing.T) {
+	h := newTestHandler(newTestMemoryService(t))
+	ends := time.Now().Add(time.Hour).Format(time.RFC3339)
+
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{`{"id": "mouse", "sku": "B", "amountOff": 1, "ends": "` + ends + `"}`, http.StatusCreated},
+		{`{"id": "mouse", "sku": "A", "percentOff": 5, "ends": "` + ends + `"}`, http.StatusConflict},
+		{`{"id": "both", "sku": "A", "brand": "Acme", "percentOff": 5}`, http.StatusBadRequest},
+		{`{"id": "toys", "category": "Nope", "percentOff": 5, "ends": "` + ends + `"}`, http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/promotions", strings.NewReader(tc.body)))
+		if rec.Code != tc.want {
+			t.Errorf("POST /promotions %s: want %d, have %d", tc.body, tc.want, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/catalogue/B", nil))
+	var p struct {
+		Price       float32 `json:"price"`
+		SalePrice   float32 `json:"salePrice"`
+		PromotionID string  `json:"promotionId"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Price != 4.5 || p.SalePrice != 3.5 || p.PromotionID != "mouse" {
+		t.Errorf("GET /catalogue/B: have %+v", p)
+	}
+
+	for path, want := range map[string]int{
+		"/promotions/mouse/expire": http.StatusOK,
+		"/promotions/nope/expire":  http.StatusNotFound,
+	} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", path, nil))
+		if rec.Code != want {
+			t.Errorf("POST %s: want %d, have %d", path, want, rec.Code)
+		}
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/admin/catalogue/B/prices", nil))
+	var changes []PriceChange
+	if err := json.NewDecoder(rec.Body).Decode(&changes); err != nil || len(changes) != 1 || changes[0].Price != 4.5 {
+		t.Errorf("GET /admin/catalogue/B/prices: have %d %v, %v", rec.Code, changes, err)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/catalogue/B/prices", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /catalogue/B/prices: want %d, have %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestPostgresStorePromotions(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	store := NewPostgresStore(sqlx.NewDb(db, "sqlmock"), logger)
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	promotion := Promotion{ID: "summer", Category: "Bowls", PercentOff: 10, Starts: now, Ends: now.Add(time.Hour)}
+
+	mock.ExpectQuery("INSERT INTO promotions .* RETURNING created").
+		WithArgs("summer", "", "", "Bowls", "", 10.0, 0.0, "", now, now.Add(time.Hour)).
+		WillReturnRows(sqlmock.NewRows([]string{"created"}).AddRow(now))
+	mock.ExpectQuery("INSERT INTO promotions").WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectQuery("UPDATE promotions SET ends = LEAST\\(ends, \\$2\\), starts = LEAST\\(starts, \\$2\\) WHERE id = \\$1 RETURNING").
+		WithArgs("nope", now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	if p, err := store.CreatePromotion(ctx, promotion); err != nil || !p.Created.Equal(now) {
+		t.Errorf("CreatePromotion: have %+v, %v", p, err)
+	}
+	if _, err := store.CreatePromotion(ctx, promotion); err != ErrPromotionExists {
+		t.Errorf("CreatePromotion again: want %v, have %v", ErrPromotionExists, err)
+	}
+	if _, err := store.ExpirePromotion(ctx, "nope", now); err != ErrNotFound {
+		t.Errorf("ExpirePromotion(nope): want %v, have %v", ErrNotFound, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// related.go contains the products recommended alongside a product: those
+// sharing its categories or brand, blended with those customers viewed with
+// it, as computed offline from events.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+)
+
+// ErrInvalidCoViews is returned when co-view signals are not positive
+// numbers.
+var ErrInvalidCoViews = errors.New("invalid co-views")
+
+// CoViews are co-view signals: for each product ID, how strongly each other
+// product was viewed with it, e.g. in how many sessions. Only the proportions
+// between the products viewed with the same product matter.
+type CoViews map[string]map[string]float64
+
+// ReadCoViews reads co-view signals from a JSON file.
+func ReadCoViews(path string) (CoViews, error) {
+	var coViews CoViews
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &coViews); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return coViews, nil
+}
+
+// The scores products are ranked by in relation to a product.
+const (
+	categoryScore = 2 // for each category they share
+	brandScore    = 1 // for the same brand
+	coViewScore   = 3 // for the product most viewed with it, others in proportion
+)
+
+// relatedScore returns the score of a product in relation to another, given
+// its co-view signal, which normalizeCoViews scales to at most 1: the sum of
+// the scores above, unbounded as products share any number of categories.
+func relatedScore(product, p Product, coView float64) float64 {
+	score := float64(categoryScore*len(intersect(product.Categories, p.Categories))) + coViewScore*coView
+	if product.Brand != "" && p.Brand == product.Brand {
+		score += brandScore
+	}
+	return score
+}
+
+// maxRelated is the most related products returned at once.
+const maxRelated = 20
+
+// normalizeCoViews validates co-view signals and scales those of each
+// product so that the strongest is 1. Signals of a product with itself are
+// dropped.
+func normalizeCoViews(coViews CoViews) (CoViews, error) {
+	normalized := make(CoViews, len(coViews))
+	for id, viewed := range coViews {
+		strongest := 0.0
+		for other, signal := range viewed {
+			if math.IsNaN(signal) || math.IsInf(signal, 0) || signal < 0 {
+				return nil, fmt.Errorf("%w: %s with %s is %v", ErrInvalidCoViews, id, other, signal)
+			}
+			if other != id {
+				strongest = math.Max(strongest, signal)
+			}
+		}
+		if strongest == 0 {
+			continue
+		}
+		scaled := make(map[string]float64, len(viewed))
+		for other, signal := range viewed {
+			if other != id && signal > 0 {
+				scaled[other] = signal / strongest
+			}
+		}
+		normalized[id] = scaled
+	}
+	return normalized, nil
+}
+
+// coViewSignals holds the current co-view signals of a service.
+type coViewSignals struct {
+	mtx     sync.RWMutex
+	coViews CoViews
+}
+
+// get returns the signals of the products viewed with a product, which the
+// caller must not change.
+func (c *coViewSignals) get(id string) map[string]float64 {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.coViews[id]
+}
+
+func (c *coViewSignals) set(coViews CoViews) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.coViews = coViews
+}
@@ -0,0 +1,107 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+package catalogue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestRelated(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryService(t)
+
+	products, err := s.Related(ctx, "A", "", nil, 10)
+	if have, want := productIDs(products), []string{"C", "B"}; err != nil || !reflect.DeepEqual(have, want) {
+		t.Errorf("Related(A): want %v, have %v, %v", want, have, err)
+	}
+	if _, err := s.Related(ctx, "E", "", nil, 10); err != ErrNotFound {
+		t.Errorf("Related(E): want %v, have %v", ErrNotFound, err)
+	}
+
+	if err := s.SetCoViews(ctx, CoViews{"A": {"B": 5, "D": -1}}); !errors.Is(err, ErrInvalidCoViews) {
+		t.Errorf("SetCoViews with negative signal: want %v, have %v", ErrInvalidCoViews, err)
+	}
+	if err := s.SetCoViews(ctx, CoViews{"A": {"A": 50, "D": 10, "B": 5}}); err != nil {
+		t.Fatal(err)
+	}
+	products, _ = s.Related(ctx, "A", "", nil, 10)
+	if have, want := productIDs(products), []string{"D", "B", "C"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("Related(A) with co-views: want %v, have %v", want, have)
+	}
+	products, _ = s.Related(ctx, "A", "", nil, 2)
+	if have, want := productIDs(products), []string{"D", "B"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("Related(A) size 2: want %v, have %v", want, have)
+	}
+}
+
+func TestRelatedHTTP(t *testing.T) {
+	h := newTestHandler(newTestMemoryService(t))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/catalogue/A/related?size=1", nil))
+	var products []Product
+	if err := json.NewDecoder(rec.Body).Decode(&products); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("GET related: have %d, %v", rec.Code, err)
+	}
+	if have, want := productIDs(products), []string{"C"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("GET related: want %v, have %v", want, have)
+	}
+
+	for body, want := range map[string]int{
+		`{"A": {"D": 1}}`:   http.StatusNoContent,
+		`{"A": {"D": -1}}`:  http.StatusBadRequest,
+		`{"A": ["B", "D"]}`: http.StatusBadRequest,
+	} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("PUT", "/coviews", strings.NewReader(body)))
+		if rec.Code != want {
+			t.Errorf("PUT /coviews %s: want %d, have %d", body, want, rec.Code)
+		}
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/catalogue/E/related", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET related of unknown product: want %d, have %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestPostgresStoreRelated(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	store := NewPostgresStore(sqlx.NewDb(db, "sqlmock"), logger)
+
+	mock.ExpectQuery("SELECT .* LEFT JOIN unnest\\(\\$1::text\\[\\], \\$2::float8\\[\\]\\) coview\\(sku, signal\\) ON coview.sku = products.sku CROSS JOIN LATERAL \\(SELECT 2 \\* .*mine.sku = \\$3 .* THEN 1 ELSE 0 END \\+ 3 \\* COALESCE\\(coview.signal, 0\\) AS score\\) related WHERE products.sku <> \\$3 AND related.score > 0 ORDER BY related.score DESC, products.sku LIMIT \\$4").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "1", 4).
+		WillReturnRows(sqlmock.NewRows([]string{"ID", "TITLE"}).AddRow("2", "title2").AddRow("3", "title3"))
+	mock.ExpectQuery("SELECT .* WHERE products.sku <> \\$3").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "0", 4).
+		WillReturnRows(sqlmock.NewRows([]string{"ID", "TITLE"}))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("0").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	products, err := store.Related(ctx, "1", map[string]float64{"3": 1}, nil, 4)
+	if have, want := productIDs(products), []string{"2", "3"}; err != nil || !reflect.DeepEqual(have, want) {
+		t.Errorf("Related: want %v, have %v, %v", want, have, err)
+	}
+	if _, err := store.Related(ctx, "0", nil, nil, 4); err != ErrNotFound {
+		t.Errorf("Related(0): want %v, have %v", ErrNotFound, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,138 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// reservation.go contains the reservations holding units of the stock of
+// products for carts, until an order commits them or they expire, and the
+// reaper releasing those that expired.
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// ErrInvalidReservation is returned when a reservation is not of a SKU and a
+// cart, of a positive quantity, or holds for longer than MaxReservationTTL,
+// and when reserving or committing stock of a product with variants, whose
+// qty is the sum of theirs.
+var ErrInvalidReservation = errors.New("invalid reservation")
+
+// ErrInsufficientStock is returned when reserving, or committing, more units
+// of a product than are available.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrReservationExpired is returned when committing a reservation that
+// expired, and no longer holds its units.
+var ErrReservationExpired = errors.New("reservation expired")
+
+// DefaultReservationTTL is the time a reservation holds its units for unless
+// it tells otherwise, and MaxReservationTTL the longest it can.
+const (
+	DefaultReservationTTL = 15 * time.Minute
+	MaxReservationTTL     = 24 * time.Hour
+)
+
+// Reservation holds Qty units of the product of a SKU for a cart until it
+// expires. Reserved units are not available to other carts; committing the
+// reservation at order time takes them out of the stock of the product.
+type Reservation struct {
+	ID      string    `json:"id" db:"id"`
+	SKU     string    `json:"sku" db:"sku"`
+	Cart    string    `json:"cart" db:"cart"`
+	Qty     int       `json:"qty" db:"qty"`
+	TTL     int       `json:"ttl,omitempty" db:"-"` // seconds it holds for, when reserving
+	Expires time.Time `json:"expires" db:"expires"`
+	Created time.Time `json:"created" db:"created"`
+}
+
+// normalizeReservation validates a reservation submitted at a time, and
+// sets the time it expires. Its ID is set by the service.
+func normalizeReservation(r Reservation, now time.Time) (Reservation, error) {
+	r.ID = ""
+	r.SKU = strings.TrimSpace(r.SKU)
+	r.Cart = strings.TrimSpace(r.Cart)
+	ttl := time.Duration(r.TTL) * time.Second
+	if r.TTL == 0 {
+		ttl = DefaultReservationTTL
+	}
+	switch {
+	case r.SKU == "" || len(r.SKU) > 20 || r.Cart == "" || len(r.Cart) > 40:
+	case r.Qty <= 0:
+	case ttl <= 0 || ttl > MaxReservationTTL:
+	default:
+		r.TTL = int(ttl / time.Second)
+		r.Created = now.UTC().Truncate(time.Microsecond)
+		r.Expires = r.Created.Add(ttl)
+		return r, nil
+	}
+	return Reservation{}, ErrInvalidReservation
+}
+
+// newID returns a random ID, of a reservation or a review.
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// available is the stock of a product not held by reservations, never below
+// zero: the stock may have been lowered below what is reserved.
+func available(qty, reserved int) int {
+	if qty < reserved {
+		return 0
+	}
+	return qty - reserved
+}
+
+// ReservationReaper releases the reservations of a store that expired. They
+// no longer hold their units anyway, so it only keeps the store from
+// accumulating them.
+type ReservationReaper struct {
+	store  Store
+	logger log.Logger
+
+	// Interval is the time between releases.
+	Interval time.Duration
+}
+
+// NewReservationReaper returns a reaper of the expired reservations of the
+// store.
+func NewReservationReaper(store Store, logger log.Logger) *ReservationReaper {
+	return &ReservationReaper{
+		store:    store,
+		logger:   logger,
+		Interval: time.Minute,
+	}
+}
+
+// Run releases expired reservations until the context is done.
+func (r *ReservationReaper) Run(ctx context.Context) {
+	for {
+		if n, err := r.Reap(ctx); err != nil {
+			r.logger.Log("reservations", "release", "err", err)
+		} else if n > 0 {
+			r.logger.Log("reservations", "release", "expired", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.Interval):
+		}
+	}
+}
+
+// Reap releases the reservations expired by now, and returns how many.
+func (r *ReservationReaper) Reap(ctx context.Context) (int, error) {
+	return r.store.ReleaseExpired(ctx, time.Now().UTC())
+}
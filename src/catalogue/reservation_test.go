@@ -0,0 +1,328 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+package catalogue
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-kit/kit/log"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestNormalizeReservation(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	r, err := normalizeReservation(Reservation{ID: "mine", SKU: " A ", Cart: "c1", Qty: 2}, now)
+	if err != nil || r.ID != "" || r.SKU != "A" || r.TTL != 900 || !r.Created.Equal(now) || !r.Expires.Equal(now.Add(DefaultReservationTTL)) {
+		t.Errorf("normalizeReservation: have %+v, %v", r, err)
+	}
+	if r, err := normalizeReservation(Reservation{SKU: "A", Cart: "c1", Qty: 1, TTL: 60}, now); err != nil || !r.Expires.Equal(now.Add(time.Minute)) {
+		t.Errorf("normalizeReservation with TTL: have %+v, %v", r, err)
+	}
+
+	for name, r := range map[string]Reservation{
+		"no sku":       {Cart: "c1", Qty: 1},
+		"no cart":      {SKU: "A", Qty: 1},
+		"no qty":       {SKU: "A", Cart: "c1"},
+		"negative qty": {SKU: "A", Cart: "c1", Qty: -1},
+		"negative ttl": {SKU: "A", Cart: "c1", Qty: 1, TTL: -1},
+		"ttl too long": {SKU: "A", Cart: "c1", Qty: 1, TTL: 2 * 24 * 60 * 60},
+	} {
+		if _, err := normalizeReservation(r, now); err != ErrInvalidReservation {
+			t.Errorf("%s: want %v, have %v", name, ErrInvalidReservation, err)
+		}
+	}
+}
+
+func TestReservations(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewMemoryStore(testFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	store.(*memoryStore).now = func() time.Time { return now }
+	s := NewCatalogueService(store)
+
+	availableOf := func(id string) int {
+		p, err := s.Get(ctx, id, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p.Available
+	}
+	if have := availableOf("A"); have != 3 {
+		t.Errorf("available of A: want 3, have %d", have)
+	}
+
+	first, err := s.Reserve(ctx, Reservation{SKU: "A", Cart: "c1", Qty: 2})
+	if err != nil || first.ID == "" {
+		t.Fatalf("Reserve: have %+v, %v", first, err)
+	}
+	if have := availableOf("A"); have != 1 {
+		t.Errorf("available of A reserved: want 1, have %d", have)
+	}
+	if _, err := s.Reserve(ctx, Reservation{SKU: "A", Cart: "c2", Qty: 2}); err != ErrInsufficientStock {
+		t.Errorf("Reserve more than available: want %v, have %v", ErrInsufficientStock, err)
+	}
+	if _, err := s.Reserve(ctx, Reservation{SKU: "E", Cart: "c2", Qty: 1}); err != ErrNotFound {
+		t.Errorf("Reserve unknown SKU: want %v, have %v", ErrNotFound, err)
+	}
+	second, err := s.Reserve(ctx, Reservation{SKU: "A", Cart: "c2", Qty: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Committing takes the units out of the stock, in a new version.
+	if r, err := s.CommitReservation(ctx, first.ID); err != nil || r.Qty != 2 {
+		t.Errorf("CommitReservation: have %+v, %v", r, err)
+	}
+	p, _ := s.Get(ctx, "A", "", nil)
+	if p.Qty != 1 || p.Available != 0 || p.Version != 2 {
+		t.Errorf("A committed: want qty 1, available 0, version 2, have %d, %d, %d", p.Qty, p.Available, p.Version)
+	}
+	if _, err := s.CommitReservation(ctx, first.ID); err != ErrNotFound {
+		t.Errorf("CommitReservation again: want %v, have %v", ErrNotFound, err)
+	}
+
+	// Releasing makes them available again.
+	if r, err := s.ReleaseReservation(ctx, second.ID); err != nil || r.SKU != "A" || r.Qty != 1 {
+		t.Errorf("ReleaseReservation: have %+v, %v", r, err)
+	}
+	if _, err := s.ReleaseReservation(ctx, second.ID); err != ErrNotFound {
+		t.Errorf("ReleaseReservation again: want %v, have %v", ErrNotFound, err)
+	}
+	if have := availableOf("A"); have != 1 {
+		t.Errorf("available of A released: want 1, have %d", have)
+	}
+
+	// Expired reservations hold nothing, and cannot be committed.
+	expiring, err := s.Reserve(ctx, Reservation{SKU: "B", Cart: "c3", Qty: 4, TTL: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Minute)
+	if have := availableOf("B"); have != 10 {
+		t.Errorf("available of B past expiry: want 10, have %d", have)
+	}
+	if _, err := store.CommitReservation(ctx, expiring.ID, now); err != ErrReservationExpired {
+		t.Errorf("CommitReservation expired: want %v, have %v", ErrReservationExpired, err)
+	}
+	if n, err := store.ReleaseExpired(ctx, now); err != nil || n != 1 {
+		t.Errorf("ReleaseExpired: want 1, have %d, %v", n, err)
+	}
+	if _, err := s.ReleaseReservation(ctx, expiring.ID); err != ErrNotFound {
+		t.Errorf("ReleaseReservation released: want %v, have %v", ErrNotFound, err)
+	}
+}
+
+func TestReservationsConcurrent(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryService(t)
+
+	// B has 10 units: 10 of 25 carts get one.
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+	reserved := 0
+	for i := 0; i < 25; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Reserve(ctx, Reservation{SKU: "B", Cart: "c", Qty: 1})
+			if err != nil && err != ErrInsufficientStock {
+				t.Error(err)
+			}
+			if err == nil {
+				mtx.Lock()
+				reserved++
+				mtx.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if reserved != 10 {
+		t.Errorf("25 concurrent reservations of 10 units: want 10 made, have %d", reserved)
+	}
+	if p, _ := s.Get(ctx, "B", "", nil); p.Available != 0 {
+		t.Errorf("available of B: want 0, have %d", p.Available)
+	}
+}
+
+func TestReservationsVariants(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryService(t)
+	if _, err := s.Create(ctx, Product{ID: "E", Title: "Collar", Price: 10, Variants: testVariants}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The qty of a product with variants is the sum of theirs, so its stock
+	// is not reserved through it.
+	if _, err := s.Reserve(ctx, Reservation{SKU: "E", Cart: "c1", Qty: 1}); err != ErrInvalidReservation {
+		t.Errorf("Reserve product with variants: want %v, have %v", ErrInvalidReservation, err)
+	}
+
+	// Stock committed stays out of products updated afterwards.
+	r, err := s.Reserve(ctx, Reservation{SKU: "A", Cart: "c1", Qty: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CommitReservation(ctx, r.ID); err != nil {
+		t.Fatal(err)
+	}
+	p, err := s.Get(ctx, "A", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err = s.Update(ctx, p); err != nil || p.Qty != 1 {
+		t.Errorf("A updated after commit: want qty 1, have %d, %v", p.Qty, err)
+	}
+
+	// Nor is it committed once the product has variants.
+	if r, err = s.Reserve(ctx, Reservation{SKU: "A", Cart: "c2", Qty: 1}); err != nil {
+		t.Fatal(err)
+	}
+	p.Variants = []Variant{{ID: "A-S", Size: "S", Qty: 4}}
+	if p, err = s.Update(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CommitReservation(ctx, r.ID); err != ErrInvalidReservation {
+		t.Errorf("CommitReservation of product given variants: want %v, have %v", ErrInvalidReservation, err)
+	}
+	if p, _ = s.Get(ctx, "A", "", nil); p.Qty != 4 {
+		t.Errorf("A with variants: want qty 4, have %d", p.Qty)
+	}
+}
+
+func TestReservationsHTTP(t *testing.T) {
+	h := newTestHandler(newTestMemoryService(t))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/reservations", strings.NewReader(`{"sku": "D", "cart": "c1", "qty": 5}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /reservations: have %d %s", rec.Code, rec.Body)
+	}
+	var r Reservation
+	if err := json.NewDecoder(rec.Body).Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{"POST", "/reservations", `{"sku": "D", "cart": "c2", "qty": 5}`, http.StatusConflict},
+		{"POST", "/reservations", `{"sku": "D", "cart": "c2"}`, http.StatusBadRequest},
+		{"POST", "/reservations", `{"sku": "E", "cart": "c2", "qty": 1}`, http.StatusNotFound},
+		{"POST", "/reservations/" + r.ID + "/commit", "", http.StatusOK},
+		{"POST", "/reservations/" + r.ID + "/commit", "", http.StatusNotFound},
+		{"DELETE", "/reservations/" + r.ID, "", http.StatusNotFound},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+		if rec.Code != tc.want {
+			t.Errorf("%s %s %s: want %d, have %d %s", tc.method, tc.path, tc.body, tc.want, rec.Code, rec.Body)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/catalogue/D", nil))
+	var p Product
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Qty != 2 || p.Available != 2 {
+		t.Errorf("D committed: want qty and available 2, have %d, %d", p.Qty, p.Available)
+	}
+}
+
+func TestReservationsBreaker(t *testing.T) {
+	h := newTestHandler(newTestMemoryService(t))
+
+	// Sold-out reservations are refused, not failures tripping the breaker.
+	for i := 0; i < 10; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/reservations", strings.NewReader(`{"sku": "D", "cart": "c1", "qty": 100}`)))
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("POST /reservations sold out, %d: want %d, have %d %s", i, http.StatusConflict, rec.Code, rec.Body)
+		}
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/reservations", strings.NewReader(`{"sku": "D", "cart": "c1", "qty": 1}`)))
+	if rec.Code != http.StatusCreated {
+		t.Errorf("POST /reservations after sold out: want %d, have %d %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+}
+
+func TestPostgresStoreReservations(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	store := NewPostgresStore(sqlx.NewDb(db, "sqlmock"), log.NewNopLogger())
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := Reservation{ID: "r1", SKU: "A", Cart: "c1", Qty: 2, Created: now, Expires: now.Add(time.Minute)}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COALESCE\\(qty, 0\\) AS qty, EXISTS \\(SELECT 1 FROM product_variant WHERE product_sku = products.sku\\) AS variants FROM products WHERE sku = \\$1 FOR UPDATE").WithArgs("A").
+		WillReturnRows(sqlmock.NewRows([]string{"qty", "variants"}).AddRow(3, false))
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(qty\\), 0\\) FROM reservations WHERE sku = \\$1 AND expires > \\$2").WithArgs("A", now).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO reservations").WithArgs("r1", "A", "c1", 2, now.Add(time.Minute), now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE").WillReturnRows(sqlmock.NewRows([]string{"qty", "variants"}).AddRow(3, false))
+	mock.ExpectQuery("FROM reservations").WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(2))
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE").WillReturnRows(sqlmock.NewRows([]string{"qty", "variants"}).AddRow(3, true))
+	mock.ExpectRollback()
+
+	rows := sqlmock.NewRows([]string{"id", "sku", "cart", "qty", "expires", "created"}).AddRow("r1", "A", "c1", 2, now.Add(time.Minute), now)
+	mock.ExpectBegin()
+	mock.ExpectQuery("DELETE FROM reservations WHERE id = \\$1 RETURNING").WithArgs("r1").WillReturnRows(rows)
+	mock.ExpectQuery("FOR UPDATE").WithArgs("A").WillReturnRows(sqlmock.NewRows([]string{"qty", "variants"}).AddRow(3, false))
+	mock.ExpectExec("UPDATE products SET qty = qty - \\$2, version = version \\+ 1 WHERE sku = \\$1").WithArgs("A", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO catalogue_outbox").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectQuery("DELETE FROM reservations WHERE id = \\$1 RETURNING").WithArgs("r1").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("DELETE FROM reservations WHERE expires <= \\$1").WithArgs(now).WillReturnResult(sqlmock.NewResult(0, 3))
+
+	if have, err := store.Reserve(ctx, r); err != nil || have != r {
+		t.Errorf("Reserve: have %+v, %v", have, err)
+	}
+	if _, err := store.Reserve(ctx, r); err != ErrInsufficientStock {
+		t.Errorf("Reserve more than available: want %v, have %v", ErrInsufficientStock, err)
+	}
+	if _, err := store.Reserve(ctx, r); err != ErrInvalidReservation {
+		t.Errorf("Reserve product with variants: want %v, have %v", ErrInvalidReservation, err)
+	}
+	if have, err := store.CommitReservation(ctx, "r1", now); err != nil || have.SKU != "A" || have.Qty != 2 {
+		t.Errorf("CommitReservation: have %+v, %v", have, err)
+	}
+	if _, err := store.ReleaseReservation(ctx, "r1"); err != ErrNotFound {
+		t.Errorf("ReleaseReservation committed: want %v, have %v", ErrNotFound, err)
+	}
+	if n, err := store.ReleaseExpired(ctx, now); err != nil || n != 3 {
+		t.Errorf("ReleaseExpired: want 3, have %d, %v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
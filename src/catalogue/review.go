@@ -0,0 +1,75 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// review.go contains the reviews customers leave on products, which count
+// toward the rating of a product once a moderator approves them.
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+// ErrInvalidReview is returned when a review has no author or text, or a
+// rating outside 1 to 5 stars, and when moderating a review to a status
+// other than approved or rejected.
+var ErrInvalidReview = errors.New("invalid review")
+
+// The moderation statuses of a review. Reviews are pending until a moderator
+// approves or rejects them, and only approved ones are shown and rated.
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// reviewStatuses are the statuses reviews can be listed by.
+var reviewStatuses = map[string]bool{ReviewPending: true, ReviewApproved: true, ReviewRejected: true}
+
+// Review is a rating of 1 to 5 stars, with text, left by a customer on the
+// product of a SKU.
+type Review struct {
+	ID        string     `json:"id" db:"id"`
+	SKU       string     `json:"sku" db:"sku"`
+	Author    string     `json:"author" db:"author"`
+	Rating    int        `json:"rating" db:"rating"`
+	Text      string     `json:"text" db:"text"`
+	Status    string     `json:"status" db:"status"`
+	Created   time.Time  `json:"created" db:"created"`
+	Moderated *time.Time `json:"moderated,omitempty" db:"moderated"` // when last approved or rejected
+}
+
+// normalizeReview validates a review submitted at a time, which is pending
+// moderation. Its ID is set by the service.
+func normalizeReview(r Review, now time.Time) (Review, error) {
+	r.ID = ""
+	r.SKU = strings.TrimSpace(r.SKU)
+	r.Author = strings.TrimSpace(r.Author)
+	r.Text = strings.TrimSpace(r.Text)
+	switch {
+	case r.SKU == "" || len(r.SKU) > 20:
+	case r.Author == "" || len(r.Author) > 60:
+	case r.Text == "" || len(r.Text) > 2000:
+	case r.Rating < 1 || r.Rating > 5:
+	default:
+		r.Status = ReviewPending
+		r.Created = now.UTC().Truncate(time.Microsecond)
+		r.Moderated = nil
+		return r, nil
+	}
+	return Review{}, ErrInvalidReview
+}
+
+// averageRating is the rating of a product given the stars of its approved
+// reviews in total, to two decimals, or 0 when it has none.
+func averageRating(stars, reviews int) float64 {
+	if reviews == 0 {
+		return 0
+	}
+	return math.Round(float64(stars)/float64(reviews)*100) / 100
+}
@@ -0,0 +1,228 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+package catalogue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-kit/kit/log"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestNormalizeReview(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	r, err := normalizeReview(Review{ID: "mine", SKU: " A ", Author: " Ann ", Rating: 4, Text: " Sturdy. ", Status: ReviewApproved}, now)
+	if err != nil || r.ID != "" || r.SKU != "A" || r.Author != "Ann" || r.Text != "Sturdy." || r.Status != ReviewPending || !r.Created.Equal(now) {
+		t.Errorf("normalizeReview: have %+v, %v", r, err)
+	}
+
+	for name, r := range map[string]Review{
+		"no sku":      {Author: "Ann", Rating: 4, Text: "Sturdy."},
+		"no author":   {SKU: "A", Rating: 4, Text: "Sturdy."},
+		"no text":     {SKU: "A", Author: "Ann", Rating: 4, Text: " "},
+		"no rating":   {SKU: "A", Author: "Ann", Text: "Sturdy."},
+		"six stars":   {SKU: "A", Author: "Ann", Rating: 6, Text: "Sturdy."},
+		"long text":   {SKU: "A", Author: "Ann", Rating: 4, Text: strings.Repeat("a", 2001)},
+		"long author": {SKU: "A", Author: strings.Repeat("a", 61), Rating: 4, Text: "Sturdy."},
+	} {
+		if _, err := normalizeReview(r, now); err != ErrInvalidReview {
+			t.Errorf("%s: want %v, have %v", name, ErrInvalidReview, err)
+		}
+	}
+
+	if have := averageRating(14, 3); have != 4.67 {
+		t.Errorf("averageRating(14, 3): want 4.67, have %v", have)
+	}
+}
+
+func TestReviews(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryService(t)
+
+	review := func(id string, rating int) Review {
+		r, err := s.CreateReview(ctx, Review{SKU: id, Author: "Ann", Rating: rating, Text: "Well."})
+		if err != nil || r.ID == "" || r.Status != ReviewPending {
+			t.Fatalf("CreateReview: have %+v, %v", r, err)
+		}
+		return r
+	}
+	a1, a2, b1 := review("A", 5), review("A", 2), review("B", 4)
+	if _, err := s.CreateReview(ctx, Review{SKU: "E", Author: "Ann", Rating: 5, Text: "Well."}); err != ErrNotFound {
+		t.Errorf("CreateReview of unknown product: want %v, have %v", ErrNotFound, err)
+	}
+
+	// Pending reviews are neither shown nor rated.
+	if have, err := s.Reviews(ctx, "A", 1, 10); err != nil || len(have) != 0 {
+		t.Errorf("Reviews of A pending: have %+v, %v", have, err)
+	}
+	if have, err := s.ListReviews(ctx, ReviewPending, 1, 2); err != nil || len(have) != 2 {
+		t.Errorf("ListReviews pending: want 2, have %+v, %v", have, err)
+	}
+	if p, _ := s.Get(ctx, "A", "", nil); p.Rating != 0 || p.ReviewCount != 0 {
+		t.Errorf("A pending: want rating 0 of 0 reviews, have %v of %d", p.Rating, p.ReviewCount)
+	}
+
+	for _, r := range []Review{a1, a2, b1} {
+		if have, err := s.ModerateReview(ctx, r.ID, ReviewApproved); err != nil || have.Status != ReviewApproved || have.Moderated == nil {
+			t.Errorf("ModerateReview: have %+v, %v", have, err)
+		}
+	}
+	if p, _ := s.Get(ctx, "A", "", nil); p.Rating != 3.5 || p.ReviewCount != 2 {
+		t.Errorf("A approved: want rating 3.5 of 2 reviews, have %v of %d", p.Rating, p.ReviewCount)
+	}
+	if have, err := s.Reviews(ctx, "A", 1, 10); err != nil || len(have) != 2 {
+		t.Errorf("Reviews of A: want 2, have %+v, %v", have, err)
+	}
+
+	// Sorting by rating pages through products as any other key.
+	products, cursor, err := s.List(ctx, Filter{}, "-rating", "", "", nil, 1, 2)
+	if err != nil || len(products) != 2 || products[0].ID != "B" || products[1].ID != "A" {
+		t.Fatalf("List by -rating: have %+v, %v", products, err)
+	}
+	if products, _, err = s.List(ctx, Filter{}, "-rating", cursor, "", nil, 1, 2); err != nil || len(products) != 2 || products[0].ID != "D" || products[1].ID != "C" {
+		t.Errorf("List by -rating after cursor: have %+v, %v", products, err)
+	}
+
+	// Rejected reviews no longer count.
+	if _, err := s.ModerateReview(ctx, a1.ID, ReviewRejected); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := s.Get(ctx, "A", "", nil); p.Rating != 2 || p.ReviewCount != 1 {
+		t.Errorf("A rejected: want rating 2 of 1 review, have %v of %d", p.Rating, p.ReviewCount)
+	}
+
+	for name, err := range map[string]error{
+		"Reviews of unknown product": func() error { _, err := s.Reviews(ctx, "E", 1, 10); return err }(),
+		"ModerateReview unknown":     func() error { _, err := s.ModerateReview(ctx, "x", ReviewApproved); return err }(),
+	} {
+		if err != ErrNotFound {
+			t.Errorf("%s: want %v, have %v", name, ErrNotFound, err)
+		}
+	}
+	for name, err := range map[string]error{
+		"ModerateReview to pending":  func() error { _, err := s.ModerateReview(ctx, a1.ID, ReviewPending); return err }(),
+		"ListReviews unknown status": func() error { _, err := s.ListReviews(ctx, "spam", 1, 10); return err }(),
+	} {
+		if err != ErrInvalidReview {
+			t.Errorf("%s: want %v, have %v", name, ErrInvalidReview, err)
+		}
+	}
+}
+
+func TestReviewsHTTP(t *testing.T) {
+	h := newTestHandler(newTestMemoryService(t))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/catalogue/C/reviews", strings.NewReader(`{"author": "Ann", "rating": 5, "text": "Crunchy indeed."}`)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /catalogue/C/reviews: have %d %s", rec.Code, rec.Body)
+	}
+	var r Review
+	if err := json.NewDecoder(rec.Body).Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	if r.SKU != "C" || r.Status != ReviewPending {
+		t.Errorf("POST /catalogue/C/reviews: have %+v", r)
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{"POST", "/catalogue/C/reviews", `{"author": "Ann", "rating": 0, "text": "Meh."}`, http.StatusBadRequest},
+		{"POST", "/catalogue/E/reviews", `{"author": "Ann", "rating": 5, "text": "Meh."}`, http.StatusNotFound},
+		{"GET", "/admin/reviews?status=pending", "", http.StatusOK},
+		{"GET", "/admin/reviews?status=spam", "", http.StatusBadRequest},
+		{"GET", "/reviews?status=pending", "", http.StatusNotFound},
+		{"POST", "/reviews/x/approve", "", http.StatusNotFound},
+		{"POST", "/reviews/" + r.ID + "/approve", "", http.StatusOK},
+		{"GET", "/catalogue/E/reviews", "", http.StatusNotFound},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+		if rec.Code != tc.want {
+			t.Errorf("%s %s %s: want %d, have %d %s", tc.method, tc.path, tc.body, tc.want, rec.Code, rec.Body)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/catalogue/C/reviews?page=1&size=5", nil))
+	var reviews []Review
+	if err := json.NewDecoder(rec.Body).Decode(&reviews); err != nil {
+		t.Fatal(err)
+	}
+	if len(reviews) != 1 || reviews[0].ID != r.ID || reviews[0].Status != ReviewApproved {
+		t.Errorf("GET /catalogue/C/reviews: have %+v", reviews)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/catalogue/C", nil))
+	var p Product
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Rating != 5 || p.ReviewCount != 1 {
+		t.Errorf("C reviewed: want rating 5 of 1 review, have %v of %d", p.Rating, p.ReviewCount)
+	}
+}
+
+func TestPostgresStoreReviews(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	store := NewPostgresStore(sqlx.NewDb(db, "sqlmock"), log.NewNopLogger())
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := Review{ID: "r1", SKU: "A", Author: "Ann", Rating: 4, Text: "Sturdy.", Status: ReviewPending, Created: now}
+	columns := []string{"id", "sku", "author", "rating", "text", "status", "created", "moderated"}
+
+	mock.ExpectExec("INSERT INTO reviews \\(id, sku, author, rating, text, status, created\\)").WithArgs("r1", "A", "Ann", 4, "Sturdy.", ReviewPending, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("UPDATE reviews SET status = \\$2, moderated = \\$3 WHERE id = \\$1 RETURNING").WithArgs("r1", ReviewApproved, now).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("r1", "A", "Ann", 4, "Sturdy.", ReviewApproved, now, now))
+	mock.ExpectQuery("UPDATE reviews").WithArgs("r2", ReviewApproved, now).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT .* FROM reviews WHERE sku = \\$1 AND status = \\$2 ORDER BY created DESC, id DESC LIMIT \\$3 OFFSET \\$4").WithArgs("A", ReviewApproved, 10, 0).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("r1", "A", "Ann", 4, "Sturdy.", ReviewApproved, now, now))
+	mock.ExpectQuery("SELECT .* FROM reviews WHERE sku = \\$1").WithArgs("E", ReviewApproved, 10, 0).WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("E").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("SELECT .* FROM reviews WHERE status = \\$1 ORDER BY").WithArgs(ReviewPending, 10, 0).WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectQuery("COALESCE\\(ratings.rating, 0\\) AS rating, ratings.review_count, .* LEFT JOIN LATERAL \\(SELECT ROUND\\(AVG\\(reviews.rating\\), 2\\)::float8 AS rating, COUNT\\(\\*\\) AS review_count FROM reviews WHERE reviews.sku = products.sku AND reviews.status = 'approved'\\) ratings ON true .* ORDER BY COALESCE\\(ratings.rating, 0\\) DESC, products.sku DESC").
+		WillReturnRows(sqlmock.NewRows([]string{"ID", "RATING", "REVIEW_COUNT"}).AddRow("A", 4.5, 2))
+
+	if err := store.CreateReview(ctx, r); err != nil {
+		t.Errorf("CreateReview: %v", err)
+	}
+	if have, err := store.ModerateReview(ctx, "r1", ReviewApproved, now); err != nil || have.Status != ReviewApproved || have.Moderated == nil || !have.Moderated.Equal(now) {
+		t.Errorf("ModerateReview: have %+v, %v", have, err)
+	}
+	if _, err := store.ModerateReview(ctx, "r2", ReviewApproved, now); err != ErrNotFound {
+		t.Errorf("ModerateReview unknown: want %v, have %v", ErrNotFound, err)
+	}
+	if have, err := store.Reviews(ctx, "A", ReviewApproved, 0, 10); err != nil || len(have) != 1 || have[0].ID != "r1" {
+		t.Errorf("Reviews: have %+v, %v", have, err)
+	}
+	if _, err := store.Reviews(ctx, "E", ReviewApproved, 0, 10); err != ErrNotFound {
+		t.Errorf("Reviews of unknown product: want %v, have %v", ErrNotFound, err)
+	}
+	if have, err := store.Reviews(ctx, "", ReviewPending, 0, 10); err != nil || len(have) != 0 {
+		t.Errorf("Reviews pending: have %+v, %v", have, err)
+	}
+	if have, err := store.List(ctx, Filter{}, Sort{Key: "rating", Descending: true}, nil, nil, 0, 10); err != nil || len(have) != 1 || have[0].Rating != 4.5 || have[0].ReviewCount != 2 {
+		t.Errorf("List by -rating: have %+v, %v", have, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -9,22 +9,52 @@ package catalogue
 // catalogue service. Everything here is agnostic to the transport (HTTP).
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"strings"
 	"time"
-
-	"github.com/go-kit/kit/log"
-	"github.com/jmoiron/sqlx"
 )
 
-// Service is the catalogue service, providing read operations on a saleable
-// catalogue of MuShop products.
+// Service is the catalogue service, providing read and admin write operations
+// on a saleable catalogue of MuShop products. Methods take the context of the
+// request they serve, which cancels their queries when it is done.
 type Service interface {
-	List(categories []string, order string, pageNum, pageSize int) ([]Product, error) // GET /catalogue
-	Count(categories []string) (int, error)                                           // GET /catalogue/size
-	Get(id string) (Product, error)                                                   // GET /catalogue/{id}
-	Categories() ([]string, error)                                                    // GET /categories
-	Health() []Health                                                                 // GET /health
+	List(ctx context.Context, filter Filter, order, cursor, currency string, languages []string, pageNum, pageSize int) ([]Product, string, error) // GET /catalogue
+	Count(ctx context.Context, filter Filter) (int, error)                                                                                         // GET /catalogue/size
+	Facets(ctx context.Context, filter Filter) (Facets, error)                                                                                     // GET /catalogue/facets
+	Search(ctx context.Context, query string, categories, languages []string, pageNum, pageSize int) ([]Product, error)                            // GET /catalogue/search
+	Suggest(ctx context.Context, query string, size int) ([]Suggestion, error)                                                                     // GET /catalogue/suggest
+	Get(ctx context.Context, id, currency string, languages []string) (Product, error)                                                             // GET /catalogue/{id}
+	Batch(ctx context.Context, ids []string, currency string, languages []string) ([]Product, []string, error)                                     // POST /catalogue/batch
+	Related(ctx context.Context, id, currency string, languages []string, size int) ([]Product, error)                                             // GET /catalogue/{id}/related
+	Create(ctx context.Context, product Product) (Product, error)                                                                                  // POST /catalogue
+	Update(ctx context.Context, product Product) (Product, error)                                                                                  // PUT /catalogue/{id}
+	Delete(ctx context.Context, id string, version int) error                                                                                      // DELETE /catalogue/{id}
+	Import(ctx context.Context, products []Product, dryRun bool) (ImportReport, error)                                                             // POST /catalogue/import
+	Export(ctx context.Context) ([]Product, error)                                                                                                 // GET /admin/catalogue/export
+	Translations(ctx context.Context, id string) ([]Translation, error)                                                                            // GET /catalogue/{id}/translations
+	SetTranslations(ctx context.Context, translations []Translation) ([]Translation, error)                                                        // POST /translations
+	DeleteTranslation(ctx context.Context, id, locale string) error                                                                                // DELETE /catalogue/{id}/translations/{locale}
+	Categories(ctx context.Context) ([]string, error)                                                                                              // GET /categories
+	CategoryTree(ctx context.Context) ([]Category, error)                                                                                          // GET /categories/tree
+	Rates(ctx context.Context) (Rates, error)                                                                                                      // GET /rates
+	SetRates(ctx context.Context, rates Rates) (Rates, error)                                                                                      // PUT /rates
+	SetCoViews(ctx context.Context, coViews CoViews) error                                                                                         // PUT /coviews
+	Promotions(ctx context.Context) ([]Promotion, error)                                                                                           // GET /promotions
+	CreatePromotion(ctx context.Context, promotion Promotion) (Promotion, error)                                                                   // POST /promotions
+	ExpirePromotion(ctx context.Context, id string) (Promotion, error)                                                                             // POST /promotions/{id}/expire
+	PriceHistory(ctx context.Context, id string) ([]PriceChange, error)                                                                            // GET /admin/catalogue/{id}/prices
+	Reviews(ctx context.Context, id string, pageNum, pageSize int) ([]Review, error)                                                               // GET /catalogue/{id}/reviews
+	CreateReview(ctx context.Context, review Review) (Review, error)                                                                               // POST /catalogue/{id}/reviews
+	ListReviews(ctx context.Context, status string, pageNum, pageSize int) ([]Review, error)                                                       // GET /admin/reviews
+	ModerateReview(ctx context.Context, id, status string) (Review, error)                                                                         // POST /reviews/{id}/approve, POST /reviews/{id}/reject
+	Reserve(ctx context.Context, reservation Reservation) (Reservation, error)                                                                     // POST /reservations
+	CommitReservation(ctx context.Context, id string) (Reservation, error)                                                                         // POST /reservations/{id}/commit
+	ReleaseReservation(ctx context.Context, id string) (Reservation, error)                                                                        // DELETE /reservations/{id}
+	ReplayChanges(ctx context.Context, from int64) (int, error)                                                                                    // POST /changes/replay
+	Health(ctx context.Context) []Health                                                                                                           // GET /health
 }
 
 // Middleware decorates a Service.
@@ -32,20 +62,32 @@ type Middleware func(Service) Service
 
 // Product describes the thing on offer in the catalogue.
 type Product struct {
-	ID             string   `json:"id" db:"ID"`
-	Brand          string   `json:"brand" db:"BRAND"`
-	Title          string   `json:"title" db:"TITLE"`
-	Description    string   `json:"description" db:"DESCRIPTION"`
-	Weight         string   `json:"weight" db:"WEIGHT"`
-	ProductSize    string   `json:"product_size" db:"PRODUCT_SIZE"`
-	Colors         string   `json:"colors" db:"COLORS"`
-	Qty            int      `json:"qty" db:"QTY"`
-	Price          float32  `json:"price" db:"PRICE"`
-	ImageURL       []string `json:"imageUrl" db:"-"`
-	ImageURL1      string   `json:"-" db:"IMAGE_URL_1"`
-	ImageURL2      string   `json:"-" db:"IMAGE_URL_2"`
-	Categories     []string `json:"category" db:"-"`
-	CategoryString string   `json:"-" db:"CATEGORIES_NAME"`
+	ID                 string    `json:"id" db:"ID"`
+	Brand              string    `json:"brand" db:"BRAND"`
+	Title              string    `json:"title" db:"TITLE"`
+	Description        string    `json:"description" db:"DESCRIPTION"`
+	Weight             string    `json:"weight" db:"WEIGHT"`
+	ProductSize        string    `json:"product_size" db:"PRODUCT_SIZE"`
+	Colors             string    `json:"colors" db:"COLORS"`
+	Qty                int       `json:"qty" db:"QTY"`
+	Available          int       `json:"available" db:"AVAILABLE"` // of the qty, not held by reservations
+	Rating             float64   `json:"rating" db:"RATING"`       // averaging its approved reviews, 0 without any
+	ReviewCount        int       `json:"reviewCount" db:"REVIEW_COUNT"`
+	Price              float32   `json:"price" db:"PRICE"` // exact to the cent below 2^24 minor units; FormattedPrice is exact beyond
+	Currency           string    `json:"currency" db:"CURRENCY"`
+	FormattedPrice     string    `json:"formattedPrice,omitempty" db:"-"`
+	SalePrice          float32   `json:"salePrice,omitempty" db:"SALE_PRICE"` // by the promotion, when on sale
+	FormattedSalePrice string    `json:"formattedSalePrice,omitempty" db:"-"`
+	PromotionID        string    `json:"promotionId,omitempty" db:"PROMOTION_ID"`
+	Locale             string    `json:"locale,omitempty" db:"LOCALE"` // of the title and description when translated
+	ImageURL           []string  `json:"imageUrl" db:"-"`
+	ImageURL1          string    `json:"-" db:"IMAGE_URL_1"`
+	ImageURL2          string    `json:"-" db:"IMAGE_URL_2"`
+	Categories         []string  `json:"category" db:"-"`
+	CategoryString     string    `json:"-" db:"CATEGORIES_NAME"`
+	Variants           []Variant `json:"variants,omitempty" db:"-"`
+	VariantString      string    `json:"-" db:"VARIANTS"`
+	Version            int       `json:"version" db:"VERSION"`
 }
 
 // Health describes the health of a service
@@ -61,162 +103,680 @@ var ErrNotFound = errors.New("not found")
 // ErrDBConnection is returned when connection with the database fails.
 var ErrDBConnection = errors.New("database connection error")
 
-var baseQuery = "SELECT products.sku AS id, products.brand, products.title, products.description, products.weight, products.product_size, products.colors, products.qty, products.price, products.image_url_1, products.image_url_2, categories_name FROM products LEFT JOIN (SELECT product_category.sku , STRING_AGG(categories.name, ', ' ORDER BY product_category.sku) AS categories_name FROM product_category LEFT OUTER JOIN categories ON product_category.category_id=categories.category_id GROUP BY product_category.sku) categoriesbundle ON products.sku=categoriesbundle.sku"
+// ErrInvalidProduct is returned when a product submitted for writing is
+// missing required fields or carries invalid values.
+var ErrInvalidProduct = errors.New("invalid product")
 
-// NewCatalogueService returns an implementation of the Service interface,
-// with connection to an SQL database.
-func NewCatalogueService(db *sqlx.DB, logger log.Logger) Service {
-	return &catalogueService{
-		db:     db,
-		logger: logger,
+// ErrUnknownCategory is returned when a product refers to a category that does
+// not exist.
+var ErrUnknownCategory = errors.New("unknown category")
+
+// ErrProductExists is returned when creating a product whose ID is taken.
+var ErrProductExists = errors.New("product already exists")
+
+// ErrVersionRequired is returned when a write does not say which version of
+// the product it was based on.
+var ErrVersionRequired = errors.New("product version required")
+
+// ErrVersionConflict is returned when a write was based on a stale version of
+// the product, i.e. somebody else changed it in the meantime.
+var ErrVersionConflict = errors.New("product version conflict")
+
+// ErrInvalidSort is returned when products are to be sorted by an unknown key.
+var ErrInvalidSort = errors.New("invalid sort key")
+
+// ErrInvalidCursor is returned when a cursor cannot be decoded, or was issued
+// for a different sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrBatchTooLarge is returned when more products are requested at once
+// than MaxBatch.
+var ErrBatchTooLarge = errors.New("too many products in batch")
+
+// MaxBatch is the most products a batch returns.
+const MaxBatch = 100
+
+// ErrEmptyQuery is returned when a search is requested without any terms.
+var ErrEmptyQuery = errors.New("search query is required")
+
+// parseSort parses a sort order such as "-price": a key of sortKeys, for
+// descending order prefixed with "-".
+func parseSort(order string) (Sort, error) {
+	if order == "" {
+		order = "id"
 	}
+	var sort Sort
+	if strings.HasPrefix(order, "-") {
+		order, sort.Descending = order[1:], true
+	}
+	if !sortKeys[order] {
+		return Sort{}, ErrInvalidSort
+	}
+	sort.Key = order
+	return sort, nil
 }
 
-type catalogueService struct {
-	db     *sqlx.DB
-	logger log.Logger
+// String returns the order the way parseSort takes it.
+func (o Sort) String() string {
+	if o.Descending {
+		return "-" + o.Key
+	}
+	return o.Key
+}
+
+// value returns the value a product has for the sort key, nil for the ID.
+// Its price is in the currency of the filter.
+func (o Sort) value(f Filter, p Product) interface{} {
+	switch o.Key {
+	case "price":
+		price, _ := f.price(p)
+		return price
+	case "title":
+		return p.Title
+	case "brand":
+		return p.Brand
+	case "qty":
+		return p.Qty
+	case "rating":
+		return p.Rating
+	}
+	return nil
 }
 
-func (s *catalogueService) List(categories []string, order string, pageNum, pageSize int) ([]Product, error) {
-	var products []Product
-	query := baseQuery
+// cursor is the position of the last product of a page, in a given order. It
+// is handed to clients as an opaque string.
+type cursor struct {
+	Sort  string      `json:"s"`
+	Value interface{} `json:"v,omitempty"`
+	ID    string      `json:"id"`
+}
 
-	var args []interface{}
+func encodeCursor(order Sort, f Filter, p Product) string {
+	b, _ := json.Marshal(cursor{Sort: order.String(), Value: order.value(f, p), ID: p.ID})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
 
-	for i, t := range categories {
-		if i == 0 {
-			query += " WHERE categories.name=:categoryname"
-			args = append(args, t)
-		} else {
-			query += " OR categories.name=:categoryname"
-			args = append(args, t)
+// decodeCursor returns the position of a cursor, which must have been issued
+// for the same order.
+func decodeCursor(order Sort, s string) (Position, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Position{}, ErrInvalidCursor
+	}
+	if err = json.Unmarshal(b, &c); err != nil || c.Sort != order.String() || c.ID == "" {
+		return Position{}, ErrInvalidCursor
+	}
+	// The value must be of the type of the key, as JSON decodes it.
+	switch order.value(Filter{}, Product{}).(type) {
+	case nil:
+		c.Value = nil
+	case string:
+		if _, ok := c.Value.(string); !ok {
+			return Position{}, ErrInvalidCursor
 		}
+	default:
+		if _, ok := c.Value.(float64); !ok {
+			return Position{}, ErrInvalidCursor
+		}
+	}
+	return Position{Value: c.Value, ID: c.ID}, nil
+}
+
+// NewCatalogueService returns an implementation of the Service interface,
+// keeping the catalogue in the given store.
+func NewCatalogueService(store Store) Service {
+	return &catalogueService{
+		store: store,
 	}
+}
 
-	query += " GROUP BY products.sku, products.brand, products.title, products.description, products.weight, products.product_size, products.colors, products.qty, products.price, products.image_url_1, products.image_url_2, categories_name"
+type catalogueService struct {
+	store     Store
+	rates     exchangeRates
+	coViews   coViewSignals
+	suggester suggester
+}
 
-	if order != "" {
-		query += " ORDER BY :orderby"
-		args = append(args, order)
+// List, Search and Get present products in the first of the languages, most
+// preferred first, they have a translation for.
+func (s *catalogueService) List(ctx context.Context, filter Filter, order, after, currency string, languages []string, pageNum, pageSize int) ([]Product, string, error) {
+	sort, err := parseSort(order)
+	if err != nil {
+		return []Product{}, "", err
+	}
+	x, err := s.exchange(currency)
+	if err != nil {
+		return []Product{}, "", err
+	}
+	if filter.Currency == "" {
+		filter.Currency = currency
+	}
+	if filter, err = s.priced(filter); err != nil {
+		return []Product{}, "", err
+	}
+	if pageNum <= 0 || pageSize <= 0 {
+		return []Product{}, "", nil // pageNum is 1-indexed
 	}
 
-	err := s.db.Select(&products, query, args...)
+	// A cursor replaces the page number: the page starts right after the
+	// product the cursor points at, which stays stable while rows are added
+	// or removed before it.
+	var position *Position
+	offset := (pageNum - 1) * pageSize
+	if after != "" {
+		p, err := decodeCursor(sort, after)
+		if err != nil {
+			return []Product{}, "", err
+		}
+		position, offset = &p, 0
+	}
+
+	// One extra product tells whether there is a next page.
+	products, err := s.store.List(ctx, filter, sort, localeCandidates(languages), position, offset, pageSize+1)
 	if err != nil {
-		s.logger.Log("database error", err)
-		return []Product{}, ErrDBConnection
+		return []Product{}, "", err
 	}
-	for i, s := range products {
-		products[i].ImageURL = []string{s.ImageURL1, s.ImageURL2}
-		products[i].Categories = strings.Split(s.CategoryString, ",")
+
+	var next string
+	if len(products) > pageSize {
+		products = products[:pageSize]
+		next = encodeCursor(sort, filter, products[pageSize-1])
+	}
+	for i, p := range products {
+		if products[i], err = x.present(p, currency); err != nil {
+			return []Product{}, "", err
+		}
 	}
 
 	// DEMO: Change 0 to 850
 	time.Sleep(0 * time.Millisecond)
 
-	products = cut(products, pageNum, pageSize)
+	return products, next, nil
+}
 
-	return products, nil
+func (s *catalogueService) Count(ctx context.Context, filter Filter) (int, error) {
+	filter, err := s.priced(filter)
+	if err != nil {
+		return 0, err
+	}
+	return s.store.Count(ctx, filter)
 }
 
-func (s *catalogueService) Count(categories []string) (int, error) {
-	query := "SELECT COUNT(DISTINCT products.sku) FROM products JOIN product_category ON products.sku=product_category.sku JOIN categories ON product_category.category_id=categories.category_id"
+func (s *catalogueService) Facets(ctx context.Context, filter Filter) (Facets, error) {
+	filter, err := s.priced(filter)
+	if err != nil {
+		return Facets{}, err
+	}
+	return s.store.Facets(ctx, filter)
+}
 
-	var args []interface{}
+// priced returns a filter comparing prices in its currency, by default the
+// base currency of the exchange rates, whatever currency products are in.
+func (s *catalogueService) priced(filter Filter) (Filter, error) {
+	x := s.rates.get()
+	if filter.Currency == "" {
+		filter.Currency = x.rates.Base
+	}
+	factors, err := x.factors(filter.Currency)
+	if err != nil {
+		return Filter{}, err
+	}
+	filter.factors = factors
+	return filter, nil
+}
 
-	for i, t := range categories {
-		if i == 0 {
-			query += " WHERE categories.name=:categoryname"
-			args = append(args, t)
-		} else {
-			query += " OR categories.name=:categoryname"
-			args = append(args, t)
+func (s *catalogueService) Search(ctx context.Context, query string, categories, languages []string, pageNum, pageSize int) ([]Product, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return []Product{}, ErrEmptyQuery
+	}
+	if pageNum <= 0 || pageSize <= 0 {
+		return []Product{}, nil // pageNum is 1-indexed
+	}
+	products, err := s.store.Search(ctx, query, categories, localeCandidates(languages), (pageNum-1)*pageSize, pageSize)
+	if err != nil {
+		return []Product{}, err
+	}
+	x := s.rates.get()
+	for i, p := range products {
+		if products[i], err = x.present(p, ""); err != nil {
+			return []Product{}, err
 		}
 	}
+	return products, nil
+}
+
+// Suggest returns up to size suggestions completing a query, from an index
+// of the catalogue kept in memory. A blank query, as typed into a search box
+// being cleared, has none.
+func (s *catalogueService) Suggest(ctx context.Context, query string, size int) ([]Suggestion, error) {
+	query = strings.TrimSpace(query)
+	if query == "" || size <= 0 {
+		return []Suggestion{}, nil
+	}
+	if size > maxSuggestions {
+		size = maxSuggestions
+	}
+	index, err := s.suggester.get(ctx, s.suggestIndex)
+	if err != nil {
+		return []Suggestion{}, err
+	}
+	return index.suggest(query, size), nil
+}
+
+// suggestIndex builds the suggest index from the products and categories of
+// the store.
+func (s *catalogueService) suggestIndex(ctx context.Context) (*suggestIndex, error) {
+	built := time.Now()
+	products, err := readAll(ctx, s.store)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := s.store.Categories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newSuggestIndex(products, categories, built), nil
+}
 
-	sel, err := s.db.Prepare(query)
+func (s *catalogueService) Get(ctx context.Context, id, currency string, languages []string) (Product, error) {
+	x, err := s.exchange(currency)
+	if err != nil {
+		return Product{}, err
+	}
+	product, err := s.store.Get(ctx, id, localeCandidates(languages))
+	if err != nil {
+		return Product{}, err
+	}
+	return x.present(product, currency)
+}
 
+// Batch returns the products of the IDs, in their order, and the IDs of
+// products that do not exist. Repeated IDs are returned once.
+func (s *catalogueService) Batch(ctx context.Context, ids []string, currency string, languages []string) ([]Product, []string, error) {
+	ids = distinct(ids)
+	if len(ids) > MaxBatch {
+		return []Product{}, []string{}, ErrBatchTooLarge
+	}
+	x, err := s.exchange(currency)
 	if err != nil {
-		s.logger.Log("database error", err)
-		return 0, ErrDBConnection
+		return []Product{}, []string{}, err
+	}
+	products, missing := []Product{}, []string{}
+	if len(ids) == 0 {
+		return products, missing, nil
 	}
-	defer sel.Close()
+	found, err := s.store.GetMany(ctx, ids, localeCandidates(languages))
+	if err != nil {
+		return []Product{}, []string{}, err
+	}
+	byID := indexByID(found)
+	for _, id := range ids {
+		p, ok := byID[id]
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		if p, err = x.present(p, currency); err != nil {
+			return []Product{}, []string{}, err
+		}
+		products = append(products, p)
+	}
+	return products, missing, nil
+}
 
-	var count int
-	err = sel.QueryRow(args...).Scan(&count)
+// Related returns up to size products related to a product, most related
+// first: those sharing its categories and brand, and those viewed with it
+// according to the co-view signals.
+func (s *catalogueService) Related(ctx context.Context, id, currency string, languages []string, size int) ([]Product, error) {
+	x, err := s.exchange(currency)
+	if err != nil {
+		return []Product{}, err
+	}
+	if size <= 0 {
+		return []Product{}, nil
+	}
+	if size > maxRelated {
+		size = maxRelated
+	}
+	products, err := s.store.Related(ctx, id, s.coViews.get(id), localeCandidates(languages), size)
+	if err != nil {
+		return []Product{}, err
+	}
+	for i, p := range products {
+		if products[i], err = x.present(p, currency); err != nil {
+			return []Product{}, err
+		}
+	}
+	return products, nil
+}
 
+// SetCoViews replaces the co-view signals related products are blended with.
+func (s *catalogueService) SetCoViews(ctx context.Context, coViews CoViews) error {
+	normalized, err := normalizeCoViews(coViews)
 	if err != nil {
-		s.logger.Log("database error", err)
-		return 0, ErrDBConnection
+		return err
 	}
+	s.coViews.set(normalized)
+	return nil
+}
 
-	return count, nil
+// exchange returns the current exchange rates, checking they cover the
+// currency prices are requested in, if any.
+func (s *catalogueService) exchange(currency string) (*exchange, error) {
+	x := s.rates.get()
+	if _, ok := x.parsed[currency]; currency != "" && !ok {
+		return nil, ErrUnknownCurrency
+	}
+	return x, nil
 }
 
-func (s *catalogueService) Get(id string) (Product, error) {
-	query := baseQuery + " WHERE products.sku =:id GROUP BY products.sku, products.brand, products.title, products.description, products.weight, products.product_size, products.colors, products.qty, products.price, products.image_url_1, products.image_url_2, categories_name"
+// Rates returns the exchange rates prices are converted by.
+func (s *catalogueService) Rates(ctx context.Context) (Rates, error) {
+	current := s.rates.get().rates
+	rates := Rates{Base: current.Base, Rates: make(map[string]json.Number, len(current.Rates))}
+	for code, rate := range current.Rates {
+		rates.Rates[code] = rate
+	}
+	return rates, nil
+}
 
-	var product Product
-	err := s.db.Get(&product, query, id)
+// SetRates replaces the exchange rates prices are converted by, and returns
+// them normalized.
+func (s *catalogueService) SetRates(ctx context.Context, rates Rates) (Rates, error) {
+	x, err := newExchange(rates)
 	if err != nil {
-		s.logger.Log("database error", err)
-		return Product{}, ErrNotFound
+		return Rates{}, err
 	}
+	s.rates.set(x)
+	return x.rates, nil
+}
 
-	product.ImageURL = []string{product.ImageURL1, product.ImageURL2}
-	product.Categories = strings.Split(product.CategoryString, ",")
+func (s *catalogueService) Create(ctx context.Context, product Product) (Product, error) {
+	product, err := normalizeProduct(product)
+	if err != nil {
+		return Product{}, err
+	}
 
+	product.Version = 1
+	if err = s.store.Create(ctx, product); err != nil {
+		return Product{}, err
+	}
+	s.suggester.invalidate()
 	return product, nil
 }
 
-func (s *catalogueService) Health() []Health {
-	var health []Health
-	dbstatus := "OK"
+func (s *catalogueService) Update(ctx context.Context, product Product) (Product, error) {
+	product, err := normalizeProduct(product)
+	if err != nil {
+		return Product{}, err
+	}
+	if product.Version <= 0 {
+		return Product{}, ErrVersionRequired
+	}
+
+	if err = s.store.Update(ctx, product); err != nil {
+		return Product{}, err
+	}
+	s.suggester.invalidate()
+	product.Version++
+	return product, nil
+}
 
-	err := s.db.Ping()
+func (s *catalogueService) Delete(ctx context.Context, id string, version int) error {
+	if version <= 0 {
+		return ErrVersionRequired
+	}
+	if err := s.store.Delete(ctx, id, version); err != nil {
+		return err
+	}
+	s.suggester.invalidate()
+	return nil
+}
+
+// Import creates and replaces products in bulk, all or none of them. The
+// products are validated together, and when any is invalid the returned
+// ImportErrors tell what is wrong with each. Products that would not change
+// are left alone. A dry run reports what the import would change without
+// changing anything.
+func (s *catalogueService) Import(ctx context.Context, products []Product, dryRun bool) (ImportReport, error) {
+	categories, err := s.store.Categories(ctx)
+	if err != nil {
+		return ImportReport{}, err
+	}
+	products, err = validateImport(products, categories)
 	if err != nil {
-		dbstatus = "err"
+		return ImportReport{}, err
+	}
+	current, err := readAll(ctx, s.store)
+	if err != nil {
+		return ImportReport{}, err
 	}
 
-	app := Health{"catalogue", "OK", time.Now().String()}
-	db := Health{"postgres:catalogue-data", dbstatus, time.Now().String()}
+	report, changed := planImport(products, indexByID(current))
+	report.DryRun = dryRun
+	if dryRun || len(changed) == 0 {
+		return report, nil
+	}
+	if err = s.store.Import(ctx, changed); err != nil {
+		var errs ImportErrors
+		if errors.As(err, &errs) {
+			for i := range errs {
+				for row, p := range products {
+					if p.ID == errs[i].ID {
+						errs[i].Row = row + 1
+					}
+				}
+			}
+		}
+		return ImportReport{}, err
+	}
+	s.suggester.invalidate()
+	return report, nil
+}
 
-	health = append(health, app)
-	health = append(health, db)
+// Export returns all products, by ID.
+func (s *catalogueService) Export(ctx context.Context) ([]Product, error) {
+	return readAll(ctx, s.store)
+}
 
-	return health
+// Translations returns the translations of a product, by locale.
+func (s *catalogueService) Translations(ctx context.Context, id string) ([]Translation, error) {
+	return s.store.Translations(ctx, id)
 }
 
-func (s *catalogueService) Categories() ([]string, error) {
-	var categories []string
-	query := "SELECT name FROM categories"
-	rows, err := s.db.Query(query)
+// SetTranslations adds or replaces translations, all or none of them, and
+// returns them normalized.
+func (s *catalogueService) SetTranslations(ctx context.Context, translations []Translation) ([]Translation, error) {
+	normalized := make([]Translation, len(translations))
+	for i, t := range translations {
+		var err error
+		if normalized[i], err = normalizeTranslation(t); err != nil {
+			return []Translation{}, err
+		}
+	}
+	if err := s.store.SetTranslations(ctx, normalized); err != nil {
+		return []Translation{}, err
+	}
+	return normalized, nil
+}
+
+func (s *catalogueService) DeleteTranslation(ctx context.Context, id, locale string) error {
+	locale, ok := normalizeLocale(locale)
+	if !ok {
+		return ErrNotFound
+	}
+	return s.store.DeleteTranslation(ctx, id, locale)
+}
+
+// Promotions returns all promotions, including scheduled and expired ones,
+// latest first.
+func (s *catalogueService) Promotions(ctx context.Context) ([]Promotion, error) {
+	return s.store.Promotions(ctx)
+}
+
+// CreatePromotion schedules a promotion, starting now unless it tells
+// otherwise, and returns it normalized.
+func (s *catalogueService) CreatePromotion(ctx context.Context, promotion Promotion) (Promotion, error) {
+	promotion, err := normalizePromotion(promotion, time.Now())
 	if err != nil {
-		s.logger.Log("database error", err)
-		return []string{}, ErrDBConnection
+		return Promotion{}, err
 	}
-	var category string
-	for rows.Next() {
-		err = rows.Scan(&category)
+	if promotion.Category != "" {
+		categories, err := s.store.Categories(ctx)
 		if err != nil {
-			s.logger.Log("database error", err)
-			continue
+			return Promotion{}, err
+		}
+		if !contains(categories, promotion.Category) {
+			return Promotion{}, ErrUnknownCategory
 		}
-		categories = append(categories, category)
 	}
-	return categories, nil
+	return s.store.CreatePromotion(ctx, promotion)
 }
 
-func cut(products []Product, pageNum, pageSize int) []Product {
-	if pageNum == 0 || pageSize == 0 {
-		return []Product{} // pageNum is 1-indexed
+// ExpirePromotion ends a promotion now, unless it already ended. The
+// promotion is kept, for audit.
+func (s *catalogueService) ExpirePromotion(ctx context.Context, id string) (Promotion, error) {
+	return s.store.ExpirePromotion(ctx, id, time.Now().UTC().Truncate(time.Microsecond))
+}
+
+// PriceHistory returns the prices a product had, latest first.
+func (s *catalogueService) PriceHistory(ctx context.Context, id string) ([]PriceChange, error) {
+	return s.store.PriceHistory(ctx, id)
+}
+
+// Reviews returns a page of the approved reviews of a product, newest first.
+func (s *catalogueService) Reviews(ctx context.Context, id string, pageNum, pageSize int) ([]Review, error) {
+	if pageNum <= 0 || pageSize <= 0 {
+		return []Review{}, nil // pageNum is 1-indexed
+	}
+	return s.store.Reviews(ctx, id, ReviewApproved, (pageNum-1)*pageSize, pageSize)
+}
+
+// CreateReview adds a review of a product, pending moderation.
+func (s *catalogueService) CreateReview(ctx context.Context, review Review) (Review, error) {
+	review, err := normalizeReview(review, time.Now())
+	if err != nil {
+		return Review{}, err
+	}
+	if review.ID, err = newID(); err != nil {
+		return Review{}, err
+	}
+	if err = s.store.CreateReview(ctx, review); err != nil {
+		return Review{}, err
+	}
+	return review, nil
+}
+
+// ListReviews returns a page of the reviews of all products in a status,
+// newest first, for moderators.
+func (s *catalogueService) ListReviews(ctx context.Context, status string, pageNum, pageSize int) ([]Review, error) {
+	if !reviewStatuses[status] {
+		return []Review{}, ErrInvalidReview
+	}
+	if pageNum <= 0 || pageSize <= 0 {
+		return []Review{}, nil // pageNum is 1-indexed
+	}
+	return s.store.Reviews(ctx, "", status, (pageNum-1)*pageSize, pageSize)
+}
+
+// ModerateReview approves or rejects a review, which counts toward the rating
+// of its product only while approved.
+func (s *catalogueService) ModerateReview(ctx context.Context, id, status string) (Review, error) {
+	if status != ReviewApproved && status != ReviewRejected {
+		return Review{}, ErrInvalidReview
+	}
+	return s.store.ModerateReview(ctx, id, status, time.Now().UTC().Truncate(time.Microsecond))
+}
+
+// Reserve holds units of a product for a cart, for the TTL of the
+// reservation in seconds or DefaultReservationTTL, unless fewer are
+// available.
+func (s *catalogueService) Reserve(ctx context.Context, reservation Reservation) (Reservation, error) {
+	reservation, err := normalizeReservation(reservation, time.Now())
+	if err != nil {
+		return Reservation{}, err
+	}
+	if reservation.ID, err = newID(); err != nil {
+		return Reservation{}, err
+	}
+	return s.store.Reserve(ctx, reservation)
+}
+
+// CommitReservation takes the units of a reservation that has not expired
+// out of the stock of its product, as an order of the cart is placed.
+func (s *catalogueService) CommitReservation(ctx context.Context, id string) (Reservation, error) {
+	return s.store.CommitReservation(ctx, id, time.Now().UTC().Truncate(time.Microsecond))
+}
+
+// ReleaseReservation makes the units of a reservation available again, as
+// when its cart is abandoned before it expires.
+func (s *catalogueService) ReleaseReservation(ctx context.Context, id string) (Reservation, error) {
+	return s.store.ReleaseReservation(ctx, id)
+}
+
+// ReplayChanges publishes the changes of products from an offset on again,
+// and returns how many were published already.
+func (s *catalogueService) ReplayChanges(ctx context.Context, from int64) (int, error) {
+	return s.store.ReplayChanges(ctx, from)
+}
+
+// normalizeProduct validates a product submitted for writing and fills in
+// the storage-only fields from their client-facing counterparts.
+func normalizeProduct(product Product) (Product, error) {
+	product.ID = strings.TrimSpace(product.ID)
+	product.Title = strings.TrimSpace(product.Title)
+	if product.ID == "" || product.Title == "" || product.Price < 0 || product.Qty < 0 || len(product.ImageURL) > 2 {
+		return Product{}, ErrInvalidProduct
 	}
-	start := (pageNum * pageSize) - pageSize
-	if start > len(products) {
-		return []Product{}
+	currency, ok := normalizeCurrency(product.Currency)
+	if !ok {
+		return Product{}, ErrInvalidProduct
 	}
-	end := (pageNum * pageSize)
-	if end > len(products) {
-		end = len(products)
+	product.Currency = currency
+	product.FormattedPrice = ""
+	product.SalePrice, product.FormattedSalePrice, product.PromotionID = 0, "", ""
+	product.Locale = ""
+	product.Available = 0
+	product.Rating, product.ReviewCount = 0, 0
+	product, err := normalizeVariants(product)
+	if err != nil {
+		return Product{}, err
 	}
-	return products[start:end]
+
+	seen := make(map[string]bool, len(product.Categories))
+	categories := make([]string, 0, len(product.Categories))
+	for _, c := range product.Categories {
+		c = strings.TrimSpace(c)
+		if c == "" || seen[c] {
+			continue
+		}
+		seen[c] = true
+		categories = append(categories, c)
+	}
+	product.Categories = categories
+	product.CategoryString = strings.Join(categories, ", ")
+
+	images := make([]string, 2)
+	copy(images, product.ImageURL)
+	product.ImageURL = images
+	product.ImageURL1, product.ImageURL2 = images[0], images[1]
+
+	return product, nil
+}
+
+func (s *catalogueService) Health(ctx context.Context) []Health {
+	app := Health{"catalogue", "OK", time.Now().String()}
+	return []Health{app, s.store.Health(ctx)}
+}
+
+func (s *catalogueService) Categories(ctx context.Context) ([]string, error) {
+	return s.store.Categories(ctx)
+}
+
+// CategoryTree returns the categories as a tree, for navigation: a filter
+// by one of them matches the products of its subtree.
+func (s *catalogueService) CategoryTree(ctx context.Context) ([]Category, error) {
+	return s.store.CategoryTree(ctx)
 }
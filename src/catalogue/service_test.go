@@ -5,14 +5,18 @@
 package catalogue
 
 import (
+	"context"
+	"errors"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/go-kit/kit/log"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 var (
@@ -22,13 +26,17 @@ var (
 	s4 = Product{ID: "4", Brand: "brand4", Title: "title4", Description: "description4", Weight: "4oz", ProductSize: "4x4", Colors: "gray", Price: 1.4, Qty: 4, ImageURL: []string{"ImageUrl_14", "ImageUrl_24"}, ImageURL1: "ImageUrl_14", ImageURL2: "ImageUrl_24", Categories: []string{"even"}, CategoryString: "even"}
 	s5 = Product{ID: "5", Brand: "brand5", Title: "title5", Description: "description5", Weight: "5oz", ProductSize: "5x5", Colors: "black", Price: 1.5, Qty: 5, ImageURL: []string{"ImageUrl_15", "ImageUrl_25"}, ImageURL1: "ImageUrl_15", ImageURL2: "ImageUrl_25", Categories: []string{"odd", "prime"}, CategoryString: "odd,prime"}
 
-	products   = []Product{s1, s2, s3, s4, s5}
 	categories = []string{"odd", "even", "prime"}
 )
 
 var logger log.Logger
 
+// convertedPrice matches the price of products converted to the currency of
+// a filter.
+const convertedPrice = "\\(products.price::float8 \\* \\(SELECT rate.factor FROM unnest\\(\\$\\d+::text\\[\\], \\$\\d+::float8\\[\\]\\) rate\\(currency, factor\\) WHERE rate.currency = products.currency\\)\\)"
+
 func TestCatalogueServiceList(t *testing.T) {
+	ctx := context.Background()
 	logger = log.NewLogfmtLogger(os.Stderr)
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -40,26 +48,35 @@ func TestCatalogueServiceList(t *testing.T) {
 	var cols []string = []string{"ID", "BRAND", "TITLE", "DESCRIPTION", "WEIGHT", "PRODUCT_SIZE", "COLORS", "PRICE", "QTY", "IMAGE_URL_1", "IMAGE_URL_2", "CATEGORIES_NAME"}
 
 	// Test Case 1
-	mock.ExpectQuery("SELECT *").WillReturnRows(sqlmock.NewRows(cols).
-		AddRow(s1.ID, s1.Brand, s1.Title, s1.Description, s1.Weight, s1.ProductSize, s1.Colors, s1.Price, s1.Qty, s1.ImageURL[0], s1.ImageURL[1], strings.Join(s1.Categories, ",")).
-		AddRow(s2.ID, s2.Brand, s2.Title, s2.Description, s2.Weight, s2.ProductSize, s2.Colors, s2.Price, s2.Qty, s2.ImageURL[0], s2.ImageURL[1], strings.Join(s2.Categories, ",")).
-		AddRow(s3.ID, s3.Brand, s3.Title, s3.Description, s3.Weight, s3.ProductSize, s3.Colors, s3.Price, s3.Qty, s3.ImageURL[0], s3.ImageURL[1], strings.Join(s3.Categories, ",")).
-		AddRow(s4.ID, s4.Brand, s4.Title, s4.Description, s4.Weight, s4.ProductSize, s4.Colors, s4.Price, s4.Qty, s4.ImageURL[0], s4.ImageURL[1], strings.Join(s4.Categories, ",")).
-		AddRow(s5.ID, s5.Brand, s5.Title, s5.Description, s5.Weight, s5.ProductSize, s5.Colors, s5.Price, s5.Qty, s5.ImageURL[0], s5.ImageURL[1], strings.Join(s5.Categories, ",")))
+	mock.ExpectQuery("SELECT .* ORDER BY products.sku ASC LIMIT \\$1 OFFSET \\$2").
+		WithArgs(6, 0).
+		WillReturnRows(sqlmock.NewRows(cols).
+			AddRow(s1.ID, s1.Brand, s1.Title, s1.Description, s1.Weight, s1.ProductSize, s1.Colors, s1.Price, s1.Qty, s1.ImageURL[0], s1.ImageURL[1], strings.Join(s1.Categories, ",")).
+			AddRow(s2.ID, s2.Brand, s2.Title, s2.Description, s2.Weight, s2.ProductSize, s2.Colors, s2.Price, s2.Qty, s2.ImageURL[0], s2.ImageURL[1], strings.Join(s2.Categories, ",")).
+			AddRow(s3.ID, s3.Brand, s3.Title, s3.Description, s3.Weight, s3.ProductSize, s3.Colors, s3.Price, s3.Qty, s3.ImageURL[0], s3.ImageURL[1], strings.Join(s3.Categories, ",")).
+			AddRow(s4.ID, s4.Brand, s4.Title, s4.Description, s4.Weight, s4.ProductSize, s4.Colors, s4.Price, s4.Qty, s4.ImageURL[0], s4.ImageURL[1], strings.Join(s4.Categories, ",")).
+			AddRow(s5.ID, s5.Brand, s5.Title, s5.Description, s5.Weight, s5.ProductSize, s5.Colors, s5.Price, s5.Qty, s5.ImageURL[0], s5.ImageURL[1], strings.Join(s5.Categories, ",")))
 
-	// Test Case 2
-	mock.ExpectQuery("SELECT *").WillReturnRows(sqlmock.NewRows(cols).
-		AddRow(s4.ID, s4.Brand, s4.Title, s4.Description, s4.Weight, s4.ProductSize, s4.Colors, s4.Price, s4.Qty, s4.ImageURL[0], s4.ImageURL[1], strings.Join(s4.Categories, ",")).
-		AddRow(s1.ID, s1.Brand, s1.Title, s1.Description, s1.Weight, s1.ProductSize, s1.Colors, s1.Price, s1.Qty, s1.ImageURL[0], s1.ImageURL[1], strings.Join(s1.Categories, ",")).
-		AddRow(s2.ID, s2.Brand, s2.Title, s2.Description, s2.Weight, s2.ProductSize, s2.Colors, s2.Price, s2.Qty, s2.ImageURL[0], s2.ImageURL[1], strings.Join(s2.Categories, ",")))
-
-	// // Test Case 3
-	mock.ExpectQuery("SELECT *").WillReturnRows(sqlmock.NewRows(cols).
-		AddRow(s1.ID, s1.Brand, s1.Title, s1.Description, s1.Weight, s1.ProductSize, s1.Colors, s1.Price, s1.Qty, s1.ImageURL[0], s1.ImageURL[1], strings.Join(s1.Categories, ",")).
-		AddRow(s3.ID, s3.Brand, s3.Title, s3.Description, s3.Weight, s3.ProductSize, s3.Colors, s3.Price, s3.Qty, s3.ImageURL[0], s3.ImageURL[1], strings.Join(s3.Categories, ",")).
-		AddRow(s5.ID, s5.Brand, s5.Title, s5.Description, s5.Weight, s5.ProductSize, s5.Colors, s5.Price, s5.Qty, s5.ImageURL[0], s5.ImageURL[1], strings.Join(s5.Categories, ",")))
-
-	s := NewCatalogueService(sqlxDB, logger)
+	// Test Case 2, by the price in the base currency
+	mock.ExpectQuery("SELECT .* ORDER BY COALESCE\\("+convertedPrice+", 0\\) DESC, products.sku DESC LIMIT \\$3 OFFSET \\$4").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 4, 0).
+		WillReturnRows(sqlmock.NewRows(cols).
+			AddRow(s5.ID, s5.Brand, s5.Title, s5.Description, s5.Weight, s5.ProductSize, s5.Colors, s5.Price, s5.Qty, s5.ImageURL[0], s5.ImageURL[1], strings.Join(s5.Categories, ",")).
+			AddRow(s4.ID, s4.Brand, s4.Title, s4.Description, s4.Weight, s4.ProductSize, s4.Colors, s4.Price, s4.Qty, s4.ImageURL[0], s4.ImageURL[1], strings.Join(s4.Categories, ",")).
+			AddRow(s3.ID, s3.Brand, s3.Title, s3.Description, s3.Weight, s3.ProductSize, s3.Colors, s3.Price, s3.Qty, s3.ImageURL[0], s3.ImageURL[1], strings.Join(s3.Categories, ",")))
+
+	// Test Case 3
+	mock.ExpectQuery("SELECT .* WHERE products.sku IN \\(SELECT product_category.sku.*WITH RECURSIVE subtree .* WHERE name = ANY\\(\\$1\\) .* ORDER BY products.sku ASC LIMIT \\$2 OFFSET \\$3").
+		WithArgs(sqlmock.AnyArg(), 3, 2).
+		WillReturnRows(sqlmock.NewRows(cols).
+			AddRow(s5.ID, s5.Brand, s5.Title, s5.Description, s5.Weight, s5.ProductSize, s5.Colors, s5.Price, s5.Qty, s5.ImageURL[0], s5.ImageURL[1], strings.Join(s5.Categories, ",")))
+
+	// Test Case 4
+	mock.ExpectQuery("SELECT .* ORDER BY COALESCE\\(products.title, ''\\) ASC, products.sku ASC LIMIT \\$1 OFFSET \\$2").
+		WithArgs(3, 10).
+		WillReturnRows(sqlmock.NewRows(cols))
+
+	s := NewCatalogueService(NewPostgresStore(sqlxDB, logger))
 	for _, testcase := range []struct {
 		categories []string
 		order      string
@@ -76,10 +93,10 @@ func TestCatalogueServiceList(t *testing.T) {
 		},
 		{
 			categories: []string{},
-			order:      "category",
+			order:      "-price",
 			pageNum:    1,
 			pageSize:   3,
-			want:       []Product{s4, s1, s2},
+			want:       []Product{s5, s4, s3},
 		},
 		{
 			categories: []string{"odd"},
@@ -88,8 +105,22 @@ func TestCatalogueServiceList(t *testing.T) {
 			pageSize:   2,
 			want:       []Product{s5},
 		},
+		{
+			categories: []string{},
+			order:      "title",
+			pageNum:    6,
+			pageSize:   2,
+			want:       []Product{},
+		},
+		{
+			categories: []string{},
+			order:      "price",
+			pageNum:    0,
+			pageSize:   2,
+			want:       []Product{}, // pageNum 0 is invalid
+		},
 	} {
-		have, err := s.List(testcase.categories, testcase.order, testcase.pageNum, testcase.pageSize)
+		have, _, err := s.List(ctx, Filter{Categories: testcase.categories}, testcase.order, "", "", nil, testcase.pageNum, testcase.pageSize)
 		if err != nil {
 			t.Errorf(
 				"List(%v, %s, %d, %d): returned error %s",
@@ -105,9 +136,87 @@ func TestCatalogueServiceList(t *testing.T) {
 			)
 		}
 	}
+
+	// Error case: unknown sort keys are rejected before querying.
+	for _, order := range []string{"category", "-", "--price"} {
+		if _, _, have := s.List(ctx, Filter{}, order, "", "", nil, 1, 10); have != ErrInvalidSort {
+			t.Errorf("List(%s): want %v, have %v", order, ErrInvalidSort, have)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCatalogueServiceListCursor(t *testing.T) {
+	ctx := context.Background()
+	logger = log.NewLogfmtLogger(os.Stderr)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+
+	var cols []string = []string{"ID", "BRAND", "TITLE", "DESCRIPTION", "WEIGHT", "PRODUCT_SIZE", "COLORS", "PRICE", "QTY", "IMAGE_URL_1", "IMAGE_URL_2", "CATEGORIES_NAME", "CURRENCY"}
+
+	// First page, the extra row signals a next page.
+	mock.ExpectQuery("SELECT .* ORDER BY COALESCE\\("+convertedPrice+", 0\\) DESC, products.sku DESC LIMIT \\$3 OFFSET \\$4").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 3, 0).
+		WillReturnRows(sqlmock.NewRows(cols).
+			AddRow(s5.ID, s5.Brand, s5.Title, s5.Description, s5.Weight, s5.ProductSize, s5.Colors, s5.Price, s5.Qty, s5.ImageURL[0], s5.ImageURL[1], strings.Join(s5.Categories, ","), "USD").
+			AddRow(s4.ID, s4.Brand, s4.Title, s4.Description, s4.Weight, s4.ProductSize, s4.Colors, s4.Price, s4.Qty, s4.ImageURL[0], s4.ImageURL[1], strings.Join(s4.Categories, ","), "USD").
+			AddRow(s3.ID, s3.Brand, s3.Title, s3.Description, s3.Weight, s3.ProductSize, s3.Colors, s3.Price, s3.Qty, s3.ImageURL[0], s3.ImageURL[1], strings.Join(s3.Categories, ","), "USD"))
+
+	// Second page, starting after the last product of the first, by its
+	// price in the base currency.
+	mock.ExpectQuery("SELECT .* WHERE \\(COALESCE\\("+convertedPrice+", 0\\), products.sku\\) < \\(\\$3, \\$4\\) .* LIMIT \\$5 OFFSET \\$6").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1.4, s4.ID, 3, 0).
+		WillReturnRows(sqlmock.NewRows(cols).
+			AddRow(s3.ID, s3.Brand, s3.Title, s3.Description, s3.Weight, s3.ProductSize, s3.Colors, s3.Price, s3.Qty, s3.ImageURL[0], s3.ImageURL[1], strings.Join(s3.Categories, ","), "USD"))
+
+	s := NewCatalogueService(NewPostgresStore(sqlxDB, logger))
+
+	have, next, err := s.List(ctx, Filter{}, "-price", "", "", nil, 1, 2)
+	if err != nil {
+		t.Fatalf("List(-price): returned error %s", err.Error())
+	}
+	if want := []Product{s5, s4}; printIDs(want).String() != printIDs(have).String() {
+		t.Errorf("List(-price): want %s, have %s", printIDs(want), printIDs(have))
+	}
+	if next == "" {
+		t.Fatalf("List(-price): want a next cursor")
+	}
+
+	// The page number is ignored once there is a cursor.
+	have, last, err := s.List(ctx, Filter{}, "-price", next, "", nil, 7, 2)
+	if err != nil {
+		t.Fatalf("List(-price, %s): returned error %s", next, err.Error())
+	}
+	if want := []Product{s3}; printIDs(want).String() != printIDs(have).String() {
+		t.Errorf("List(-price, %s): want %s, have %s", next, printIDs(want), printIDs(have))
+	}
+	if last != "" {
+		t.Errorf("List(-price, %s): want no next cursor, have %s", next, last)
+	}
+
+	// Error case: cursors are bound to their sort order.
+	for order, c := range map[string]string{
+		"price": next,
+		"id":    next,
+		"-qty":  "not a cursor",
+	} {
+		if _, _, have := s.List(ctx, Filter{}, order, c, "", nil, 1, 2); have != ErrInvalidCursor {
+			t.Errorf("List(%s, %s): want %v, have %v", order, c, ErrInvalidCursor, have)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
 }
 
 func TestCatalogueServiceCount(t *testing.T) {
+	ctx := context.Background()
 	logger = log.NewLogfmtLogger(os.Stderr)
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -118,20 +227,25 @@ func TestCatalogueServiceCount(t *testing.T) {
 
 	var cols []string = []string{"count"}
 
-	mock.ExpectPrepare("SELECT *").ExpectQuery().WillReturnRows(sqlmock.NewRows(cols).AddRow(5))
-	mock.ExpectPrepare("SELECT *").ExpectQuery().WillReturnRows(sqlmock.NewRows(cols).AddRow(4))
-	mock.ExpectPrepare("SELECT *").ExpectQuery().WillReturnRows(sqlmock.NewRows(cols).AddRow(1))
+	mock.ExpectPrepare("SELECT COUNT\\(\\*\\) FROM products$").ExpectQuery().WithArgs().WillReturnRows(sqlmock.NewRows(cols).AddRow(5))
+	mock.ExpectPrepare("SELECT COUNT\\(\\*\\) FROM products WHERE products.sku IN \\(SELECT .* WHERE name = ANY\\(\\$1\\) .* ON product_category.category_id = subtree.category_id\\)$").ExpectQuery().WithArgs(sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows(cols).AddRow(4))
+	mock.ExpectPrepare("SELECT COUNT\\(\\*\\) FROM products WHERE products.sku IN \\(SELECT .* WHERE name = ANY\\(\\$1\\) .* ON product_category.category_id = subtree.category_id\\)$").ExpectQuery().WithArgs(sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows(cols).AddRow(5))
+	mock.ExpectPrepare("SELECT COUNT\\(\\*\\) FROM products WHERE products.sku IN \\(SELECT .* WHERE name = ANY\\(\\$1\\) .* GROUP BY product_category.sku HAVING COUNT\\(DISTINCT subtree.root\\) = \\$2\\)$").ExpectQuery().WithArgs(sqlmock.AnyArg(), 2).WillReturnRows(sqlmock.NewRows(cols).AddRow(1))
+	mock.ExpectPrepare("SELECT COUNT\\(\\*\\) FROM products WHERE products.sku IN \\(SELECT .* WHERE name = ANY\\(\\$1\\) .* ON product_category.category_id = subtree.category_id\\)$").ExpectQuery().WithArgs(sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows(cols).AddRow(4))
 
-	s := NewCatalogueService(sqlxDB, logger)
+	s := NewCatalogueService(NewPostgresStore(sqlxDB, logger))
 	for _, testcase := range []struct {
 		categories []string
+		matchAll   bool
 		want       int
 	}{
-		{[]string{}, 5},
-		{[]string{"prime"}, 4},
-		{[]string{"even", "prime"}, 1},
+		{[]string{}, false, 5},
+		{[]string{"prime"}, false, 4},
+		{[]string{"even", "prime"}, false, 5},
+		{[]string{"even", "prime"}, true, 1},
+		{[]string{"prime", " prime"}, true, 4}, // a single distinct category
 	} {
-		have, err := s.Count(testcase.categories)
+		have, err := s.Count(ctx, Filter{Categories: testcase.categories, MatchAll: testcase.matchAll})
 		if err != nil {
 			t.Errorf(
 				"Count(%v): (%s) returned error %s",
@@ -140,12 +254,161 @@ func TestCatalogueServiceCount(t *testing.T) {
 			)
 		}
 		if want := testcase.want; want != have {
-			t.Errorf("Count(%v): want %d, have %d", testcase.categories, want, have)
+			t.Errorf("Count(%v, %t): want %d, have %d", testcase.categories, testcase.matchAll, want, have)
 		}
 	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCatalogueServiceListFilter(t *testing.T) {
+	ctx := context.Background()
+	logger = log.NewLogfmtLogger(os.Stderr)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+
+	var cols []string = []string{"ID", "BRAND", "TITLE", "DESCRIPTION", "WEIGHT", "PRODUCT_SIZE", "COLORS", "PRICE", "QTY", "IMAGE_URL_1", "IMAGE_URL_2", "CATEGORIES_NAME"}
+
+	mock.ExpectQuery("SELECT .* WHERE "+convertedPrice+" >= \\$3 AND "+convertedPrice+" <= \\$4 AND products.brand = ANY\\(\\$5\\) AND EXISTS \\(.*products.colors.* = ANY\\(\\$6\\)\\) AND EXISTS \\(.*products.product_size.* = ANY\\(\\$7\\)\\) .* LIMIT \\$8 OFFSET \\$9").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1.2, 1.4, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 11, 0).
+		WillReturnRows(sqlmock.NewRows(cols).
+			AddRow(s3.ID, s3.Brand, s3.Title, s3.Description, s3.Weight, s3.ProductSize, s3.Colors, s3.Price, s3.Qty, s3.ImageURL[0], s3.ImageURL[1], strings.Join(s3.Categories, ",")))
+
+	s := NewCatalogueService(NewPostgresStore(sqlxDB, logger))
+
+	min, max := 1.2, 1.4
+	filter := Filter{MinPrice: &min, MaxPrice: &max, Brands: []string{"brand2", "brand3"}, Colors: []string{"Blue"}, Sizes: []string{"3x3"}}
+	have, _, err := s.List(ctx, filter, "id", "", "", nil, 1, 10)
+	if err != nil {
+		t.Errorf("List(%s): returned error %s", formatFilter(filter), err.Error())
+	}
+	if want := []Product{s3}; !reflect.DeepEqual(want, have) {
+		t.Errorf("List(%s): want %s, have %s", formatFilter(filter), printIDs(want), printIDs(have))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCatalogueServiceFacets(t *testing.T) {
+	ctx := context.Background()
+	logger = log.NewLogfmtLogger(os.Stderr)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+
+	var cols []string = []string{"value", "count"}
+
+	// Brands are counted without the brand filter, but with the others.
+	mock.ExpectQuery("SELECT products.brand, COUNT\\(DISTINCT products.sku\\) FROM products WHERE products.sku IN .* AND "+convertedPrice+" <= \\$4 .* GROUP BY").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1.5).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow("brand1", 1).AddRow("brand3", 1))
+	mock.ExpectQuery("SELECT lower\\(color\\), COUNT\\(DISTINCT products.sku\\) FROM products, regexp_split_to_table").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1.5, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow("red", 1))
+	mock.ExpectQuery("SELECT categories.name, COUNT\\(DISTINCT products.sku\\) FROM products JOIN product_category").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1.5, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow("prime", 1).AddRow("odd", 1))
+	mock.ExpectQuery("SELECT width_bucket\\("+convertedPrice+"::float8, \\$1::float8\\[\\]\\), COUNT\\(\\*\\) FROM products").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"bucket", "count"}).AddRow(0, 1).AddRow(4, 2))
+
+	s := NewCatalogueService(NewPostgresStore(sqlxDB, logger))
+
+	max := 1.5
+	have, err := s.Facets(ctx, Filter{Categories: []string{"odd"}, MaxPrice: &max, Brands: []string{"brand1"}})
+	if err != nil {
+		t.Fatalf("Facets(): returned error %s", err.Error())
+	}
+	if want := []FacetCount{{"brand1", 1}, {"brand3", 1}}; !reflect.DeepEqual(want, have.Brands) {
+		t.Errorf("Facets().Brands: want %v, have %v", want, have.Brands)
+	}
+	if want := []FacetCount{{"red", 1}}; !reflect.DeepEqual(want, have.Colors) {
+		t.Errorf("Facets().Colors: want %v, have %v", want, have.Colors)
+	}
+	if want := []FacetCount{{"prime", 1}, {"odd", 1}}; !reflect.DeepEqual(want, have.Categories) {
+		t.Errorf("Facets().Categories: want %v, have %v", want, have.Categories)
+	}
+	if want, have := []int{1, 0, 0, 0, 2}, have.Prices; len(have) != len(want) {
+		t.Errorf("Facets().Prices: want %d buckets, have %d", len(want), len(have))
+	} else {
+		for i := range want {
+			if have[i].Count != want[i] {
+				t.Errorf("Facets().Prices[%d]: want %d, have %d", i, want[i], have[i].Count)
+			}
+		}
+		if have[4].Min != 100 || have[4].Max != nil {
+			t.Errorf("Facets().Prices[4]: want 100 and up, have %v to %v", have[4].Min, have[4].Max)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCatalogueServiceSearch(t *testing.T) {
+	ctx := context.Background()
+	logger = log.NewLogfmtLogger(os.Stderr)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+
+	var cols []string = []string{"ID", "BRAND", "TITLE", "DESCRIPTION", "WEIGHT", "PRODUCT_SIZE", "COLORS", "PRICE", "QTY", "IMAGE_URL_1", "IMAGE_URL_2", "CATEGORIES_NAME"}
+
+	// Test Case 1
+	mock.ExpectQuery("SELECT .* plainto_tsquery.* ORDER BY ts_rank").
+		WithArgs("title", 10, 0).
+		WillReturnRows(sqlmock.NewRows(cols).
+			AddRow(s3.ID, s3.Brand, s3.Title, s3.Description, s3.Weight, s3.ProductSize, s3.Colors, s3.Price, s3.Qty, s3.ImageURL[0], s3.ImageURL[1], strings.Join(s3.Categories, ",")).
+			AddRow(s1.ID, s1.Brand, s1.Title, s1.Description, s1.Weight, s1.ProductSize, s1.Colors, s1.Price, s1.Qty, s1.ImageURL[0], s1.ImageURL[1], strings.Join(s1.Categories, ",")))
+
+	// Test Case 2
+	mock.ExpectQuery("SELECT .* plainto_tsquery.*WITH RECURSIVE subtree .* WHERE name = ANY").
+		WithArgs("title", sqlmock.AnyArg(), 2, 2).
+		WillReturnRows(sqlmock.NewRows(cols))
+
+	s := NewCatalogueService(NewPostgresStore(sqlxDB, logger))
+	for _, testcase := range []struct {
+		query      string
+		categories []string
+		pageNum    int
+		pageSize   int
+		want       []Product
+	}{
+		{"title", []string{}, 1, 10, []Product{s3, s1}},
+		{" title ", []string{"odd"}, 2, 2, []Product{}},
+	} {
+		have, err := s.Search(ctx, testcase.query, testcase.categories, nil, testcase.pageNum, testcase.pageSize)
+		if err != nil {
+			t.Errorf("Search(%q, %v, %d, %d): returned error %s", testcase.query, testcase.categories, testcase.pageNum, testcase.pageSize, err.Error())
+		}
+		if want := testcase.want; !reflect.DeepEqual(want, have) {
+			t.Errorf("Search(%q, %v, %d, %d): want %s, have %s", testcase.query, testcase.categories, testcase.pageNum, testcase.pageSize, printIDs(want), printIDs(have))
+		}
+	}
+
+	// Error case: no database round trip for a blank query.
+	if _, have := s.Search(ctx, "  ", nil, nil, 1, 10); have != ErrEmptyQuery {
+		t.Errorf("Search(blank): want %v, have %v", ErrEmptyQuery, have)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
 }
 
 func TestCatalogueServiceGet(t *testing.T) {
+	ctx := context.Background()
 	logger = log.NewLogfmtLogger(os.Stderr)
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -157,21 +420,25 @@ func TestCatalogueServiceGet(t *testing.T) {
 	var cols []string = []string{"ID", "BRAND", "TITLE", "DESCRIPTION", "WEIGHT", "PRODUCT_SIZE", "COLORS", "PRICE", "QTY", "IMAGE_URL_1", "IMAGE_URL_2", "CATEGORIES_NAME"}
 
 	// (Error) Test Cases 1
-	mock.ExpectQuery("SELECT *").WillReturnRows(sqlmock.NewRows(cols))
+	mock.ExpectQuery("SELECT .* WHERE products.sku = \\$1 GROUP BY").WithArgs("0").WillReturnRows(sqlmock.NewRows(cols))
+	mock.ExpectQuery("SELECT .* WHERE products.sku = \\$1 GROUP BY").WithArgs("1").WillReturnError(errors.New("connection refused"))
 
 	// Test Case 2
-	mock.ExpectQuery("SELECT *").WillReturnRows(sqlmock.NewRows(cols).
+	mock.ExpectQuery("SELECT .* WHERE products.sku = \\$1 GROUP BY").WithArgs("3").WillReturnRows(sqlmock.NewRows(cols).
 		AddRow(s3.ID, s3.Brand, s3.Title, s3.Description, s3.Weight, s3.ProductSize, s3.Colors, s3.Price, s3.Qty, s3.ImageURL[0], s3.ImageURL[1], strings.Join(s3.Categories, ",")))
 
-	s := NewCatalogueService(sqlxDB, logger)
+	s := NewCatalogueService(NewPostgresStore(sqlxDB, logger))
 	{
 		// Error case
-		for _, id := range []string{
-			"0",
+		for _, tc := range []struct {
+			id   string
+			want error
+		}{
+			{"0", ErrNotFound},
+			{"1", ErrDBConnection}, // an outage, not a missing product
 		} {
-			want := ErrNotFound
-			if _, have := s.Get(id); want != have {
-				t.Errorf("Get(%s): want %v, have %v", id, want, have)
+			if _, have := s.Get(ctx, tc.id, "", nil); tc.want != have {
+				t.Errorf("Get(%s): want %v, have %v", tc.id, tc.want, have)
 			}
 		}
 	}
@@ -180,7 +447,7 @@ func TestCatalogueServiceGet(t *testing.T) {
 		for id, want := range map[string]Product{
 			"3": s3,
 		} {
-			have, err := s.Get(id)
+			have, err := s.Get(ctx, id, "", nil)
 			if err != nil {
 				t.Errorf("Get(%s): %v", id, err)
 				continue
@@ -193,7 +460,209 @@ func TestCatalogueServiceGet(t *testing.T) {
 	}
 }
 
+func TestCatalogueServiceBatch(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+
+	cols := []string{"ID", "TITLE", "CATEGORIES_NAME"}
+	mock.ExpectQuery("SELECT .* WHERE products.sku = ANY\\(\\$1\\) GROUP BY").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(s3.ID, s3.Title, s3.CategoryString).AddRow(s1.ID, s1.Title, s1.CategoryString))
+
+	s := NewCatalogueService(NewPostgresStore(sqlxDB, logger))
+	products, missing, err := s.Batch(ctx, []string{"1", "0", "3", "1"}, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := productIDs(products), []string{"1", "3"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("Batch: want %v, have %v", want, have)
+	}
+	if want := []string{"0"}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("Batch missing: want %v, have %v", want, missing)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	ids := make([]string, MaxBatch+1)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+	}
+	if _, _, err := s.Batch(ctx, ids, "", nil); err != ErrBatchTooLarge {
+		t.Errorf("Batch of %d: want %v, have %v", len(ids), ErrBatchTooLarge, err)
+	}
+}
+
+func TestCatalogueServiceCreate(t *testing.T) {
+	ctx := context.Background()
+	logger = log.NewLogfmtLogger(os.Stderr)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+
+	// Test Case 1
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO products").
+		WithArgs(s1.ID, s1.Brand, s1.Title, s1.Description, s1.Weight, s1.ProductSize, s1.Colors, s1.Qty, s1.Price, DefaultCurrency, s1.ImageURL1, s1.ImageURL2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM product_category").WithArgs(s1.ID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT category_id FROM categories").WillReturnRows(sqlmock.NewRows([]string{"category_id"}).AddRow(1).AddRow(3))
+	mock.ExpectExec("INSERT INTO product_category").WithArgs(s1.ID, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO product_category").WithArgs(s1.ID, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM product_variant").WithArgs(s1.ID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO catalogue_outbox").WithArgs(ProductCreated, ProductUpdated, s1.ID).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// (Error) Test Case 2
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO products").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM product_category").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT category_id FROM categories").WillReturnRows(sqlmock.NewRows([]string{"category_id"}).AddRow(1))
+	mock.ExpectRollback()
+
+	s := NewCatalogueService(NewPostgresStore(sqlxDB, logger))
+
+	have, err := s.Create(ctx, Product{ID: s1.ID, Brand: s1.Brand, Title: s1.Title, Description: s1.Description, Weight: s1.Weight, ProductSize: s1.ProductSize, Colors: s1.Colors, Qty: s1.Qty, Price: s1.Price, ImageURL: s1.ImageURL, Categories: []string{"odd", "prime", "odd"}})
+	if err != nil {
+		t.Errorf("Create(%s): returned error %s", s1.ID, err.Error())
+	}
+	want := s1
+	want.CategoryString = "odd, prime"
+	want.Currency = DefaultCurrency
+	want.Version = 1
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("Create(%s): want %v, have %v", s1.ID, want, have)
+	}
+
+	if _, have := s.Create(ctx, Product{ID: s2.ID, Title: s2.Title, Categories: []string{"even", "unknown"}}); have != ErrUnknownCategory {
+		t.Errorf("Create(%s): want %v, have %v", s2.ID, ErrUnknownCategory, have)
+	}
+	for _, p := range []Product{
+		{Title: "no id"},
+		{ID: "6"},
+		{ID: "6", Title: "negative price", Price: -1},
+		{ID: "6", Title: "too many images", ImageURL: []string{"a", "b", "c"}},
+	} {
+		if _, have := s.Create(ctx, p); have != ErrInvalidProduct {
+			t.Errorf("Create(%v): want %v, have %v", p, ErrInvalidProduct, have)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCatalogueServiceUpdate(t *testing.T) {
+	ctx := context.Background()
+	logger = log.NewLogfmtLogger(os.Stderr)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+
+	// Test Case 1
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE products SET .* version = version \\+ 1 WHERE sku = \\$1 AND version = \\$2").
+		WithArgs(s4.ID, 2, s4.Brand, s4.Title, s4.Description, s4.Weight, s4.ProductSize, s4.Colors, s4.Qty, s4.Price, DefaultCurrency, s4.ImageURL1, s4.ImageURL2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM product_category").WithArgs(s4.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT category_id FROM categories").WillReturnRows(sqlmock.NewRows([]string{"category_id"}).AddRow(2))
+	mock.ExpectExec("INSERT INTO product_category").WithArgs(s4.ID, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM product_variant").WithArgs(s4.ID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO catalogue_outbox").WithArgs(ProductCreated, ProductUpdated, s4.ID).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// (Error) Test Case 2
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE products").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(s4.ID).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	// (Error) Test Case 3
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE products").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("0").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+
+	s := NewCatalogueService(NewPostgresStore(sqlxDB, logger))
+
+	update := s4
+	update.Version = 2
+	have, err := s.Update(ctx, update)
+	if err != nil {
+		t.Errorf("Update(%s): returned error %s", s4.ID, err.Error())
+	}
+	if have.Version != 3 {
+		t.Errorf("Update(%s): want version 3, have %d", s4.ID, have.Version)
+	}
+
+	if _, have := s.Update(ctx, update); have != ErrVersionConflict {
+		t.Errorf("Update(%s): want %v, have %v", s4.ID, ErrVersionConflict, have)
+	}
+	missing := update
+	missing.ID = "0"
+	if _, have := s.Update(ctx, missing); have != ErrNotFound {
+		t.Errorf("Update(0): want %v, have %v", ErrNotFound, have)
+	}
+	if _, have := s.Update(ctx, s4); have != ErrVersionRequired {
+		t.Errorf("Update(%s) without version: want %v, have %v", s4.ID, ErrVersionRequired, have)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCatalogueServiceDelete(t *testing.T) {
+	ctx := context.Background()
+	logger = log.NewLogfmtLogger(os.Stderr)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+
+	// Test Case 1
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM product_category").WithArgs(s5.ID).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM products").WithArgs(s5.ID, 4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO catalogue_outbox").WithArgs(ProductDeleted, s5.ID, 4).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// (Error) Test Case 2
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM product_category").WithArgs(s5.ID).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM products").WithArgs(s5.ID, 3).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(s5.ID).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	s := NewCatalogueService(NewPostgresStore(sqlxDB, logger))
+	if err := s.Delete(ctx, s5.ID, 4); err != nil {
+		t.Errorf("Delete(%s, 4): returned error %s", s5.ID, err.Error())
+	}
+	if have := s.Delete(ctx, s5.ID, 3); have != ErrVersionConflict {
+		t.Errorf("Delete(%s, 3): want %v, have %v", s5.ID, ErrVersionConflict, have)
+	}
+	if have := s.Delete(ctx, s5.ID, 0); have != ErrVersionRequired {
+		t.Errorf("Delete(%s, 0): want %v, have %v", s5.ID, ErrVersionRequired, have)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
+
 func TestCatalogueServiceCategories(t *testing.T) {
+	ctx := context.Background()
 	logger = log.NewLogfmtLogger(os.Stderr)
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -209,9 +678,9 @@ func TestCatalogueServiceCategories(t *testing.T) {
 		AddRow(categories[1]).
 		AddRow(categories[2]))
 
-	s := NewCatalogueService(sqlxDB, logger)
+	s := NewCatalogueService(NewPostgresStore(sqlxDB, logger))
 
-	have, err := s.Categories()
+	have, err := s.Categories(ctx)
 	if err != nil {
 		t.Errorf("Categories(): %v", err)
 	}
@@ -220,39 +689,6 @@ func TestCatalogueServiceCategories(t *testing.T) {
 	}
 }
 
-func TestCut(t *testing.T) {
-	for _, testcase := range []struct {
-		pageNum  int
-		pageSize int
-		want     []Product
-	}{
-		{0, 1, []Product{}}, // pageNum 0 is invalid
-		{1, 0, []Product{}}, // pageSize 0 is invalid
-		{1, 1, []Product{s1}},
-		{1, 2, []Product{s1, s2}},
-		{1, 5, []Product{s1, s2, s3, s4, s5}},
-		{1, 9, []Product{s1, s2, s3, s4, s5}},
-		{2, 0, []Product{}},
-		{2, 1, []Product{s2}},
-		{2, 2, []Product{s3, s4}},
-		{2, 3, []Product{s4, s5}},
-		{2, 4, []Product{s5}},
-		{2, 5, []Product{}},
-		{2, 6, []Product{}},
-		{3, 0, []Product{}},
-		{3, 1, []Product{s3}},
-		{3, 2, []Product{s5}},
-		{3, 3, []Product{}},
-		{4, 1, []Product{s4}},
-		{4, 2, []Product{}},
-	} {
-		have := cut(products, testcase.pageNum, testcase.pageSize)
-		if want := testcase.want; !reflect.DeepEqual(want, have) {
-			t.Errorf("cut(%d, %d): want %s, have %s", testcase.pageNum, testcase.pageSize, printIDs(want), printIDs(have))
-		}
-	}
-}
-
 // Make test output nicer: just print product IDs.
 type printIDs []Product
 
@@ -263,3 +699,57 @@ func (s printIDs) String() string {
 	}
 	return "[" + strings.Join(ids, ", ") + "]"
 }
+
+func TestPostgresStoreImport(t *testing.T) {
+	ctx := context.Background()
+	logger = log.NewLogfmtLogger(os.Stderr)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening stub database connection", err)
+	}
+	defer db.Close()
+	store := NewPostgresStore(sqlx.NewDb(db, "sqlmock"), logger)
+
+	// Test Case 1: all products in one transaction.
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO products .* ON CONFLICT \\(sku\\) DO UPDATE SET .* version = products.version \\+ 1").
+		WithArgs(s1.ID, s1.Brand, s1.Title, s1.Description, s1.Weight, s1.ProductSize, s1.Colors, s1.Qty, s1.Price, s1.Currency, s1.ImageURL1, s1.ImageURL2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM product_category").WithArgs(s1.ID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT category_id FROM categories").WillReturnRows(sqlmock.NewRows([]string{"category_id"}).AddRow(1).AddRow(3))
+	mock.ExpectExec("INSERT INTO product_category").WithArgs(s1.ID, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO product_category").WithArgs(s1.ID, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM product_variant").WithArgs(s1.ID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO catalogue_outbox").WithArgs(ProductCreated, ProductUpdated, s1.ID).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO products").WithArgs(s4.ID, s4.Brand, s4.Title, s4.Description, s4.Weight, s4.ProductSize, s4.Colors, s4.Qty, s4.Price, s4.Currency, s4.ImageURL1, s4.ImageURL2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM product_category").WithArgs(s4.ID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT category_id FROM categories").WillReturnRows(sqlmock.NewRows([]string{"category_id"}).AddRow(2))
+	mock.ExpectExec("INSERT INTO product_category").WithArgs(s4.ID, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM product_variant").WithArgs(s4.ID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO catalogue_outbox").WithArgs(ProductCreated, ProductUpdated, s4.ID).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// (Error) Test Case 2: a value too long rolls back the whole import.
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO products").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM product_category").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT category_id FROM categories").WillReturnRows(sqlmock.NewRows([]string{"category_id"}).AddRow(1).AddRow(3))
+	mock.ExpectExec("INSERT INTO product_category").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO product_category").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM product_variant").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO catalogue_outbox").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO products").WillReturnError(&pq.Error{Code: "22001", Message: "value too long for type character varying(20)"})
+	mock.ExpectRollback()
+
+	if err := store.Import(ctx, []Product{s1, s4}); err != nil {
+		t.Errorf("Import: returned error %s", err)
+	}
+	err = store.Import(ctx, []Product{s1, s4})
+	if errs, ok := err.(ImportErrors); !ok || errs[0].ID != s4.ID {
+		t.Errorf("Import too long: want ImportErrors for %s, have %v", s4.ID, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,131 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// store.go contains the definition of the storage the catalogue service keeps
+// its products in. Its implementations are in postgres.go and memory.go.
+
+import (
+	"context"
+	"time"
+)
+
+// Store keeps the products and categories of the catalogue. The service
+// validates what it passes to a store, and a store reports failures with the
+// errors of the service: ErrNotFound, ErrProductExists, ErrUnknownCategory,
+// ErrVariantExists, ErrPromotionExists, ErrVersionConflict,
+// ErrInsufficientStock and ErrReservationExpired, or ErrDBConnection when the
+// storage itself fails. Queries stop when their context is done, failing with
+// the error of the context.
+//
+// Reads take the locales to present products in, most preferred first: the
+// title and description of a product are those of the first locale it has a
+// translation for, and its Locale is set to it. Products read are on sale by
+// the promotion active at the time that lowers their price the most, and
+// tell how much of their stock is available: not held by reservations that
+// have not expired. They are rated by averageRating of their approved
+// reviews.
+//
+// Create, Update, Delete, Import and CommitReservation record the changes
+// they make in an outbox, along with them.
+type Store interface {
+	// List returns up to limit products matching the filter in the given
+	// order, from the one after the position if there is one, and skipping
+	// offset products otherwise.
+	List(ctx context.Context, filter Filter, order Sort, locales []string, after *Position, offset, limit int) ([]Product, error)
+	Count(ctx context.Context, filter Filter) (int, error)
+	Facets(ctx context.Context, filter Filter) (Facets, error)
+	// Search returns the products matching the terms of a query, best
+	// matches first, optionally only those in any of the categories.
+	Search(ctx context.Context, query string, categories []string, locales []string, offset, limit int) ([]Product, error)
+	Get(ctx context.Context, id string, locales []string) (Product, error)
+	// GetMany returns the products of the IDs that exist, in no particular
+	// order.
+	GetMany(ctx context.Context, ids []string, locales []string) ([]Product, error)
+	// Related returns up to limit other products related to a product, most
+	// related first, or ErrNotFound if there is no such product. Products
+	// are ranked by relatedScore, given the co-view signals of the products
+	// viewed with it, and those scoring 0 are left out.
+	Related(ctx context.Context, id string, coViews map[string]float64, locales []string, limit int) ([]Product, error)
+	// Create adds a product, at the version it carries.
+	Create(ctx context.Context, product Product) error
+	// Update replaces the product of the same ID if it is still at the version
+	// the product carries, and increments its version.
+	Update(ctx context.Context, product Product) error
+	// Delete removes a product if it is still at the given version.
+	Delete(ctx context.Context, id string, version int) error
+	// Import writes products all at once, or none of them if any fails. It
+	// replaces the products that exist, incrementing their version, and
+	// creates the others at version 1.
+	Import(ctx context.Context, products []Product) error
+	// Translations returns the translations of a product, by locale.
+	Translations(ctx context.Context, id string) ([]Translation, error)
+	// SetTranslations adds or replaces translations all at once, or none of
+	// them if any is of a product that does not exist.
+	SetTranslations(ctx context.Context, translations []Translation) error
+	DeleteTranslation(ctx context.Context, id, locale string) error
+	// Promotions returns all promotions, expired or not, latest first.
+	Promotions(ctx context.Context) ([]Promotion, error)
+	// CreatePromotion adds a promotion, created at the current time.
+	CreatePromotion(ctx context.Context, promotion Promotion) (Promotion, error)
+	// ExpirePromotion ends a promotion at a time, unless it ended before.
+	// A promotion that has not started yet starts and ends then.
+	ExpirePromotion(ctx context.Context, id string, at time.Time) (Promotion, error)
+	// PriceHistory returns the prices a product had, latest first, including
+	// those of deleted products.
+	PriceHistory(ctx context.Context, id string) ([]PriceChange, error)
+	// Reviews returns up to limit reviews in a status, newest first, from
+	// offset: those of a product if an ID is given, failing with ErrNotFound
+	// if there is no such product, and those of all products otherwise.
+	Reviews(ctx context.Context, id, status string, offset, limit int) ([]Review, error)
+	// CreateReview adds a review, failing with ErrNotFound if there is no
+	// product of its SKU.
+	CreateReview(ctx context.Context, review Review) error
+	// ModerateReview sets the status of a review, moderated at a time.
+	ModerateReview(ctx context.Context, id, status string, at time.Time) (Review, error)
+	// Reserve adds a reservation, unless fewer units of its product are
+	// available when it is created than it holds. Reservations of the same
+	// product are made one at a time.
+	Reserve(ctx context.Context, reservation Reservation) (Reservation, error)
+	// CommitReservation removes a reservation that had not expired at a
+	// time, and takes its units out of the stock of its product, whose
+	// version it increments.
+	CommitReservation(ctx context.Context, id string, at time.Time) (Reservation, error)
+	// ReleaseReservation removes a reservation, and returns it.
+	ReleaseReservation(ctx context.Context, id string) (Reservation, error)
+	// ReleaseExpired removes the reservations expired at a time, and returns
+	// how many.
+	ReleaseExpired(ctx context.Context, at time.Time) (int, error)
+	// PublishChanges passes up to limit unpublished changes, oldest first,
+	// to publish, marks them published unless it fails, and returns how many
+	// it published.
+	PublishChanges(ctx context.Context, limit int, publish func([]Change) error) (int, error)
+	// ReplayChanges marks the changes from an offset on unpublished again,
+	// and returns how many were published.
+	ReplayChanges(ctx context.Context, from int64) (int, error)
+	Categories(ctx context.Context) ([]string, error)
+	// CategoryTree returns the roots of the tree of categories, each with its
+	// children, ordered by position and then by name.
+	CategoryTree(ctx context.Context) ([]Category, error)
+	Health(ctx context.Context) Health
+}
+
+// Sort is an order of products, by one of the keys in sortKeys. Products
+// comparing equal are ordered by ID, in the same direction.
+type Sort struct {
+	Key        string
+	Descending bool
+}
+
+// sortKeys are the keys products can be sorted by.
+var sortKeys = map[string]bool{"id": true, "price": true, "title": true, "brand": true, "qty": true, "rating": true}
+
+// Position is the place of a product in a Sort: its sort key value, which is
+// nil when sorting by ID, and its ID.
+type Position struct {
+	Value interface{}
+	ID    string
+}
@@ -0,0 +1,415 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// suggest.go contains the suggestions completing what customers type in the
+// search box: product titles, brands and category names, matched from an
+// index kept in memory.
+
+import (
+	"context"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxSuggestions is the most suggestions returned at once.
+const maxSuggestions = 20
+
+// suggestMaxAge is the time the suggest index is used for before it is
+// rebuilt, catching up with the writes of other instances of the service.
+// Writes through the service rebuild it right away.
+const suggestMaxAge = time.Minute
+
+// suggestRefreshTimeout is the time given to rebuilding the suggest index in
+// the background.
+const suggestRefreshTimeout = 30 * time.Second
+
+// The kinds of suggestions, in the order they rank in when matching as well.
+const (
+	SuggestCategory = "category"
+	SuggestBrand    = "brand"
+	SuggestProduct  = "product"
+)
+
+// Suggestion is a completion of a query: the title of a product, a brand or
+// the name of a category.
+type Suggestion struct {
+	Text        string `json:"text"`
+	Kind        string `json:"kind"`
+	ID          string `json:"id,omitempty"` // of the product
+	Highlighted string `json:"highlighted"`  // Text escaped for HTML, the parts matching in <em>
+}
+
+// suggestWord is a word of a suggestion: its letters and digits in lower
+// case, and where it is in the text.
+type suggestWord struct {
+	text       string
+	start, end int
+}
+
+type suggestEntry struct {
+	Suggestion
+	rank  int // of its kind
+	words []suggestWord
+}
+
+// suggestIndex finds suggestions by the words they contain.
+type suggestIndex struct {
+	entries    []suggestEntry
+	vocabulary []string         // the words of the entries, sorted
+	postings   map[string][]int // the entries containing each word
+	built      time.Time
+}
+
+// suggestWords splits a text into words of letters and digits.
+func suggestWords(text string) []suggestWord {
+	var words []suggestWord
+	var b strings.Builder
+	start := -1
+	for i, r := range text + " " {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			b.WriteRune(unicode.ToLower(r))
+		} else if start >= 0 {
+			words = append(words, suggestWord{b.String(), start, i})
+			b.Reset()
+			start = -1
+		}
+	}
+	return words
+}
+
+// newSuggestIndex indexes the titles and brands of products, and the names
+// of categories.
+func newSuggestIndex(products []Product, categories []string, built time.Time) *suggestIndex {
+	x := &suggestIndex{postings: make(map[string][]int), built: built}
+	seen := make(map[string]bool)
+	add := func(s Suggestion, rank int) {
+		key := s.Kind + "\x00" + strings.ToLower(s.Text) + "\x00" + s.ID
+		if strings.TrimSpace(s.Text) == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		e := suggestEntry{Suggestion: s, rank: rank, words: suggestWords(s.Text)}
+		for _, w := range e.words {
+			postings := x.postings[w.text]
+			if len(postings) == 0 {
+				x.vocabulary = append(x.vocabulary, w.text)
+			}
+			if len(postings) == 0 || postings[len(postings)-1] != len(x.entries) {
+				x.postings[w.text] = append(postings, len(x.entries))
+			}
+		}
+		x.entries = append(x.entries, e)
+	}
+	for _, c := range categories {
+		add(Suggestion{Text: c, Kind: SuggestCategory}, 0)
+	}
+	for _, p := range products {
+		add(Suggestion{Text: p.Brand, Kind: SuggestBrand}, 1)
+	}
+	for _, p := range products {
+		add(Suggestion{Text: p.Title, Kind: SuggestProduct, ID: p.ID}, 2)
+	}
+	sort.Strings(x.vocabulary)
+	return x
+}
+
+// The scores of a word of a query matching a word of a suggestion.
+const (
+	suggestExact  = 3
+	suggestPrefix = 2
+	suggestFuzzy  = 1
+)
+
+// wordMatch is how a word of a query matches a word of the index: its score,
+// and how many of the runes of the word match.
+type wordMatch struct {
+	score int
+	runes int
+}
+
+// matches returns the words of the index a word of a query matches: those
+// it is a prefix of, and those starting within a few edits of it, one for
+// words of 3 to 5 letters and two for longer ones.
+func (x *suggestIndex) matches(query string) map[string]wordMatch {
+	matches := make(map[string]wordMatch)
+	q := []rune(query)
+	for i := sort.SearchStrings(x.vocabulary, query); i < len(x.vocabulary) && strings.HasPrefix(x.vocabulary[i], query); i++ {
+		if x.vocabulary[i] == query {
+			matches[query] = wordMatch{suggestExact, len(q)}
+		} else {
+			matches[x.vocabulary[i]] = wordMatch{suggestPrefix, len(q)}
+		}
+	}
+
+	edits := 0
+	switch {
+	case len(q) >= 6:
+		edits = 2
+	case len(q) >= 3:
+		edits = 1
+	}
+	if edits == 0 {
+		return matches
+	}
+	for _, word := range x.vocabulary {
+		if _, ok := matches[word]; ok {
+			continue
+		}
+		w := []rune(word)
+		if len(w) < len(q)-edits {
+			continue
+		}
+		// The word starts with the query, give or take the edits: compare
+		// the query with the prefixes of the word of about its length,
+		// closest first.
+		best, runes := edits+1, 0
+		for i := 0; i <= 2*edits; i++ {
+			n := len(q) + (i+1)/2
+			if i%2 == 1 {
+				n = len(q) - (i+1)/2
+			}
+			if n <= 0 || n > len(w) {
+				continue
+			}
+			if d := editDistance(q, w[:n]); d < best {
+				best, runes = d, n
+			}
+		}
+		if best <= edits {
+			matches[word] = wordMatch{suggestFuzzy, runes}
+		}
+	}
+	return matches
+}
+
+// editDistance returns the number of insertions, deletions, substitutions
+// and transpositions of adjacent runes turning a into b.
+func editDistance(a, b []rune) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = d[i-1][j-1] + cost
+			if d[i-1][j]+1 < d[i][j] {
+				d[i][j] = d[i-1][j] + 1
+			}
+			if d[i][j-1]+1 < d[i][j] {
+				d[i][j] = d[i][j-1] + 1
+			}
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && d[i-2][j-2]+1 < d[i][j] {
+				d[i][j] = d[i-2][j-2] + 1
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+// suggest returns up to limit suggestions matching all the words of a query,
+// best first: by how well the words match, then those starting with the
+// first word, those of fewer words, categories, brands and products, and by
+// text.
+func (x *suggestIndex) suggest(query string, limit int) []Suggestion {
+	words := suggestWords(query)
+	if len(words) == 0 {
+		return []Suggestion{}
+	}
+	matches := make([]map[string]wordMatch, len(words))
+	for i, w := range words {
+		matches[i] = x.matches(w.text)
+	}
+
+	type candidate struct {
+		entry      int
+		score      int
+		highlights [][2]int
+	}
+	var candidates []candidate
+	seen := make(map[int]bool)
+	for word := range matches[0] {
+		for _, i := range x.postings[word] {
+			if seen[i] {
+				continue
+			}
+			seen[i] = true
+			c := candidate{entry: i}
+			for q := range words {
+				best, at := wordMatch{}, -1
+				for j, w := range x.entries[i].words {
+					if m, ok := matches[q][w.text]; ok && m.score > best.score {
+						best, at = m, j
+					}
+				}
+				if at < 0 {
+					c.score = 0
+					break
+				}
+				w := x.entries[i].words[at]
+				c.score += 2 * best.score
+				if q == 0 && at == 0 {
+					c.score++
+				}
+				c.highlights = append(c.highlights, [2]int{w.start, w.start + prefixBytes(x.entries[i].Text[w.start:w.end], best.runes)})
+			}
+			if c.score > 0 {
+				candidates = append(candidates, c)
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := x.entries[candidates[i].entry], x.entries[candidates[j].entry]
+		switch {
+		case candidates[i].score != candidates[j].score:
+			return candidates[i].score > candidates[j].score
+		case len(a.words) != len(b.words):
+			return len(a.words) < len(b.words)
+		case a.rank != b.rank:
+			return a.rank < b.rank
+		case a.Text != b.Text:
+			return a.Text < b.Text
+		}
+		return a.ID < b.ID
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	suggestions := make([]Suggestion, len(candidates))
+	for i, c := range candidates {
+		suggestions[i] = x.entries[c.entry].Suggestion
+		suggestions[i].Highlighted = highlight(suggestions[i].Text, c.highlights)
+	}
+	return suggestions
+}
+
+// prefixBytes returns the length in bytes of the first n runes of a word.
+func prefixBytes(word string, n int) int {
+	i := 0
+	for ; n > 0 && i < len(word); n-- {
+		_, size := utf8.DecodeRuneInString(word[i:])
+		i += size
+	}
+	return i
+}
+
+// highlight escapes a text for HTML, wrapping the byte ranges given in <em>.
+func highlight(text string, ranges [][2]int) string {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	var b strings.Builder
+	at := 0
+	for _, r := range ranges {
+		if r[1] <= at {
+			continue
+		}
+		if r[0] < at {
+			r[0] = at
+		}
+		b.WriteString(html.EscapeString(text[at:r[0]]))
+		b.WriteString("<em>" + html.EscapeString(text[r[0]:r[1]]) + "</em>")
+		at = r[1]
+	}
+	b.WriteString(html.EscapeString(text[at:]))
+	return b.String()
+}
+
+// suggester holds the suggest index of a service. It is built on first use,
+// by a single call shared by the requests waiting for it; afterwards, once
+// stale, it is rebuilt in the background while the previous one answers.
+type suggester struct {
+	mtx        sync.Mutex
+	index      *suggestIndex
+	building   *cacheCall // the first build, while in flight
+	stale      bool       // set by writes
+	refreshing bool
+}
+
+// get returns the current index, building it with load if there is none.
+func (s *suggester) get(ctx context.Context, load func(context.Context) (*suggestIndex, error)) (*suggestIndex, error) {
+	s.mtx.Lock()
+	index := s.index
+	if index == nil {
+		if call := s.building; call != nil {
+			s.mtx.Unlock()
+			call.wg.Wait()
+			// The build shared may have failed for the deadline or
+			// cancellation of the request making it, rather than of this one.
+			if isContextError(call.err) && ctx.Err() == nil {
+				return s.get(ctx, load)
+			}
+			if call.err != nil {
+				return nil, call.err
+			}
+			return call.value.(*suggestIndex), nil
+		}
+		call := &cacheCall{}
+		call.wg.Add(1)
+		s.building = call
+		s.mtx.Unlock()
+
+		index, err := load(ctx)
+		call.value, call.err = index, err
+
+		s.mtx.Lock()
+		s.building = nil
+		if err == nil && (s.index == nil || s.index.built.Before(index.built)) {
+			s.index = index
+		}
+		s.mtx.Unlock()
+		call.wg.Done()
+		if err != nil {
+			return nil, err
+		}
+		return index, nil
+	}
+	if (s.stale || time.Since(index.built) > suggestMaxAge) && !s.refreshing {
+		s.stale, s.refreshing = false, true
+		go s.refresh(load)
+	}
+	s.mtx.Unlock()
+	return index, nil
+}
+
+// refresh rebuilds the index, which stays stale if it fails.
+func (s *suggester) refresh(load func(context.Context) (*suggestIndex, error)) {
+	ctx, cancel := context.WithTimeout(context.Background(), suggestRefreshTimeout)
+	defer cancel()
+	index, err := load(ctx)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.refreshing = false
+	if err != nil {
+		s.stale = true
+		return
+	}
+	s.index = index
+}
+
+// invalidate has the index rebuilt on its next use. Writes to the catalogue
+// must call it.
+func (s *suggester) invalidate() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.stale = true
+}
@@ -0,0 +1,169 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+package catalogue
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEditDistance(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"bowl", "bowl", 0},
+		{"bowl", "bwol", 1},
+		{"bowl", "bow", 1},
+		{"bowl", "bowls", 1},
+		{"kitten", "sitting", 3},
+		{"", "toy", 3},
+	} {
+		if have := editDistance([]rune(tc.a), []rune(tc.b)); have != tc.want {
+			t.Errorf("editDistance(%q, %q): want %d, have %d", tc.a, tc.b, tc.want, have)
+		}
+	}
+}
+
+func TestSuggestIndex(t *testing.T) {
+	categories := []string{"Bowls", "Toys", "Food"}
+	products := append(testFixture.Products, Product{ID: "E", Brand: "Bits & Bites", Title: "Bits & Bites treats"})
+	x := newSuggestIndex(products, categories, time.Now())
+
+	for _, tc := range []struct {
+		query string
+		limit int
+		want  []Suggestion
+	}{
+		{"Bow", 10, []Suggestion{
+			{Text: "Bowls", Kind: SuggestCategory, Highlighted: "<em>Bow</em>ls"},
+			{Text: "Steel bowl", Kind: SuggestProduct, ID: "A", Highlighted: "Steel <em>bow</em>l"},
+		}},
+		{"fodo", 2, []Suggestion{
+			{Text: "Food", Kind: SuggestCategory, Highlighted: "<em>Food</em>"},
+			{Text: "Dry food", Kind: SuggestProduct, ID: "C", Highlighted: "Dry <em>food</em>"},
+		}},
+		{"wet f", 10, []Suggestion{
+			{Text: "Wet food", Kind: SuggestProduct, ID: "D", Highlighted: "<em>Wet</em> <em>f</em>ood"},
+		}},
+		{"bits", 10, []Suggestion{
+			{Text: "Bits & Bites", Kind: SuggestBrand, Highlighted: "<em>Bits</em> &amp; Bites"},
+			{Text: "Bits & Bites treats", Kind: SuggestProduct, ID: "E", Highlighted: "<em>Bits</em> &amp; Bites treats"},
+		}},
+		{"acme steel", 10, []Suggestion{}},
+		{"&", 10, []Suggestion{}},
+	} {
+		have := x.suggest(tc.query, tc.limit)
+		if len(have) == 0 && len(tc.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("suggest %q: want %+v, have %+v", tc.query, tc.want, have)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryService(t)
+
+	if have, err := s.Suggest(ctx, " ", 5); err != nil || len(have) != 0 {
+		t.Errorf("Suggest blank: want none, have %v, %v", have, err)
+	}
+	if have, err := s.Suggest(ctx, "chow", 5); err != nil || len(have) != 1 || have[0].Text != "Chow" {
+		t.Errorf("Suggest chow: have %+v, %v", have, err)
+	}
+
+	// The index is rebuilt in the background after a write.
+	if _, err := s.Create(ctx, Product{ID: "E", Brand: "Chowder", Title: "Fish feast"}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		have, err := s.Suggest(ctx, "chow", 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(have) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Suggest chow after Create: have %+v", have)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSuggesterSingleflight(t *testing.T) {
+	var s suggester
+	var loads int64
+	release := make(chan struct{})
+	load := func(ctx context.Context) (*suggestIndex, error) {
+		atomic.AddInt64(&loads, 1)
+		<-release
+		return newSuggestIndex(nil, []string{"brown"}, time.Now()), nil
+	}
+
+	var wg sync.WaitGroup
+	indexes := make([]*suggestIndex, 10)
+	for i := range indexes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			indexes[i], _ = s.get(context.Background(), load)
+		}(i)
+	}
+	// Let the requests pile up on the first build before it returns.
+	for atomic.LoadInt64(&loads) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads != 1 {
+		t.Errorf("10 concurrent requests without an index: want 1 build, have %d", loads)
+	}
+	for i, index := range indexes {
+		if index == nil || index != s.index {
+			t.Errorf("request %d: have index %p, want %p", i, index, s.index)
+		}
+	}
+}
+
+func TestSuggestHTTP(t *testing.T) {
+	h := newTestHandler(newTestMemoryService(t))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/catalogue/suggest?q=toy&size=2", nil))
+	var suggestions []Suggestion
+	if err := json.NewDecoder(rec.Body).Decode(&suggestions); err != nil {
+		t.Fatal(err)
+	}
+	want := []Suggestion{
+		{Text: "Mouse toy", Kind: SuggestProduct, ID: "B", Highlighted: "Mouse <em>toy</em>"},
+		{Text: "Toys", Kind: SuggestCategory, Highlighted: "<em>Toy</em>s"},
+	}
+	if rec.Code != http.StatusOK || !reflect.DeepEqual(suggestions, want) {
+		t.Errorf("GET /catalogue/suggest: want %+v, have %d %+v", want, rec.Code, suggestions)
+	}
+
+	// A search box being cleared asks for nothing.
+	for i := 0; i < 10; i++ {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/catalogue/suggest?q=+", nil))
+		if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+			t.Fatalf("GET /catalogue/suggest blank: want %d [], have %d %s", http.StatusOK, rec.Code, rec.Body)
+		}
+	}
+}
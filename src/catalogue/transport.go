@@ -10,12 +10,13 @@ package catalogue
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/go-kit/kit/circuitbreaker"
+	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/tracing/opentracing"
 	httptransport "github.com/go-kit/kit/transport/http"
@@ -26,65 +27,256 @@ import (
 	"golang.org/x/net/context"
 )
 
-// MakeHTTPHandler mounts the endpoints into a REST-y HTTP handler.
-func MakeHTTPHandler(e Endpoints, imagePath string, logger log.Logger, tracer stdopentracing.Tracer) *mux.Router {
+// MakeHTTPHandler mounts the endpoints into a REST-y HTTP handler, and the
+// images handler under /catalogue/images/.
+func MakeHTTPHandler(e Endpoints, images http.Handler, logger log.Logger, tracer stdopentracing.Tracer) *mux.Router {
 	r := mux.NewRouter().StrictSlash(false)
 	options := []httptransport.ServerOption{
 		httptransport.ServerErrorLogger(logger),
 		httptransport.ServerErrorEncoder(encodeError),
+		httptransport.ServerBefore(ifNoneMatchToContext),
 	}
 
 	// GET /catalogue       List
 	// GET /catalogue/size  Count
+	// GET /catalogue/facets  Facets
+	// GET /catalogue/search  Search
+	// GET /catalogue/suggest  Suggest
 	// GET /catalogue/{id}  Get
+	// GET /catalogue/{id}/related  Related
+	// GET /admin/catalogue/export  Export
+	// POST /catalogue      Create
+	// POST /catalogue/import  Import
+	// POST /catalogue/batch  Batch
+	// PUT /catalogue/{id}  Update
+	// DELETE /catalogue/{id}  Delete
+	// GET /catalogue/{id}/translations  Translations
+	// DELETE /catalogue/{id}/translations/{locale}  DeleteTranslation
+	// POST /translations    SetTranslations
 	// GET /categories            Categories
+	// GET /categories/tree  CategoryTree
+	// GET /rates            Rates
+	// PUT /rates            SetRates
+	// PUT /coviews          SetCoViews
+	// GET /promotions       Promotions
+	// POST /promotions      CreatePromotion
+	// POST /promotions/{id}/expire  ExpirePromotion
+	// GET /admin/catalogue/{id}/prices  PriceHistory
+	// GET /catalogue/{id}/reviews  Reviews
+	// POST /catalogue/{id}/reviews  CreateReview
+	// GET /admin/reviews    ListReviews
+	// POST /reviews/{id}/approve  ModerateReview
+	// POST /reviews/{id}/reject  ModerateReview
+	// POST /reservations    Reserve
+	// POST /reservations/{id}/commit  CommitReservation
+	// DELETE /reservations/{id}  ReleaseReservation
+	// POST /changes/replay  ReplayChanges
 	// GET /health		Health Check
 
 	r.Methods("GET").Path("/catalogue").Handler(httptransport.NewServer(
-		circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
-			Name:    "List",
-			Timeout: 30 * time.Second,
-		}))(e.ListEndpoint),
+		breaker("List")(e.ListEndpoint),
 		decodeListRequest,
 		encodeListResponse,
 		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "GET /catalogue", logger)))...,
 	))
 	r.Methods("GET").Path("/catalogue/size").Handler(httptransport.NewServer(
-		circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
-			Name:    "Count",
-			Timeout: 30 * time.Second,
-		}))(e.CountEndpoint),
+		breaker("Count")(e.CountEndpoint),
 		decodeCountRequest,
 		encodeResponse,
 		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "GET /catalogue/size", logger)))...,
 	))
+	r.Methods("GET").Path("/catalogue/facets").Handler(httptransport.NewServer(
+		breaker("Facets")(e.FacetsEndpoint),
+		decodeFacetsRequest,
+		encodeFacetsResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "GET /catalogue/facets", logger)))...,
+	))
+	r.Methods("GET").Path("/catalogue/search").Handler(httptransport.NewServer(
+		breaker("Search")(e.SearchEndpoint),
+		decodeSearchRequest,
+		encodeSearchResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "GET /catalogue/search", logger)))...,
+	))
+	r.Methods("GET").Path("/catalogue/suggest").Handler(httptransport.NewServer(
+		breaker("Suggest")(e.SuggestEndpoint),
+		decodeSuggestRequest,
+		encodeSuggestResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "GET /catalogue/suggest", logger)))...,
+	))
+	r.Methods("GET").Path("/admin/catalogue/export").Handler(httptransport.NewServer(
+		breaker("Export")(e.ExportEndpoint),
+		decodeExportRequest,
+		encodeExportResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "GET /admin/catalogue/export", logger)))...,
+	))
 	r.Methods("GET").Path("/catalogue/{id}").Handler(httptransport.NewServer(
-		circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
-			Name:    "Get",
-			Timeout: 30 * time.Second,
-		}))(e.GetEndpoint),
+		breaker("Get")(e.GetEndpoint),
 		decodeGetRequest,
 		encodeGetResponse, // special case, this one can have an error
 		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "GET /catalogue/{id}", logger)))...,
 	))
+	r.Methods("GET").Path("/catalogue/{id}/related").Handler(httptransport.NewServer(
+		breaker("Related")(e.RelatedEndpoint),
+		decodeRelatedRequest,
+		encodeRelatedResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "GET /catalogue/{id}/related", logger)))...,
+	))
+	r.Methods("POST").Path("/catalogue").Handler(httptransport.NewServer(
+		breaker("Create")(e.CreateEndpoint),
+		decodeCreateRequest,
+		encodeCreateResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "POST /catalogue", logger)))...,
+	))
+	r.Methods("POST").Path("/catalogue/import").Handler(httptransport.NewServer(
+		breaker("Import")(e.ImportEndpoint),
+		decodeImportRequest,
+		encodeImportResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "POST /catalogue/import", logger)))...,
+	))
+	r.Methods("POST").Path("/catalogue/batch").Handler(httptransport.NewServer(
+		breaker("Batch")(e.BatchEndpoint),
+		decodeBatchRequest,
+		encodeBatchResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "POST /catalogue/batch", logger)))...,
+	))
+	r.Methods("PUT").Path("/catalogue/{id}").Handler(httptransport.NewServer(
+		breaker("Update")(e.UpdateEndpoint),
+		decodeUpdateRequest,
+		encodeUpdateResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "PUT /catalogue/{id}", logger)))...,
+	))
+	r.Methods("DELETE").Path("/catalogue/{id}").Handler(httptransport.NewServer(
+		breaker("Delete")(e.DeleteEndpoint),
+		decodeDeleteRequest,
+		encodeDeleteResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "DELETE /catalogue/{id}", logger)))...,
+	))
+	r.Methods("GET").Path("/catalogue/{id}/translations").Handler(httptransport.NewServer(
+		breaker("Translations")(e.TranslationsEndpoint),
+		decodeTranslationsRequest,
+		encodeTranslationsResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "GET /catalogue/{id}/translations", logger)))...,
+	))
+	r.Methods("DELETE").Path("/catalogue/{id}/translations/{locale}").Handler(httptransport.NewServer(
+		breaker("DeleteTranslation")(e.DeleteTranslationEndpoint),
+		decodeDeleteTranslationRequest,
+		encodeDeleteResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "DELETE /catalogue/{id}/translations/{locale}", logger)))...,
+	))
+	r.Methods("POST").Path("/translations").Handler(httptransport.NewServer(
+		breaker("SetTranslations")(e.SetTranslationsEndpoint),
+		decodeSetTranslationsRequest,
+		encodeTranslationsResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "POST /translations", logger)))...,
+	))
 	r.Methods("GET").Path("/categories").Handler(httptransport.NewServer(
-		circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
-			Name:    "Categories",
-			Timeout: 30 * time.Second,
-		}))(e.CategoriesEndpoint),
+		breaker("Categories")(e.CategoriesEndpoint),
 		decodeCategoriesRequest,
-		encodeResponse,
+		encodeCategoriesResponse,
 		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "GET /categories", logger)))...,
 	))
+	r.Methods("GET").Path("/categories/tree").Handler(httptransport.NewServer(
+		breaker("CategoryTree")(e.CategoryTreeEndpoint),
+		decodeCategoriesRequest,
+		encodeCategoryTreeResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "GET /categories/tree", logger)))...,
+	))
+	r.Methods("GET").Path("/rates").Handler(httptransport.NewServer(
+		breaker("Rates")(e.RatesEndpoint),
+		decodeRatesRequest,
+		encodeRatesResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "GET /rates", logger)))...,
+	))
+	r.Methods("PUT").Path("/rates").Handler(httptransport.NewServer(
+		breaker("SetRates")(e.SetRatesEndpoint),
+		decodeSetRatesRequest,
+		encodeRatesResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "PUT /rates", logger)))...,
+	))
+	r.Methods("PUT").Path("/coviews").Handler(httptransport.NewServer(
+		breaker("SetCoViews")(e.SetCoViewsEndpoint),
+		decodeSetCoViewsRequest,
+		encodeDeleteResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "PUT /coviews", logger)))...,
+	))
+	r.Methods("GET").Path("/promotions").Handler(httptransport.NewServer(
+		breaker("Promotions")(e.PromotionsEndpoint),
+		decodePromotionsRequest,
+		encodePromotionsResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "GET /promotions", logger)))...,
+	))
+	r.Methods("POST").Path("/promotions").Handler(httptransport.NewServer(
+		breaker("CreatePromotion")(e.CreatePromotionEndpoint),
+		decodeCreatePromotionRequest,
+		encodeCreatePromotionResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "POST /promotions", logger)))...,
+	))
+	r.Methods("POST").Path("/promotions/{id}/expire").Handler(httptransport.NewServer(
+		breaker("ExpirePromotion")(e.ExpirePromotionEndpoint),
+		decodeExpirePromotionRequest,
+		encodePromotionResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "POST /promotions/{id}/expire", logger)))...,
+	))
+	r.Methods("GET").Path("/admin/catalogue/{id}/prices").Handler(httptransport.NewServer(
+		breaker("PriceHistory")(e.PriceHistoryEndpoint),
+		decodePriceHistoryRequest,
+		encodePriceHistoryResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "GET /admin/catalogue/{id}/prices", logger)))...,
+	))
+	r.Methods("GET").Path("/catalogue/{id}/reviews").Handler(httptransport.NewServer(
+		breaker("Reviews")(e.ReviewsEndpoint),
+		decodeReviewsRequest,
+		encodeReviewsResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "GET /catalogue/{id}/reviews", logger)))...,
+	))
+	r.Methods("POST").Path("/catalogue/{id}/reviews").Handler(httptransport.NewServer(
+		breaker("CreateReview")(e.CreateReviewEndpoint),
+		decodeCreateReviewRequest,
+		encodeCreateReviewResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "POST /catalogue/{id}/reviews", logger)))...,
+	))
+	r.Methods("GET").Path("/admin/reviews").Handler(httptransport.NewServer(
+		breaker("ListReviews")(e.ListReviewsEndpoint),
+		decodeListReviewsRequest,
+		encodeReviewsResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "GET /admin/reviews", logger)))...,
+	))
+	r.Methods("POST").Path("/reviews/{id}/{action:approve|reject}").Handler(httptransport.NewServer(
+		breaker("ModerateReview")(e.ModerateReviewEndpoint),
+		decodeModerateReviewRequest,
+		encodeReviewResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "POST /reviews/{id}/{action}", logger)))...,
+	))
+	r.Methods("POST").Path("/reservations").Handler(httptransport.NewServer(
+		breaker("Reserve")(e.ReserveEndpoint),
+		decodeReserveRequest,
+		encodeReserveResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "POST /reservations", logger)))...,
+	))
+	r.Methods("POST").Path("/reservations/{id}/commit").Handler(httptransport.NewServer(
+		breaker("CommitReservation")(e.CommitReservationEndpoint),
+		decodeReservationRequest,
+		encodeReservationResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "POST /reservations/{id}/commit", logger)))...,
+	))
+	r.Methods("DELETE").Path("/reservations/{id}").Handler(httptransport.NewServer(
+		breaker("ReleaseReservation")(e.ReleaseReservationEndpoint),
+		decodeReservationRequest,
+		encodeDeleteResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "DELETE /reservations/{id}", logger)))...,
+	))
+	r.Methods("POST").Path("/changes/replay").Handler(httptransport.NewServer(
+		breaker("ReplayChanges")(e.ReplayChangesEndpoint),
+		decodeReplayChangesRequest,
+		encodeResponse,
+		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "POST /changes/replay", logger)))...,
+	))
 	r.Methods("GET").PathPrefix("/catalogue/images/").Handler(http.StripPrefix(
 		"/catalogue/images/",
-		http.FileServer(http.Dir(imagePath)),
+		images,
 	))
 	r.Methods("GET").PathPrefix("/health").Handler(httptransport.NewServer(
-		circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
-			Name:    "Health",
-			Timeout: 30 * time.Second,
-		}))(e.HealthEndpoint),
+		breaker("Health")(e.HealthEndpoint),
 		decodeHealthRequest,
 		encodeHealthResponse,
 		append(options, httptransport.ServerBefore(opentracing.HTTPToContext(tracer, "GET /health", logger)))...,
@@ -93,19 +285,76 @@ func MakeHTTPHandler(e Endpoints, imagePath string, logger log.Logger, tracer st
 	return r
 }
 
-func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+// breaker returns the circuit breaker of an endpoint. Errors of the client,
+// which encodeError replies to with a 4xx status, do not count as failures:
+// a sold-out product or a bad query is not an outage.
+func breaker(name string) endpoint.Middleware {
+	cb := gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:    name,
+		Timeout: 30 * time.Second,
+	})
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			var clientErr error
+			response, err := cb.Execute(func() (interface{}, error) {
+				response, err := next(ctx, request)
+				if err != nil && errorStatus(err) < http.StatusInternalServerError {
+					clientErr = err
+					return response, nil
+				}
+				return response, err
+			})
+			if clientErr != nil {
+				return response, clientErr
+			}
+			return response, err
+		}
+	}
+}
+
+// errorStatus returns the HTTP status an error is replied with.
+func errorStatus(err error) int {
 	code := http.StatusInternalServerError
 	switch err {
 	case ErrNotFound:
 		code = http.StatusNotFound
+	case ErrEmptyQuery, ErrBatchTooLarge, ErrInvalidSort, ErrInvalidCursor, ErrInvalidProduct, ErrUnknownCategory, ErrUnknownCurrency, ErrInvalidTranslation, ErrInvalidPromotion, ErrInvalidReservation, ErrInvalidReview, errBadRequest:
+		code = http.StatusBadRequest
+	case ErrProductExists, ErrVariantExists, ErrVersionConflict, ErrPromotionExists, ErrInsufficientStock:
+		code = http.StatusConflict
+	case ErrReservationExpired:
+		code = http.StatusGone
+	case ErrVersionRequired:
+		code = http.StatusPreconditionRequired
 	}
-	w.WriteHeader(code)
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	if errors.Is(err, ErrInvalidRates) || errors.Is(err, ErrInvalidCoViews) {
+		code = http.StatusBadRequest // wrapped, telling which rate or signal
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		code = http.StatusGatewayTimeout // the query ran past its deadline
+	}
+	var rows ImportErrors
+	if errors.As(err, &rows) {
+		code = http.StatusBadRequest
+	}
+	return code
+}
+
+func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+	code := errorStatus(err)
+	body := map[string]interface{}{
 		"error":       err.Error(),
 		"status_code": code,
 		"status_text": http.StatusText(code),
-	})
+	}
+	// A rejected import tells what is wrong with every row.
+	var rows ImportErrors
+	if errors.As(err, &rows) {
+		body["errors"] = rows
+	}
+	w.WriteHeader(code)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(body)
 }
 
 func decodeListRequest(_ context.Context, r *http.Request) (interface{}, error) {
@@ -121,39 +370,183 @@ func decodeListRequest(_ context.Context, r *http.Request) (interface{}, error)
 	if sort := r.FormValue("sort"); sort != "" {
 		order = strings.ToLower(sort)
 	}
-	categories := []string{}
-	if categoriesval := r.FormValue("categories"); categoriesval != "" {
-		categories = strings.Split(categoriesval, ",")
+	filter, err := decodeFilter(r)
+	if err != nil {
+		return nil, err
 	}
+	envelope, _ := strconv.ParseBool(r.FormValue("envelope"))
 	return listRequest{
-		Categories: categories,
-		Order:      order,
-		PageNum:    pageNum,
-		PageSize:   pageSize,
+		Filter:    filter,
+		Order:     order,
+		Cursor:    r.FormValue("cursor"),
+		Currency:  strings.ToUpper(r.FormValue("currency")),
+		Languages: decodeLanguages(r),
+		PageNum:   pageNum,
+		PageSize:  pageSize,
+		Envelope:  envelope,
 	}, nil
 }
 
+// decodeLanguages reads the languages products are to be presented in, most
+// preferred first: those of the lang query parameter, comma separated, or
+// else those of the Accept-Language header.
+func decodeLanguages(r *http.Request) []string {
+	if lang := r.FormValue("lang"); lang != "" {
+		return strings.Split(lang, ",")
+	}
+	return parseAcceptLanguage(r.Header.Get("Accept-Language"))
+}
+
+// setContentLanguage tells the locales products are presented in, which
+// depend on the Accept-Language header of the request.
+func setContentLanguage(w http.ResponseWriter, products ...Product) {
+	w.Header().Set("Content-Language", contentLanguage(products...))
+	w.Header().Add("Vary", "Accept-Language")
+}
+
+// decodeFilter reads the product filter shared by list, count and facets
+// requests. List valued parameters are comma separated, and match=all asks for
+// products in all of the categories rather than any. The size filter goes by
+// product_size, as size already is the page size.
+func decodeFilter(r *http.Request) (Filter, error) {
+	filter := Filter{
+		Categories: []string{},
+		Currency:   strings.ToUpper(r.FormValue("currency")),
+	}
+	if categoriesval := r.FormValue("categories"); categoriesval != "" {
+		filter.Categories = strings.Split(categoriesval, ",")
+	}
+	switch r.FormValue("match") {
+	case "", "any":
+	case "all":
+		filter.MatchAll = true
+	default:
+		return Filter{}, errBadRequest
+	}
+	if brand := r.FormValue("brand"); brand != "" {
+		filter.Brands = strings.Split(brand, ",")
+	}
+	if color := r.FormValue("color"); color != "" {
+		filter.Colors = strings.Split(color, ",")
+	}
+	if size := r.FormValue("product_size"); size != "" {
+		filter.Sizes = strings.Split(size, ",")
+	}
+	for param, bound := range map[string]**float64{
+		"minPrice": &filter.MinPrice,
+		"maxPrice": &filter.MaxPrice,
+	} {
+		if v := r.FormValue(param); v != "" {
+			price, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return Filter{}, errBadRequest
+			}
+			*bound = &price
+		}
+	}
+	return filter, nil
+}
+
 // encodeListResponse is distinct from the generic encodeResponse because our
 // clients expect that we will encode the slice (array) of products directly,
-// without the wrapping response object.
+// without the wrapping response object. The cursor of the next page travels
+// in a header, or, for clients opting in, in an envelope around the products.
 func encodeListResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
 	resp := response.(listResponse)
-	return encodeResponse(ctx, w, resp.Products)
+	setContentLanguage(w, resp.Products...)
+	if resp.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", resp.NextCursor)
+	}
+	if resp.Envelope {
+		return encodeCacheableResponse(ctx, w, "", listEnvelope{
+			Products:   resp.Products,
+			NextCursor: resp.NextCursor,
+		})
+	}
+	return encodeCacheableResponse(ctx, w, "", resp.Products)
+}
+
+type listEnvelope struct {
+	Products   []Product `json:"products"`
+	NextCursor string    `json:"next_cursor,omitempty"`
 }
 
 func decodeCountRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	filter, err := decodeFilter(r)
+	if err != nil {
+		return nil, err
+	}
+	return countRequest{
+		Filter: filter,
+	}, nil
+}
+
+func decodeFacetsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	filter, err := decodeFilter(r)
+	if err != nil {
+		return nil, err
+	}
+	return facetsRequest{
+		Filter: filter,
+	}, nil
+}
+
+func encodeFacetsResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	return encodeResponse(ctx, w, response.(facetsResponse).Facets)
+}
+
+func decodeSearchRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	pageNum := 1
+	if page := r.FormValue("page"); page != "" {
+		pageNum, _ = strconv.Atoi(page)
+	}
+	pageSize := 10
+	if size := r.FormValue("size"); size != "" {
+		pageSize, _ = strconv.Atoi(size)
+	}
 	categories := []string{}
 	if categoriesval := r.FormValue("categories"); categoriesval != "" {
 		categories = strings.Split(categoriesval, ",")
 	}
-	return countRequest{
+	return searchRequest{
+		Query:      r.FormValue("q"),
 		Categories: categories,
+		Languages:  decodeLanguages(r),
+		PageNum:    pageNum,
+		PageSize:   pageSize,
+	}, nil
+}
+
+// encodeSearchResponse, like encodeListResponse, encodes the ranked slice of
+// products directly.
+func encodeSearchResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(searchResponse)
+	setContentLanguage(w, resp.Products...)
+	return encodeResponse(ctx, w, resp.Products)
+}
+
+func decodeSuggestRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	size := 5
+	if s := r.FormValue("size"); s != "" {
+		size, _ = strconv.Atoi(s)
+	}
+	return suggestRequest{
+		Query: r.FormValue("q"),
+		Size:  size,
 	}, nil
 }
 
+// encodeSuggestResponse, like encodeSearchResponse, encodes the ranked slice
+// of suggestions directly.
+func encodeSuggestResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	return encodeResponse(ctx, w, response.(suggestResponse).Suggestions)
+}
+
 func decodeGetRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	return getRequest{
-		ID: mux.Vars(r)["id"],
+		ID:        mux.Vars(r)["id"],
+		Currency:  strings.ToUpper(r.FormValue("currency")),
+		Languages: decodeLanguages(r),
 	}, nil
 }
 
@@ -165,13 +558,427 @@ func encodeGetResponse(ctx context.Context, w http.ResponseWriter, response inte
 		encodeError(ctx, resp.Err, w)
 		return nil
 	}
-	return encodeResponse(ctx, w, resp.Product)
+	setContentLanguage(w, resp.Product)
+	return encodeCacheableResponse(ctx, w, productETagPrefix(resp.Product), resp.Product)
+}
+
+// decodeBatchRequest reads the IDs of the products from a JSON object such
+// as {"ids": ["MU-US-001", "MU-US-002"]}. Prices and languages are taken
+// from the query and headers as for single products.
+func decodeBatchRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var body struct {
+		IDs []string `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, errBadRequest
+	}
+	return batchRequest{
+		IDs:       body.IDs,
+		Currency:  strings.ToUpper(r.FormValue("currency")),
+		Languages: decodeLanguages(r),
+	}, nil
+}
+
+func encodeBatchResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(batchResponse)
+	setContentLanguage(w, resp.Products...)
+	return encodeResponse(ctx, w, resp)
+}
+
+// decodeRelatedRequest reads the number of related products from the size
+// query parameter, 4 by default.
+func decodeRelatedRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	size := 4
+	if v := r.FormValue("size"); v != "" {
+		var err error
+		if size, err = strconv.Atoi(v); err != nil {
+			return nil, errBadRequest
+		}
+	}
+	return relatedRequest{
+		ID:        mux.Vars(r)["id"],
+		Currency:  strings.ToUpper(r.FormValue("currency")),
+		Languages: decodeLanguages(r),
+		Size:      size,
+	}, nil
+}
+
+// encodeRelatedResponse, like encodeListResponse, encodes the ranked slice of
+// products directly.
+func encodeRelatedResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(relatedResponse)
+	setContentLanguage(w, resp.Products...)
+	return encodeCacheableResponse(ctx, w, "", resp.Products)
+}
+
+// errBadRequest is returned by decoders when the request cannot be read.
+var errBadRequest = errors.New("bad request")
+
+func decodeCreateRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var product Product
+	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+		return nil, errBadRequest
+	}
+	return createRequest{Product: product}, nil
+}
+
+// decodeUpdateRequest takes the product ID from the path. The version the
+// update is based on comes from the If-Match header and, failing that, from
+// the version field of the body.
+func decodeUpdateRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var product Product
+	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+		return nil, errBadRequest
+	}
+	product.ID = mux.Vars(r)["id"]
+	if v, ok, err := ifMatchVersion(r); err != nil {
+		return nil, err
+	} else if ok {
+		product.Version = v
+	}
+	return updateRequest{Product: product}, nil
+}
+
+// decodeDeleteRequest takes the version from the If-Match header or, failing
+// that, from the version query parameter.
+func decodeDeleteRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	version, ok, err := ifMatchVersion(r)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if v := r.FormValue("version"); v != "" {
+			if version, err = strconv.Atoi(v); err != nil {
+				return nil, errBadRequest
+			}
+		}
+	}
+	return deleteRequest{
+		ID:      mux.Vars(r)["id"],
+		Version: version,
+	}, nil
+}
+
+// ifMatchVersion reads the product version from an If-Match header carrying
+// a product ETag, which starts with the version. A bare version is accepted
+// too.
+func ifMatchVersion(r *http.Request) (int, bool, error) {
+	match := r.Header.Get("If-Match")
+	if match == "" {
+		return 0, false, nil
+	}
+	tag := strings.Trim(strings.TrimPrefix(strings.TrimSpace(match), "W/"), `"`)
+	if i := strings.Index(tag, "-"); i >= 0 {
+		tag = tag[:i]
+	}
+	version, err := strconv.Atoi(tag)
+	if err != nil {
+		return 0, false, errBadRequest
+	}
+	return version, true, nil
+}
+
+func encodeCreateResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(createResponse)
+	body, etag, err := marshalTagged(productETagPrefix(resp.Product), resp.Product)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", "/catalogue/"+resp.Product.ID)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(body)
+	return err
+}
+
+func encodeUpdateResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(updateResponse)
+	body, etag, err := marshalTagged(productETagPrefix(resp.Product), resp.Product)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, err = w.Write(body)
+	return err
+}
+
+func encodeDeleteResponse(_ context.Context, w http.ResponseWriter, _ interface{}) error {
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// maxImportSize is the largest product file an import takes.
+const maxImportSize = 32 << 20
+
+// decodeImportRequest reads the products of a product file, in the format of
+// the format query parameter or else of the Content-Type: CSV for text/csv,
+// JSON otherwise.
+func decodeImportRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = FormatJSON
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+			format = FormatCSV
+		}
+	}
+	if format != FormatCSV && format != FormatJSON {
+		return nil, errBadRequest
+	}
+	// A dry run misread as a real one would change the catalogue.
+	var dryRun bool
+	if v := r.URL.Query().Get("dryRun"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			return nil, errBadRequest
+		}
+	}
+	products, err := ReadProducts(http.MaxBytesReader(nil, r.Body, maxImportSize), format)
+	if err != nil {
+		return nil, err
+	}
+	return importRequest{Products: products, DryRun: dryRun}, nil
+}
+
+func encodeImportResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	return encodeResponse(ctx, w, response.(importResponse).Report)
+}
+
+// decodeExportRequest takes the format from the format query parameter or,
+// failing that, from the Accept header.
+func decodeExportRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = FormatJSON
+		if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+			format = FormatCSV
+		}
+	}
+	if format != FormatCSV && format != FormatJSON {
+		return nil, errBadRequest
+	}
+	return exportRequest{Format: format}, nil
+}
+
+func encodeExportResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(exportResponse)
+	contentType := "application/json; charset=utf-8"
+	if resp.Format == FormatCSV {
+		contentType = "text/csv; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="catalogue.`+resp.Format+`"`)
+	return WriteProducts(w, resp.Format, resp.Products)
+}
+
+func decodeTranslationsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return translationsRequest{ID: mux.Vars(r)["id"]}, nil
+}
+
+// decodeSetTranslationsRequest reads a JSON array of translations, of any
+// products.
+func decodeSetTranslationsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var translations []Translation
+	if err := json.NewDecoder(r.Body).Decode(&translations); err != nil {
+		return nil, errBadRequest
+	}
+	return setTranslationsRequest{Translations: translations}, nil
+}
+
+func encodeTranslationsResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	return encodeResponse(ctx, w, response.(translationsResponse).Translations)
+}
+
+func decodeDeleteTranslationRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return deleteTranslationRequest{
+		ID:     mux.Vars(r)["id"],
+		Locale: mux.Vars(r)["locale"],
+	}, nil
 }
 
 func decodeCategoriesRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	return struct{}{}, nil
 }
 
+func encodeCategoriesResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	return encodeCacheableResponse(ctx, w, "", response.(categoriesResponse))
+}
+
+func encodeCategoryTreeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	return encodeCacheableResponse(ctx, w, "", response.(categoryTreeResponse))
+}
+
+func decodeRatesRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return struct{}{}, nil
+}
+
+func decodeSetRatesRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var rates Rates
+	d := json.NewDecoder(r.Body)
+	d.UseNumber()
+	if err := d.Decode(&rates); err != nil {
+		return nil, errBadRequest
+	}
+	return setRatesRequest{Rates: rates}, nil
+}
+
+func encodeRatesResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	return encodeResponse(ctx, w, response.(ratesResponse).Rates)
+}
+
+func decodeSetCoViewsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var coViews CoViews
+	if err := json.NewDecoder(r.Body).Decode(&coViews); err != nil {
+		return nil, errBadRequest
+	}
+	return setCoViewsRequest{CoViews: coViews}, nil
+}
+
+func decodePromotionsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return struct{}{}, nil
+}
+
+func encodePromotionsResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	return encodeResponse(ctx, w, response.(promotionsResponse).Promotions)
+}
+
+func decodeCreatePromotionRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var promotion Promotion
+	if err := json.NewDecoder(r.Body).Decode(&promotion); err != nil {
+		return nil, errBadRequest
+	}
+	return createPromotionRequest{Promotion: promotion}, nil
+}
+
+func encodeCreatePromotionResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(response.(promotionResponse).Promotion)
+}
+
+func decodeExpirePromotionRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return expirePromotionRequest{ID: mux.Vars(r)["id"]}, nil
+}
+
+func encodePromotionResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	return encodeResponse(ctx, w, response.(promotionResponse).Promotion)
+}
+
+func decodePriceHistoryRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return priceHistoryRequest{ID: mux.Vars(r)["id"]}, nil
+}
+
+func encodePriceHistoryResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	return encodeResponse(ctx, w, response.(priceHistoryResponse).Prices)
+}
+
+// decodePage reads the page and size query parameters, for a page of size
+// items by default.
+func decodePage(r *http.Request, size int) (pageNum, pageSize int) {
+	pageNum = 1
+	if page := r.FormValue("page"); page != "" {
+		pageNum, _ = strconv.Atoi(page)
+	}
+	pageSize = size
+	if size := r.FormValue("size"); size != "" {
+		pageSize, _ = strconv.Atoi(size)
+	}
+	return pageNum, pageSize
+}
+
+func decodeReviewsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	pageNum, pageSize := decodePage(r, 10)
+	return reviewsRequest{
+		ID:       mux.Vars(r)["id"],
+		PageNum:  pageNum,
+		PageSize: pageSize,
+	}, nil
+}
+
+// encodeReviewsResponse encodes the slice of reviews directly.
+func encodeReviewsResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	return encodeResponse(ctx, w, response.(reviewsResponse).Reviews)
+}
+
+// decodeCreateReviewRequest reads a review of the product of the path.
+func decodeCreateReviewRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var review Review
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		return nil, errBadRequest
+	}
+	review.SKU = mux.Vars(r)["id"]
+	return createReviewRequest{Review: review}, nil
+}
+
+func encodeCreateReviewResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(response.(reviewResponse).Review)
+}
+
+// decodeListReviewsRequest reads the status of the reviews to list, pending
+// by default.
+func decodeListReviewsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	status := r.FormValue("status")
+	if status == "" {
+		status = ReviewPending
+	}
+	pageNum, pageSize := decodePage(r, 10)
+	return listReviewsRequest{
+		Status:   status,
+		PageNum:  pageNum,
+		PageSize: pageSize,
+	}, nil
+}
+
+// decodeModerateReviewRequest reads the status to moderate a review to from
+// the action of the path: approve or reject.
+func decodeModerateReviewRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	status := ReviewApproved
+	if vars["action"] == "reject" {
+		status = ReviewRejected
+	}
+	return moderateReviewRequest{ID: vars["id"], Status: status}, nil
+}
+
+func encodeReviewResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	return encodeResponse(ctx, w, response.(reviewResponse).Review)
+}
+
+func decodeReserveRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var reservation Reservation
+	if err := json.NewDecoder(r.Body).Decode(&reservation); err != nil {
+		return nil, errBadRequest
+	}
+	return reserveRequest{Reservation: reservation}, nil
+}
+
+func encodeReserveResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(response.(reservationResponse).Reservation)
+}
+
+func decodeReservationRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return reservationRequest{ID: mux.Vars(r)["id"]}, nil
+}
+
+func encodeReservationResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	return encodeResponse(ctx, w, response.(reservationResponse).Reservation)
+}
+
+// decodeReplayChangesRequest reads the offset to replay changes from, the
+// from query parameter.
+func decodeReplayChangesRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	from, err := strconv.ParseInt(r.FormValue("from"), 10, 64)
+	if err != nil || from < 1 {
+		return nil, errBadRequest
+	}
+	return replayChangesRequest{From: from}, nil
+}
+
 func decodeHealthRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	return struct{}{}, nil
 }
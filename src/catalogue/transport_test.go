@@ -0,0 +1,191 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+package catalogue
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	stdopentracing "github.com/opentracing/opentracing-go"
+)
+
+// stubService serves a single product. Methods it does not define panic
+// through the nil embedded Service.
+type stubService struct {
+	Service
+	product Product
+	deleted int // version passed to Delete
+}
+
+func (s *stubService) Get(ctx context.Context, id, currency string, languages []string) (Product, error) {
+	if id != s.product.ID {
+		return Product{}, ErrNotFound
+	}
+	return s.product, nil
+}
+
+func (s *stubService) Delete(ctx context.Context, id string, version int) error {
+	s.deleted = version
+	return nil
+}
+
+func newTestHandler(s Service) http.Handler {
+	return MakeHTTPHandler(MakeEndpoints(s, stdopentracing.NoopTracer{}), http.NotFoundHandler(), log.NewNopLogger(), stdopentracing.NoopTracer{})
+}
+
+func TestConditionalGet(t *testing.T) {
+	h := newTestHandler(&stubService{product: Product{ID: "1", Title: "Bowl", Version: 3}})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/catalogue/1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET: want %d, have %d", http.StatusOK, rec.Code)
+	}
+	etag := rec.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `"3-`) {
+		t.Errorf("ETag: want version 3 prefix, have %q", etag)
+	}
+	if have := rec.Header().Get("Cache-Control"); have != readCacheControl {
+		t.Errorf("Cache-Control: want %q, have %q", readCacheControl, have)
+	}
+
+	for _, match := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		req := httptest.NewRequest("GET", "/catalogue/1", nil)
+		req.Header.Set("If-None-Match", match)
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("If-None-Match %s: want %d, have %d", match, http.StatusNotModified, rec.Code)
+		}
+		if rec.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: want no body, have %q", match, rec.Body.String())
+		}
+		if have := rec.Header().Get("ETag"); have != etag {
+			t.Errorf("If-None-Match %s: want ETag %s, have %s", match, etag, have)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/catalogue/1", nil)
+	req.Header.Set("If-None-Match", `"3-stale"`)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("stale If-None-Match: want %d, have %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestBatchHTTP(t *testing.T) {
+	h := newTestHandler(newTestMemoryService(t))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/catalogue/batch?currency=usd", strings.NewReader(`{"ids": ["D", "E", "A"]}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /catalogue/batch: have %d %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Products []Product `json:"products"`
+		Missing  []string  `json:"missing"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := productIDs(resp.Products), []string{"D", "A"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("products: want %v, have %v", want, have)
+	}
+	if want := []string{"E"}; !reflect.DeepEqual(resp.Missing, want) {
+		t.Errorf("missing: want %v, have %v", want, resp.Missing)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/catalogue/batch", strings.NewReader(`["A"]`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /catalogue/batch without object: want %d, have %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	for match, want := range map[string]int{
+		`"3-0123abcd"`:   3,
+		`W/"4-0123abcd"`: 4,
+		`"5"`:            5,
+	} {
+		s := &stubService{}
+		req := httptest.NewRequest("DELETE", "/catalogue/1", nil)
+		req.Header.Set("If-Match", match)
+		rec := httptest.NewRecorder()
+		newTestHandler(s).ServeHTTP(rec, req)
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("If-Match %s: want %d, have %d", match, http.StatusNoContent, rec.Code)
+		}
+		if s.deleted != want {
+			t.Errorf("If-Match %s: want version %d, have %d", match, want, s.deleted)
+		}
+	}
+
+	req := httptest.NewRequest("DELETE", "/catalogue/1", nil)
+	req.Header.Set("If-Match", `"x-0123abcd"`)
+	rec := httptest.NewRecorder()
+	newTestHandler(&stubService{}).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("If-Match without version: want %d, have %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestStaleWritesBreaker(t *testing.T) {
+	h := newTestHandler(newTestMemoryService(t))
+
+	// Writes of a stale version conflict, but do not lock out those that
+	// are not.
+	for i := 0; i < 10; i++ {
+		for _, method := range []string{"PUT", "DELETE"} {
+			req := httptest.NewRequest(method, "/catalogue/A", strings.NewReader(`{"title": "Bowl", "price": 1}`))
+			req.Header.Set("If-Match", `"9"`)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusConflict {
+				t.Fatalf("%s stale version, %d: want %d, have %d %s", method, i, http.StatusConflict, rec.Code, rec.Body)
+			}
+		}
+	}
+	req := httptest.NewRequest("PUT", "/catalogue/A", strings.NewReader(`{"title": "Bowl", "price": 1}`))
+	req.Header.Set("If-Match", `"1"`)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("PUT current version: want %d, have %d %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	req = httptest.NewRequest("DELETE", "/catalogue/A", nil)
+	req.Header.Set("If-Match", `"2"`)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE current version: want %d, have %d %s", http.StatusNoContent, rec.Code, rec.Body)
+	}
+}
+
+func TestInvalidListBreaker(t *testing.T) {
+	h := newTestHandler(newTestMemoryService(t))
+
+	for i := 0; i < 10; i++ {
+		for _, query := range []string{"sort=bogus", "sort=price&cursor=bogus"} {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("GET", "/catalogue?"+query, nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("GET /catalogue?%s, %d: want %d, have %d %s", query, i, http.StatusBadRequest, rec.Code, rec.Body)
+			}
+		}
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/catalogue?sort=price", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /catalogue after invalid ones: want %d, have %d %s", http.StatusOK, rec.Code, rec.Body)
+	}
+}
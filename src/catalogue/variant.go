@@ -0,0 +1,111 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+
+package catalogue
+
+// variant.go contains the variants of products: the colours and sizes a
+// product comes in, each with a SKU, stock and price of its own.
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ErrVariantExists is returned when a variant SKU is taken by a variant of
+// another product.
+var ErrVariantExists = errors.New("variant already exists")
+
+// Variant is a child SKU of a product, in a colour and size. It is priced at
+// the price of the product plus its price delta, which reads return as its
+// price.
+type Variant struct {
+	ID             string   `json:"id"`
+	Color          string   `json:"color"`
+	Size           string   `json:"size"`
+	PriceDelta     float32  `json:"priceDelta"`
+	Price          float32  `json:"price,omitempty"`
+	FormattedPrice string   `json:"formattedPrice,omitempty"`
+	Qty            int      `json:"qty"`
+	ImageURL       []string `json:"imageUrl"`
+}
+
+// normalizeVariants validates the variants of a product submitted for
+// writing, and derives the flat fields older clients read from them: the
+// colors and sizes of all variants, and their total stock. Products without
+// variants keep their flat fields.
+func normalizeVariants(product Product) (Product, error) {
+	if len(product.Variants) == 0 {
+		product.Variants = nil
+		return product, nil
+	}
+	seen := map[string]bool{product.ID: true}
+	variants := make([]Variant, len(product.Variants))
+	var colors, sizes []string
+	qty := 0
+	for i, v := range product.Variants {
+		v.ID = strings.TrimSpace(v.ID)
+		v.Color = strings.TrimSpace(v.Color)
+		v.Size = strings.TrimSpace(v.Size)
+		if v.ID == "" || seen[v.ID] || v.Qty < 0 || product.Price+v.PriceDelta < 0 || len(v.ImageURL) > 2 {
+			return Product{}, ErrInvalidProduct
+		}
+		seen[v.ID] = true
+		images := make([]string, 2)
+		copy(images, v.ImageURL)
+		v.ImageURL = images
+		v.Price, v.FormattedPrice = 0, ""
+		variants[i] = v
+
+		colors = append(colors, v.Color)
+		sizes = append(sizes, v.Size)
+		qty += v.Qty
+	}
+	product.Variants = variants
+	product.Colors = strings.Join(distinct(colors), ", ")
+	product.ProductSize = strings.Join(distinct(sizes), ",")
+	product.Qty = qty
+	return product, nil
+}
+
+// parseVariants parses the variants column, the JSON array variantsColumn
+// builds, which is empty for products without variants.
+func parseVariants(list string) []Variant {
+	var variants []Variant
+	if list == "" || json.Unmarshal([]byte(list), &variants) != nil || len(variants) == 0 {
+		return nil
+	}
+	return variants
+}
+
+// sameVariants tells whether two lists of variants are the same, in order.
+func sameVariants(a, b []Variant) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		v, w := a[i], b[i]
+		if v.ID != w.ID || v.Color != w.Color || v.Size != w.Size || v.PriceDelta != w.PriceDelta || v.Qty != w.Qty ||
+			strings.Join(v.ImageURL, ",") != strings.Join(w.ImageURL, ",") {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesVariant tells whether a product matches the colors and sizes of a
+// filter, which must be lowered, in the same variant. Products without
+// variants match by their flat fields alone.
+func matchesVariant(p Product, colors, sizes []string) bool {
+	if len(p.Variants) == 0 || len(colors) == 0 || len(sizes) == 0 {
+		return true
+	}
+	for _, v := range p.Variants {
+		if contains(colors, strings.ToLower(v.Color)) && contains(sizes, strings.ToLower(v.Size)) {
+			return true
+		}
+	}
+	return false
+}
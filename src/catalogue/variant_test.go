@@ -0,0 +1,130 @@
+/*
+** Copyright © 2020, Oracle and/or its affiliates. All rights reserved.
+** Licensed under the Universal Permissive License v 1.0 as shown at http://oss.oracle.com/licenses/upl.
+ */
+package catalogue
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testVariants = []Variant{
+	{ID: "E-S-RED", Color: "Red", Size: "S", Qty: 2, ImageURL: []string{"e-red.png"}},
+	{ID: "E-L-RED", Color: "Red", Size: "L", PriceDelta: 1.5, Qty: 1},
+	{ID: "E-S-BLUE", Color: "Blue", Size: "S", PriceDelta: -0.5, Qty: 0},
+}
+
+func TestNormalizeVariants(t *testing.T) {
+	p, err := normalizeVariants(Product{ID: "E", Title: "Collar", Colors: "Green", Qty: 9, Price: 10, Variants: testVariants})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Colors != "Red, Blue" || p.ProductSize != "S,L" || p.Qty != 3 {
+		t.Errorf("flat fields: have colors %q, sizes %q, qty %d", p.Colors, p.ProductSize, p.Qty)
+	}
+	if want := []string{"e-red.png", ""}; !reflect.DeepEqual(p.Variants[0].ImageURL, want) {
+		t.Errorf("images: want %q, have %q", want, p.Variants[0].ImageURL)
+	}
+
+	for name, variants := range map[string][]Variant{
+		"no id":          {{Color: "Red"}},
+		"repeated id":    {{ID: "E-1"}, {ID: "E-1"}},
+		"product id":     {{ID: "E"}},
+		"negative qty":   {{ID: "E-1", Qty: -1}},
+		"negative price": {{ID: "E-1", PriceDelta: -10.5}},
+		"3 images":       {{ID: "E-1", ImageURL: []string{"1", "2", "3"}}},
+	} {
+		if _, err := normalizeVariants(Product{ID: "E", Title: "Collar", Price: 10, Variants: variants}); err != ErrInvalidProduct {
+			t.Errorf("%s: want %v, have %v", name, ErrInvalidProduct, err)
+		}
+	}
+}
+
+func TestVariants(t *testing.T) {
+	ctx := context.Background()
+	s := newTestMemoryService(t)
+	if _, err := s.SetRates(ctx, Rates{Base: "USD", Rates: map[string]json.Number{"EUR": "0.5"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create(ctx, Product{ID: "E", Title: "Collar", Price: 10, Variants: testVariants}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create(ctx, Product{ID: "F", Title: "Leash", Variants: []Variant{{ID: "E-S-RED"}}}); err != ErrVariantExists {
+		t.Errorf("Create with taken variant: want %v, have %v", ErrVariantExists, err)
+	}
+
+	p, err := s.Get(ctx, "E", "EUR", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prices []string
+	for _, v := range p.Variants {
+		prices = append(prices, v.FormattedPrice)
+	}
+	if want := []string{"€5.00", "€5.75", "€4.75"}; !reflect.DeepEqual(prices, want) {
+		t.Errorf("variant prices: want %v, have %v", want, prices)
+	}
+	if p.Colors != "Red, Blue" || p.Qty != 3 {
+		t.Errorf("flat fields: have colors %q, qty %d", p.Colors, p.Qty)
+	}
+
+	for _, tc := range []struct {
+		filter Filter
+		want   []string
+	}{
+		{Filter{Colors: []string{"blue"}, Sizes: []string{"s"}}, []string{"E"}},
+		{Filter{Colors: []string{"Blue"}, Sizes: []string{"L"}}, []string{}},
+		{Filter{Colors: []string{"Red"}}, []string{"A", "B", "E"}},
+	} {
+		products, _, err := s.List(ctx, tc.filter, "", "", "", nil, 1, 10)
+		if have := productIDs(products); err != nil || !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("%s: want %v, have %v, %v", formatFilter(tc.filter), tc.want, have, err)
+		}
+	}
+
+	p.Variants = p.Variants[:1]
+	if p, err = s.Update(ctx, p); err != nil || p.Colors != "Red" || p.Qty != 2 || len(p.Variants) != 1 {
+		t.Errorf("Update to 1 variant: have %v, %v", p, err)
+	}
+}
+
+func TestVariantsHTTP(t *testing.T) {
+	h := newTestHandler(newTestMemoryService(t))
+
+	body := `{"id": "E", "title": "Collar", "price": 10, "variants": [{"id": "E-S", "color": "Red", "size": "S", "priceDelta": 2, "qty": 4}]}`
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/catalogue", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /catalogue: have %d %s", rec.Code, rec.Body)
+	}
+	body = `{"id": "F", "title": "Leash", "variants": [{"id": "E-S"}]}`
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/catalogue", strings.NewReader(body)))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("POST with taken variant: want %d, have %d", http.StatusConflict, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/catalogue/E", nil))
+	var p struct {
+		Colors   string `json:"colors"`
+		Size     string `json:"product_size"`
+		Qty      int    `json:"qty"`
+		Variants []struct {
+			ID    string  `json:"id"`
+			Price float32 `json:"price"`
+		} `json:"variants"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Colors != "Red" || p.Size != "S" || p.Qty != 4 || len(p.Variants) != 1 || p.Variants[0].Price != 12 {
+		t.Errorf("GET /catalogue/E: have %+v", p)
+	}
+}